@@ -0,0 +1,91 @@
+// Package bytecode compiles Lox to bytecode and runs it on a stack-based
+// virtual machine, in the manner of clox. It runs the same language as the
+// tree-walk interpreter and produces the same output, except that the
+// compiler rejects programs that its instructions can't encode: functions
+// with more than 255 local variables, 256 closure variables or 65536
+// constants, list literals of more than 65535 elements, and jumps over more
+// than 65535 bytes of code.
+package bytecode
+
+import "github.com/fosmjo/lox/scanner"
+
+type OpCode byte
+
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	OpGetLocal
+	OpSetLocal
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	OpGetUpvalue
+	OpSetUpvalue
+	OpGetProperty
+	OpSetProperty
+	OpGetSuper
+	OpGetIndex
+	OpSetIndex
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpNot
+	OpNegate
+	OpPrint
+	OpJump
+	OpJumpIfFalse
+	OpLoop
+	OpCall
+	OpClosure
+	OpCloseUpvalue
+	OpReturn
+	OpClass
+	OpInherit
+	OpMethod
+	OpList
+	OpMap
+	OpMapAdd
+	OpImport
+	OpImportName
+	OpTry
+	OpEndTry
+	OpCatch
+	OpThrow
+	OpRethrow
+)
+
+// Chunk is a sequence of instructions along with the constants they refer
+// to. Every byte of code has the span of the source it was compiled from,
+// so that runtime errors can point at it.
+type Chunk struct {
+	Code      []byte
+	Lines     []int
+	Spans     []scanner.Span
+	Constants []Value
+	// Steps marks the instructions that start statements, which count
+	// against the VM's step limit.
+	Steps []bool
+}
+
+func (c *Chunk) Write(b byte, span scanner.Span) {
+	c.Code = append(c.Code, b)
+	c.Lines = append(c.Lines, span.Start.Line)
+	c.Spans = append(c.Spans, span)
+	c.Steps = append(c.Steps, false)
+}
+
+// AddConstant adds value to the chunk's constants and returns its index.
+func (c *Chunk) AddConstant(value Value) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
@@ -0,0 +1,841 @@
+package bytecode
+
+import (
+	"math"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+const (
+	maxLocals   = math.MaxUint8 + 1
+	maxUpvalues = math.MaxUint8 + 1
+	maxShort    = math.MaxUint16
+)
+
+type local struct {
+	name       string
+	depth      int
+	isCaptured bool
+}
+
+type upvalue struct {
+	index   byte
+	isLocal bool
+}
+
+// loop is a loop being compiled, with the jumps that 'break' and
+// 'continue' statements in its body have left to patch.
+type loop struct {
+	enclosing  *loop
+	scopeDepth int
+	tryDepth   int
+	breaks     []int
+	continues  []int
+}
+
+// tryBlock is the body of a try statement being compiled, during which its
+// handler is installed.
+type tryBlock struct {
+	finally []parser.Stmt
+}
+
+type classCompiler struct {
+	enclosing     *classCompiler
+	hasSuperclass bool
+}
+
+// Compiler compiles a resolved syntax tree to bytecode. There is one
+// Compiler for each function being compiled, linked to the Compiler of the
+// function it is nested in.
+type Compiler struct {
+	enclosing  *Compiler
+	function   *Function
+	locals     []local
+	upvalues   []upvalue
+	scopeDepth int
+	loop       *loop
+	tries      []tryBlock
+	class      *classCompiler
+	names      map[string]int
+	diags      *diag.List
+
+	// span is attributed to the instructions emitted for the node being
+	// compiled, unless an instruction is given a span of its own.
+	span scanner.Span
+}
+
+// Compile compiles a script. If its last statement is an expression
+// statement, the script returns that expression's value.
+func Compile(stmts []parser.Stmt) (*Function, diag.List) {
+	var diags diag.List
+	c := newCompiler(nil, KindScript, "", &diags)
+
+	for j, stmt := range stmts {
+		if exprStmt, ok := stmt.(*parser.ExpressionStmt); ok && j == len(stmts)-1 {
+			c.span = stmt.Span()
+			c.compileExpr(exprStmt.Expression)
+			c.emitOp(OpReturn)
+			return c.function, diags
+		}
+		c.compileStmt(stmt)
+	}
+
+	if len(stmts) > 0 {
+		c.span = endOf(stmts[len(stmts)-1].Span())
+	}
+	c.emitReturn()
+	return c.function, diags
+}
+
+func newCompiler(enclosing *Compiler, kind FunctionKind, name string, diags *diag.List) *Compiler {
+	c := &Compiler{
+		enclosing: enclosing,
+		function:  &Function{Name: name, Kind: kind},
+		names:     make(map[string]int),
+		diags:     diags,
+	}
+	if enclosing != nil {
+		c.class = enclosing.class
+	}
+
+	// Slot zero holds the function being called, or the receiver of a
+	// method.
+	slotZero := ""
+	if kind == KindMethod || kind == KindInitializer {
+		slotZero = "this"
+	}
+	c.locals = append(c.locals, local{name: slotZero})
+	return c
+}
+
+func (c *Compiler) VisitBlockStmt(stmt *parser.BlockStmt) (interface{}, error) {
+	c.block(stmt.Statements)
+	return nil, nil
+}
+
+func (c *Compiler) VisitBreakStmt(stmt *parser.BreakStmt) (interface{}, error) {
+	c.exitTries(c.loop.tryDepth)
+	c.discardLocals(c.loop.scopeDepth)
+	c.loop.breaks = append(c.loop.breaks, c.emitJump(OpJump))
+	return nil, nil
+}
+
+func (c *Compiler) VisitClassStmt(stmt *parser.ClassStmt) (interface{}, error) {
+	name := c.identifierConstant(stmt.Name.Lexeme)
+	c.declareVariable(stmt.Name)
+
+	c.emitShortAt(stmt.Name.Span, OpClass, name)
+	c.defineVariable(name)
+
+	class := &classCompiler{enclosing: c.class}
+	c.class = class
+
+	if stmt.Superclass != nil {
+		c.compileExpr(stmt.Superclass)
+
+		c.beginScope()
+		c.addLocal(scanner.Token{Lexeme: "super", Span: stmt.Superclass.Span()})
+		c.markInitialized()
+
+		c.namedVariable(stmt.Name, false)
+		c.emitAt(stmt.Superclass.Name.Span, OpInherit)
+		class.hasSuperclass = true
+	}
+
+	c.namedVariable(stmt.Name, false)
+	for _, method := range stmt.Methods {
+		kind := KindMethod
+		if method.Name.Lexeme == "init" {
+			kind = KindInitializer
+		}
+		c.compileFunction(method, kind)
+		c.emitShortAt(method.Name.Span, OpMethod, c.identifierConstant(method.Name.Lexeme))
+	}
+	c.emitOp(OpPop)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+
+	c.class = class.enclosing
+	return nil, nil
+}
+
+func (c *Compiler) VisitContinueStmt(stmt *parser.ContinueStmt) (interface{}, error) {
+	c.exitTries(c.loop.tryDepth)
+	c.discardLocals(c.loop.scopeDepth)
+	c.loop.continues = append(c.loop.continues, c.emitJump(OpJump))
+	return nil, nil
+}
+
+func (c *Compiler) VisitExpressionStmt(stmt *parser.ExpressionStmt) (interface{}, error) {
+	c.compileExpr(stmt.Expression)
+	c.emitOp(OpPop)
+	return nil, nil
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt *parser.FunctionStmt) (interface{}, error) {
+	name := c.identifierConstant(stmt.Name.Lexeme)
+	c.declareVariable(stmt.Name)
+	if c.scopeDepth > 0 {
+		// A local function may refer to itself.
+		c.markInitialized()
+	}
+	c.compileFunction(stmt, KindFunction)
+	c.defineVariable(name)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt *parser.IfStmt) (interface{}, error) {
+	c.compileExpr(stmt.Condition)
+
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.compileStmt(stmt.ThenBranch)
+
+	elseJump := c.emitJump(OpJump)
+	c.patchJump(thenJump)
+	c.emitOp(OpPop)
+	if stmt.ElseBranch != nil {
+		c.compileStmt(stmt.ElseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil, nil
+}
+
+func (c *Compiler) VisitImportStmt(stmt *parser.ImportStmt) (interface{}, error) {
+	path := c.makeConstant(stmt.Path.Literal, stmt.Path.Span)
+
+	if len(stmt.Names) == 0 {
+		name := c.identifierConstant(stmt.Alias.Lexeme)
+		c.declareVariable(stmt.Alias)
+		c.emitShortAt(stmt.Path.Span, OpImport, path)
+		c.defineVariable(name)
+		return nil, nil
+	}
+
+	// A module that is already loaded is only looked up again, so each
+	// name can import it afresh.
+	for _, token := range stmt.Names {
+		name := c.identifierConstant(token.Lexeme)
+		c.declareVariable(token)
+		c.emitShortAt(stmt.Path.Span, OpImport, path)
+		c.emitShortAt(token.Span, OpImportName, name)
+		c.defineVariable(name)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitPrintStmt(stmt *parser.PrintStmt) (interface{}, error) {
+	c.compileExpr(stmt.Expression)
+	c.emitOp(OpPrint)
+	return nil, nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt *parser.ReturnStmt) (interface{}, error) {
+	switch {
+	case stmt.Value != nil:
+		c.compileExpr(stmt.Value)
+	case c.function.Kind == KindInitializer:
+		c.emitBytes(byte(OpGetLocal), 0)
+	default:
+		c.emitOp(OpNil)
+	}
+
+	if len(c.tries) == 0 {
+		c.emitOp(OpReturn)
+		return nil, nil
+	}
+
+	// Leaving try statements runs their finally blocks, so the value is
+	// kept in a local while they run.
+	c.beginScope()
+	c.addLocal(stmt.Keyword)
+	c.markInitialized()
+	slot := len(c.locals) - 1
+
+	c.exitTries(0)
+	c.emitBytes(byte(OpGetLocal), byte(slot))
+	c.emitOp(OpReturn)
+
+	c.locals = c.locals[:slot]
+	c.scopeDepth--
+	return nil, nil
+}
+
+func (c *Compiler) VisitThrowStmt(stmt *parser.ThrowStmt) (interface{}, error) {
+	c.compileExpr(stmt.Value)
+	c.emitAt(stmt.Keyword.Span, OpThrow)
+	return nil, nil
+}
+
+func (c *Compiler) VisitTryStmt(stmt *parser.TryStmt) (interface{}, error) {
+	if stmt.FinallyBody == nil {
+		c.tryCatch(stmt)
+		return nil, nil
+	}
+
+	handler := c.emitJump(OpTry)
+	c.tries = append(c.tries, tryBlock{finally: stmt.FinallyBody})
+	if stmt.CatchBody != nil {
+		c.tryCatch(stmt)
+	} else {
+		c.block(stmt.Body)
+	}
+	c.tries = c.tries[:len(c.tries)-1]
+	c.emitOp(OpEndTry)
+	c.block(stmt.FinallyBody)
+	end := c.emitJump(OpJump)
+
+	// An error raised in the try statement runs the finally block, and is
+	// then raised again.
+	c.patchJump(handler)
+	c.beginScope()
+	c.addLocal(scanner.Token{Span: c.span})
+	c.markInitialized()
+	c.block(stmt.FinallyBody)
+	c.emitOp(OpRethrow)
+	c.locals = c.locals[:len(c.locals)-1]
+	c.scopeDepth--
+
+	c.patchJump(end)
+	return nil, nil
+}
+
+func (c *Compiler) tryCatch(stmt *parser.TryStmt) {
+	handler := c.emitJump(OpTry)
+	c.tries = append(c.tries, tryBlock{})
+	c.block(stmt.Body)
+	c.tries = c.tries[:len(c.tries)-1]
+	c.emitOp(OpEndTry)
+	end := c.emitJump(OpJump)
+
+	c.patchJump(handler)
+	c.emitOp(OpCatch)
+	c.beginScope()
+	c.addLocal(stmt.CatchName)
+	c.markInitialized()
+	for _, s := range stmt.CatchBody {
+		c.compileStmt(s)
+	}
+	c.endScope()
+
+	c.patchJump(end)
+}
+
+func (c *Compiler) VisitVarStmt(stmt *parser.VarStmt) (interface{}, error) {
+	name := c.identifierConstant(stmt.Name.Lexeme)
+	c.declareVariable(stmt.Name)
+
+	if stmt.Initializer != nil {
+		c.compileExpr(stmt.Initializer)
+	} else {
+		c.emitOp(OpNil)
+	}
+
+	c.defineVariable(name)
+	return nil, nil
+}
+
+func (c *Compiler) VisitWhileStmt(stmt *parser.WhileStmt) (interface{}, error) {
+	loopStart := len(c.function.Chunk.Code)
+	c.compileExpr(stmt.Condition)
+
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+
+	c.loop = &loop{enclosing: c.loop, scopeDepth: c.scopeDepth, tryDepth: len(c.tries)}
+	c.compileStmt(stmt.Body)
+
+	for _, jump := range c.loop.continues {
+		c.patchJump(jump)
+	}
+	if stmt.Increment != nil {
+		c.compileExpr(stmt.Increment)
+		c.emitOp(OpPop)
+	}
+	c.emitLoop(loopStart)
+
+	c.patchJump(exitJump)
+	c.emitOp(OpPop)
+
+	for _, jump := range c.loop.breaks {
+		c.patchJump(jump)
+	}
+	c.loop = c.loop.enclosing
+	return nil, nil
+}
+
+func (c *Compiler) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, error) {
+	c.compileExpr(expr.Value)
+	c.namedVariable(expr.Name, true)
+	return nil, nil
+}
+
+func (c *Compiler) VisitBinaryExpr(expr *parser.BinaryExpr) (interface{}, error) {
+	c.compileExpr(expr.Left)
+	c.compileExpr(expr.Right)
+
+	var op OpCode
+	switch expr.Operator.Type {
+	case scanner.GREATER:
+		op = OpGreater
+	case scanner.GREATER_EQUAL:
+		op = OpGreaterEqual
+	case scanner.LESS:
+		op = OpLess
+	case scanner.LESS_EQUAL:
+		op = OpLessEqual
+	case scanner.EQUAL_EQUAL:
+		op = OpEqual
+	case scanner.BANG_EQUAL:
+		op = OpNotEqual
+	case scanner.MINUS:
+		op = OpSubtract
+	case scanner.PLUS:
+		op = OpAdd
+	case scanner.SLASH:
+		op = OpDivide
+	case scanner.STAR:
+		op = OpMultiply
+	}
+	c.emitAt(expr.Operator.Span, op)
+	return nil, nil
+}
+
+func (c *Compiler) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
+	c.compileExpr(expr.Callee)
+	for _, arg := range expr.Arguments {
+		c.compileExpr(arg)
+	}
+	c.emitAt(expr.Paren.Span, OpCall, byte(len(expr.Arguments)))
+	return nil, nil
+}
+
+func (c *Compiler) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.emitShortAt(expr.Name.Span, OpGetProperty, c.identifierConstant(expr.Name.Lexeme))
+	return nil, nil
+}
+
+func (c *Compiler) VisitGroupingExpr(expr *parser.GroupingExpr) (interface{}, error) {
+	c.compileExpr(expr.Expression)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIndexExpr(expr *parser.IndexExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.emitAt(expr.Bracket.Span, OpGetIndex)
+	return nil, nil
+}
+
+func (c *Compiler) VisitIndexSetExpr(expr *parser.IndexSetExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.compileExpr(expr.Value)
+	c.emitAt(expr.Bracket.Span, OpSetIndex)
+	return nil, nil
+}
+
+func (c *Compiler) VisitLambdaExpr(expr *parser.LambdaExpr) (interface{}, error) {
+	c.compileFunction(expr.Declaration, KindFunction)
+	return nil, nil
+}
+
+func (c *Compiler) VisitListExpr(expr *parser.ListExpr) (interface{}, error) {
+	for _, element := range expr.Elements {
+		c.compileExpr(element)
+	}
+
+	if len(expr.Elements) > maxShort {
+		c.error(c.span, diag.TooManyElements, "Too many elements in list literal.")
+	}
+	c.emitShort(OpList, len(expr.Elements))
+	return nil, nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr *parser.LiteralExpr) (interface{}, error) {
+	switch expr.Value {
+	case nil:
+		c.emitOp(OpNil)
+	case true:
+		c.emitOp(OpTrue)
+	case false:
+		c.emitOp(OpFalse)
+	default:
+		c.emitShort(OpConstant, c.makeConstant(expr.Value, c.span))
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitLogicalExpr(expr *parser.LogicalExpr) (interface{}, error) {
+	c.compileExpr(expr.Left)
+
+	if expr.Operator.Type == scanner.OR {
+		elseJump := c.emitJump(OpJumpIfFalse)
+		endJump := c.emitJump(OpJump)
+		c.patchJump(elseJump)
+		c.emitOp(OpPop)
+		c.compileExpr(expr.Right)
+		c.patchJump(endJump)
+		return nil, nil
+	}
+
+	endJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.compileExpr(expr.Right)
+	c.patchJump(endJump)
+	return nil, nil
+}
+
+func (c *Compiler) VisitMapExpr(expr *parser.MapExpr) (interface{}, error) {
+	c.emitAt(expr.Brace.Span, OpMap)
+	for j := range expr.Keys {
+		c.compileExpr(expr.Keys[j])
+		c.compileExpr(expr.Values[j])
+		c.emitAt(expr.Brace.Span, OpMapAdd)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Value)
+	c.emitShortAt(expr.Name.Span, OpSetProperty, c.identifierConstant(expr.Name.Lexeme))
+	return nil, nil
+}
+
+func (c *Compiler) VisitSuperExpr(expr *parser.SuperExpr) (interface{}, error) {
+	name := c.identifierConstant(expr.Method.Lexeme)
+	c.namedVariable(scanner.Token{Lexeme: "this", Span: expr.Keyword.Span}, false)
+	c.namedVariable(expr.Keyword, false)
+	c.emitShortAt(expr.Method.Span, OpGetSuper, name)
+	return nil, nil
+}
+
+func (c *Compiler) VisitThisExpr(expr *parser.ThisExpr) (interface{}, error) {
+	c.namedVariable(expr.Keyword, false)
+	return nil, nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr *parser.UnaryExpr) (interface{}, error) {
+	c.compileExpr(expr.Right)
+
+	switch expr.Operator.Type {
+	case scanner.BANG:
+		c.emitAt(expr.Operator.Span, OpNot)
+	case scanner.MINUS:
+		c.emitAt(expr.Operator.Span, OpNegate)
+	}
+	return nil, nil
+}
+
+func (c *Compiler) VisitVariableExpr(expr *parser.VariableExpr) (interface{}, error) {
+	c.namedVariable(expr.Name, false)
+	return nil, nil
+}
+
+// compileFunction compiles the declaration of a function and emits the
+// closure that creates it.
+func (c *Compiler) compileFunction(stmt *parser.FunctionStmt, kind FunctionKind) {
+	name := ""
+	if stmt.Name.Type == scanner.IDENTIFIER {
+		name = stmt.Name.Lexeme
+	}
+
+	fc := newCompiler(c, kind, name, c.diags)
+	fc.span = stmt.Span()
+	fc.beginScope()
+
+	fc.function.Arity = len(stmt.Params)
+	for _, param := range stmt.Params {
+		fc.declareVariable(param)
+		fc.markInitialized()
+	}
+	for _, s := range stmt.Body {
+		fc.compileStmt(s)
+	}
+	fc.span = endOf(stmt.Span())
+	fc.emitReturn()
+
+	fn := fc.function
+	fn.UpvalueCount = len(fc.upvalues)
+
+	preSpan := c.span
+	c.span = stmt.Span()
+	c.emitShort(OpClosure, c.makeConstant(fn, stmt.Span()))
+	for _, uv := range fc.upvalues {
+		isLocal := byte(0)
+		if uv.isLocal {
+			isLocal = 1
+		}
+		c.emitBytes(isLocal, uv.index)
+	}
+	c.span = preSpan
+}
+
+func (c *Compiler) block(stmts []parser.Stmt) {
+	c.beginScope()
+	for _, stmt := range stmts {
+		c.compileStmt(stmt)
+	}
+	c.endScope()
+}
+
+func (c *Compiler) compileStmt(stmt parser.Stmt) {
+	preSpan := c.span
+	c.span = stmt.Span()
+	start := len(c.function.Chunk.Code)
+	stmt.Accept(c)
+	// A statement that compiles to no code, such as a variable declared
+	// without a value, doesn't count as a step. One nested in another at the
+	// same instruction counts along with it, as a single step.
+	if start < len(c.function.Chunk.Code) {
+		c.function.Chunk.Steps[start] = true
+	}
+	c.span = preSpan
+}
+
+func (c *Compiler) compileExpr(expr parser.Expr) {
+	preSpan := c.span
+	c.span = expr.Span()
+	expr.Accept(c)
+	c.span = preSpan
+}
+
+func (c *Compiler) beginScope() {
+	c.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	c.scopeDepth--
+	c.discardLocals(c.scopeDepth)
+
+	n := len(c.locals)
+	for n > 0 && c.locals[n-1].depth > c.scopeDepth {
+		n--
+	}
+	c.locals = c.locals[:n]
+}
+
+// discardLocals emits the instructions that pop the locals deeper than
+// depth off the stack, without ending their scopes.
+func (c *Compiler) discardLocals(depth int) {
+	for i := len(c.locals) - 1; i >= 0 && c.locals[i].depth > depth; i-- {
+		if c.locals[i].isCaptured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+	}
+}
+
+// exitTries emits the instructions that leave the try statements from the
+// innermost down to the one at depth, running their finally blocks.
+func (c *Compiler) exitTries(depth int) {
+	tries := c.tries
+	defer func() { c.tries = tries }()
+
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emitOp(OpEndTry)
+		if tries[i].finally != nil {
+			c.tries = tries[:i]
+			c.block(tries[i].finally)
+		}
+	}
+}
+
+func (c *Compiler) declareVariable(name scanner.Token) {
+	if c.scopeDepth == 0 {
+		return
+	}
+	c.addLocal(name)
+}
+
+func (c *Compiler) addLocal(name scanner.Token) {
+	if len(c.locals) == maxLocals {
+		c.error(name.Span, diag.TooManyLocals, "Too many local variables in function.")
+		return
+	}
+	c.locals = append(c.locals, local{name: name.Lexeme, depth: -1})
+}
+
+func (c *Compiler) markInitialized() {
+	if c.scopeDepth == 0 {
+		return
+	}
+	c.locals[len(c.locals)-1].depth = c.scopeDepth
+}
+
+func (c *Compiler) defineVariable(global int) {
+	if c.scopeDepth > 0 {
+		c.markInitialized()
+		return
+	}
+	c.emitShort(OpDefineGlobal, global)
+}
+
+func (c *Compiler) namedVariable(name scanner.Token, assign bool) {
+	getOp, setOp := OpGetLocal, OpSetLocal
+	arg := c.resolveLocal(name.Lexeme)
+	if arg == -1 {
+		getOp, setOp = OpGetUpvalue, OpSetUpvalue
+		arg = c.resolveUpvalue(name.Lexeme)
+	}
+
+	op := getOp
+	if assign {
+		op = setOp
+	}
+
+	if arg == -1 {
+		op = OpGetGlobal
+		if assign {
+			op = OpSetGlobal
+		}
+		c.emitShortAt(name.Span, op, c.identifierConstant(name.Lexeme))
+		return
+	}
+	c.emitAt(name.Span, op, byte(arg))
+}
+
+func (c *Compiler) resolveLocal(name string) int {
+	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.locals[i].name == name && c.locals[i].depth != -1 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+
+	if local := c.enclosing.resolveLocal(name); local != -1 {
+		c.enclosing.locals[local].isCaptured = true
+		return c.addUpvalue(byte(local), true)
+	}
+
+	if upvalue := c.enclosing.resolveUpvalue(name); upvalue != -1 {
+		return c.addUpvalue(byte(upvalue), false)
+	}
+
+	return -1
+}
+
+func (c *Compiler) addUpvalue(index byte, isLocal bool) int {
+	for i, uv := range c.upvalues {
+		if uv.index == index && uv.isLocal == isLocal {
+			return i
+		}
+	}
+
+	if len(c.upvalues) == maxUpvalues {
+		c.error(c.span, diag.TooManyUpvalues, "Too many closure variables in function.")
+		return 0
+	}
+
+	c.upvalues = append(c.upvalues, upvalue{index: index, isLocal: isLocal})
+	return len(c.upvalues) - 1
+}
+
+// identifierConstant returns the index of the constant holding name,
+// adding it the first time the function refers to name.
+func (c *Compiler) identifierConstant(name string) int {
+	if index, ok := c.names[name]; ok {
+		return index
+	}
+
+	index := c.makeConstant(name, c.span)
+	c.names[name] = index
+	return index
+}
+
+func (c *Compiler) makeConstant(value Value, span scanner.Span) int {
+	chunk := &c.function.Chunk
+	if len(chunk.Constants) > maxShort {
+		c.error(span, diag.TooManyConstants, "Too many constants in one chunk.")
+		return 0
+	}
+	return chunk.AddConstant(value)
+}
+
+func (c *Compiler) emitReturn() {
+	if c.function.Kind == KindInitializer {
+		c.emitBytes(byte(OpGetLocal), 0)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.emitOp(OpReturn)
+}
+
+func (c *Compiler) emitJump(op OpCode) int {
+	c.emitBytes(byte(op), 0xff, 0xff)
+	return len(c.function.Chunk.Code) - 2
+}
+
+// patchJump points the jump whose operand is at offset to the next
+// instruction.
+func (c *Compiler) patchJump(offset int) {
+	code := c.function.Chunk.Code
+	jump := len(code) - offset - 2
+	if jump > maxShort {
+		c.error(c.span, diag.JumpTooLarge, "Too much code to jump over.")
+	}
+
+	code[offset] = byte(jump >> 8)
+	code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(loopStart int) {
+	c.emitOp(OpLoop)
+
+	offset := len(c.function.Chunk.Code) - loopStart + 2
+	if offset > maxShort {
+		c.error(c.span, diag.JumpTooLarge, "Loop body too large.")
+	}
+	c.emitBytes(byte(offset>>8), byte(offset))
+}
+
+func (c *Compiler) emitOp(op OpCode) {
+	c.emitBytes(byte(op))
+}
+
+func (c *Compiler) emitShort(op OpCode, operand int) {
+	c.emitBytes(byte(op), byte(operand>>8), byte(operand))
+}
+
+func (c *Compiler) emitBytes(bytes ...byte) {
+	for _, b := range bytes {
+		c.function.Chunk.Write(b, c.span)
+	}
+}
+
+// emitAt emits an instruction that can fail at runtime, attributing it to
+// the span of the token that errors should point at.
+func (c *Compiler) emitAt(span scanner.Span, op OpCode, operands ...byte) {
+	preSpan := c.span
+	c.span = span
+	c.emitBytes(append([]byte{byte(op)}, operands...)...)
+	c.span = preSpan
+}
+
+func (c *Compiler) emitShortAt(span scanner.Span, op OpCode, operand int) {
+	c.emitAt(span, op, byte(operand>>8), byte(operand))
+}
+
+// endOf returns the empty span at the end of span, for code such as an
+// implicit return that belongs to where a function finishes.
+func endOf(span scanner.Span) scanner.Span {
+	span.Start = span.End
+	return span
+}
+
+func (c *Compiler) error(span scanner.Span, code diag.Code, msg string) {
+	c.diags.Add(code, diag.Error, span, msg)
+}
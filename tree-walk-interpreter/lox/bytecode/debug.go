@@ -0,0 +1,193 @@
+package bytecode
+
+import (
+	"fmt"
+	"io"
+)
+
+var opNames = [...]string{
+	OpConstant:     "OP_CONSTANT",
+	OpNil:          "OP_NIL",
+	OpTrue:         "OP_TRUE",
+	OpFalse:        "OP_FALSE",
+	OpPop:          "OP_POP",
+	OpGetLocal:     "OP_GET_LOCAL",
+	OpSetLocal:     "OP_SET_LOCAL",
+	OpGetGlobal:    "OP_GET_GLOBAL",
+	OpDefineGlobal: "OP_DEFINE_GLOBAL",
+	OpSetGlobal:    "OP_SET_GLOBAL",
+	OpGetUpvalue:   "OP_GET_UPVALUE",
+	OpSetUpvalue:   "OP_SET_UPVALUE",
+	OpGetProperty:  "OP_GET_PROPERTY",
+	OpSetProperty:  "OP_SET_PROPERTY",
+	OpGetSuper:     "OP_GET_SUPER",
+	OpGetIndex:     "OP_GET_INDEX",
+	OpSetIndex:     "OP_SET_INDEX",
+	OpEqual:        "OP_EQUAL",
+	OpNotEqual:     "OP_NOT_EQUAL",
+	OpGreater:      "OP_GREATER",
+	OpGreaterEqual: "OP_GREATER_EQUAL",
+	OpLess:         "OP_LESS",
+	OpLessEqual:    "OP_LESS_EQUAL",
+	OpAdd:          "OP_ADD",
+	OpSubtract:     "OP_SUBTRACT",
+	OpMultiply:     "OP_MULTIPLY",
+	OpDivide:       "OP_DIVIDE",
+	OpNot:          "OP_NOT",
+	OpNegate:       "OP_NEGATE",
+	OpPrint:        "OP_PRINT",
+	OpJump:         "OP_JUMP",
+	OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	OpLoop:         "OP_LOOP",
+	OpCall:         "OP_CALL",
+	OpClosure:      "OP_CLOSURE",
+	OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+	OpReturn:       "OP_RETURN",
+	OpClass:        "OP_CLASS",
+	OpInherit:      "OP_INHERIT",
+	OpMethod:       "OP_METHOD",
+	OpList:         "OP_LIST",
+	OpMap:          "OP_MAP",
+	OpMapAdd:       "OP_MAP_ADD",
+	OpImport:       "OP_IMPORT",
+	OpImportName:   "OP_IMPORT_NAME",
+	OpTry:          "OP_TRY",
+	OpEndTry:       "OP_END_TRY",
+	OpCatch:        "OP_CATCH",
+	OpThrow:        "OP_THROW",
+	OpRethrow:      "OP_RETHROW",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("OpCode(%d)", op)
+}
+
+// Disassemble prints the chunk of function and then, in the order they
+// appear among its constants, those of the functions nested in it.
+func Disassemble(w io.Writer, function *Function) {
+	DisassembleChunk(w, &function.Chunk, chunkName(function))
+	for _, constant := range function.Chunk.Constants {
+		if nested, ok := constant.(*Function); ok {
+			fmt.Fprintln(w)
+			Disassemble(w, nested)
+		}
+	}
+}
+
+func chunkName(function *Function) string {
+	switch {
+	case function.Kind == KindScript:
+		return "<script>"
+	case function.Name == "":
+		return "<anonymous>"
+	}
+	return function.Name
+}
+
+// DisassembleChunk prints every instruction in chunk under a header naming
+// it.
+func DisassembleChunk(w io.Writer, chunk *Chunk, name string) {
+	fmt.Fprintf(w, "== %s ==\n", name)
+
+	for offset := 0; offset < len(chunk.Code); {
+		offset = DisassembleInstruction(w, chunk, offset)
+	}
+}
+
+// DisassembleInstruction prints the instruction at offset and returns the
+// offset of the next one.
+func DisassembleInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	fmt.Fprintf(w, "%04d ", offset)
+	if offset > 0 && chunk.Lines[offset] == chunk.Lines[offset-1] {
+		fmt.Fprint(w, "   | ")
+	} else {
+		fmt.Fprintf(w, "%4d ", chunk.Lines[offset])
+	}
+
+	op := OpCode(chunk.Code[offset])
+	switch op {
+	case OpConstant, OpGetGlobal, OpDefineGlobal, OpSetGlobal,
+		OpGetProperty, OpSetProperty, OpGetSuper,
+		OpClass, OpMethod, OpImport, OpImportName:
+		return constantInstruction(w, op, chunk, offset)
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall:
+		return byteInstruction(w, op, chunk, offset)
+	case OpList:
+		return shortInstruction(w, op, chunk, offset)
+	case OpJump, OpJumpIfFalse, OpTry:
+		return jumpInstruction(w, op, 1, chunk, offset)
+	case OpLoop:
+		return jumpInstruction(w, op, -1, chunk, offset)
+	case OpClosure:
+		return closureInstruction(w, chunk, offset)
+	case OpNil, OpTrue, OpFalse, OpPop, OpGetIndex, OpSetIndex,
+		OpEqual, OpNotEqual, OpGreater, OpGreaterEqual, OpLess, OpLessEqual,
+		OpAdd, OpSubtract, OpMultiply, OpDivide, OpNot, OpNegate, OpPrint,
+		OpCloseUpvalue, OpReturn, OpInherit, OpMap, OpMapAdd,
+		OpEndTry, OpCatch, OpThrow, OpRethrow:
+		return simpleInstruction(w, op, offset)
+	default:
+		fmt.Fprintf(w, "Unknown opcode %d\n", op)
+		return offset + 1
+	}
+}
+
+func simpleInstruction(w io.Writer, op OpCode, offset int) int {
+	fmt.Fprintln(w, op)
+	return offset + 1
+}
+
+func byteInstruction(w io.Writer, op OpCode, chunk *Chunk, offset int) int {
+	fmt.Fprintf(w, "%-16s %4d\n", op, chunk.Code[offset+1])
+	return offset + 2
+}
+
+func shortInstruction(w io.Writer, op OpCode, chunk *Chunk, offset int) int {
+	fmt.Fprintf(w, "%-16s %4d\n", op, readShort(chunk, offset+1))
+	return offset + 3
+}
+
+func constantInstruction(w io.Writer, op OpCode, chunk *Chunk, offset int) int {
+	constant := readShort(chunk, offset+1)
+	fmt.Fprintf(w, "%-16s %4d '%s'\n", op, constant, printValue(chunk.Constants[constant]))
+	return offset + 3
+}
+
+func jumpInstruction(w io.Writer, op OpCode, sign int, chunk *Chunk, offset int) int {
+	jump := readShort(chunk, offset+1)
+	fmt.Fprintf(w, "%-16s %4d -> %d\n", op, offset, offset+3+sign*jump)
+	return offset + 3
+}
+
+func closureInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	constant := readShort(chunk, offset+1)
+	function := chunk.Constants[constant].(*Function)
+	fmt.Fprintf(w, "%-16s %4d %s\n", OpClosure, constant, printValue(function))
+	offset += 3
+
+	for i := 0; i < function.UpvalueCount; i++ {
+		kind := "upvalue"
+		if chunk.Code[offset] == 1 {
+			kind = "local"
+		}
+		fmt.Fprintf(w, "%04d      |                     %s %d\n", offset, kind, chunk.Code[offset+1])
+		offset += 2
+	}
+	return offset
+}
+
+func readShort(chunk *Chunk, offset int) int {
+	return int(chunk.Code[offset])<<8 | int(chunk.Code[offset+1])
+}
+
+// printValue formats a constant the way clox's printValue does, which
+// unlike print doesn't quote strings.
+func printValue(value Value) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return stringify(value)
+}
@@ -0,0 +1,46 @@
+package bytecode
+
+import (
+	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// errorClass is the class of the objects that built-in runtime errors are
+// exposed as when caught by Lox code.
+var errorClass = newClass("Error")
+
+type RuntimeError struct {
+	Message string
+	Span    scanner.Span
+	// Trace holds the calls in progress when the error was raised,
+	// innermost last.
+	Trace []interpreter.Frame
+
+	// value is the Lox value passed to 'throw', if thrown is set.
+	value  Value
+	thrown bool
+
+	// limit is the execution limit that was exceeded, if any.
+	limit error
+}
+
+func (re *RuntimeError) Error() string {
+	return re.Message
+}
+
+func (re *RuntimeError) Unwrap() error {
+	return re.limit
+}
+
+// Value returns the value a catch clause binds for this error: the thrown
+// value itself, or an Error instance with 'message' and 'line' fields.
+func (re *RuntimeError) Value() Value {
+	if re.thrown {
+		return re.value
+	}
+
+	ins := newInstance(errorClass)
+	ins.fields["message"] = re.Message
+	ins.fields["line"] = float64(re.Span.Start.Line)
+	return ins
+}
@@ -0,0 +1,71 @@
+package bytecode
+
+import (
+	"errors"
+	"time"
+)
+
+func defineBuiltins() map[string]Value {
+	builtins := make(map[string]Value)
+	for _, n := range []*Native{
+		{name: "clock", arity: 0, fn: clock},
+		{name: "len", arity: 1, fn: length},
+		{name: "push", arity: 2, fn: push},
+		{name: "pop", arity: 1, fn: pop},
+		{name: "keys", arity: 1, fn: keys},
+	} {
+		builtins[n.name] = n
+	}
+	return builtins
+}
+
+func clock(vm *VM, args []Value) (Value, error) {
+	return time.Now(), nil
+}
+
+func length(vm *VM, args []Value) (Value, error) {
+	switch v := args[0].(type) {
+	case *List:
+		return float64(len(v.elements)), nil
+	case *Map:
+		return float64(len(v.keys)), nil
+	case string:
+		return float64(len(v)), nil
+	}
+	return nil, errors.New("Argument to 'len' must be a list, a map or a string.")
+}
+
+func push(vm *VM, args []Value) (Value, error) {
+	list, ok := args[0].(*List)
+	if !ok {
+		return nil, errors.New("First argument to 'push' must be a list.")
+	}
+
+	list.elements = append(list.elements, args[1])
+	return nil, nil
+}
+
+func pop(vm *VM, args []Value) (Value, error) {
+	list, ok := args[0].(*List)
+	if !ok {
+		return nil, errors.New("Argument to 'pop' must be a list.")
+	}
+	if len(list.elements) == 0 {
+		return nil, errors.New("Can't pop from an empty list.")
+	}
+
+	last := list.elements[len(list.elements)-1]
+	list.elements = list.elements[:len(list.elements)-1]
+	return last, nil
+}
+
+func keys(vm *VM, args []Value) (Value, error) {
+	m, ok := args[0].(*Map)
+	if !ok {
+		return nil, errors.New("Argument to 'keys' must be a map.")
+	}
+
+	keys := make([]Value, len(m.keys))
+	copy(keys, m.keys)
+	return &List{elements: keys}, nil
+}
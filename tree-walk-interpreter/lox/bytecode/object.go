@@ -0,0 +1,211 @@
+package bytecode
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+type FunctionKind int
+
+const (
+	KindScript FunctionKind = iota
+	KindFunction
+	KindMethod
+	KindInitializer
+)
+
+// Function is a compiled function. Name is empty for scripts and anonymous
+// functions.
+type Function struct {
+	Name         string
+	Kind         FunctionKind
+	Arity        int
+	UpvalueCount int
+	Chunk        Chunk
+}
+
+func (f *Function) String() string {
+	if f.Kind == KindScript {
+		return "<script>"
+	}
+	if f.Name == "" {
+		return "<fn anonymous>"
+	}
+	return "<fn " + f.Name + ">"
+}
+
+// Upvalue is a variable captured by a closure. While the variable is still
+// on the stack, the upvalue refers to its slot; once the variable goes out
+// of scope, the upvalue holds the value itself.
+type Upvalue struct {
+	slot   int
+	closed Value
+	open   bool
+	next   *Upvalue
+}
+
+type Closure struct {
+	function *Function
+	upvalues []*Upvalue
+	globals  *globals
+
+	// class is the class the closure is a method of, if it is one.
+	class *Class
+}
+
+func (c *Closure) String() string {
+	return c.function.String()
+}
+
+// globals holds the global variables of a script or module. Lookups that
+// miss fall back to the built-in functions.
+type globals struct {
+	vars     map[string]Value
+	builtins map[string]Value
+
+	// module is the module the globals belong to, or nil for the script.
+	module *Module
+}
+
+func newGlobals(builtins map[string]Value) *globals {
+	return &globals{vars: make(map[string]Value), builtins: builtins}
+}
+
+func (g *globals) get(name string) (Value, bool) {
+	if v, ok := g.vars[name]; ok {
+		return v, true
+	}
+	v, ok := g.builtins[name]
+	return v, ok
+}
+
+func (g *globals) set(name string, value Value) bool {
+	if _, ok := g.vars[name]; ok {
+		g.vars[name] = value
+		return true
+	}
+	if _, ok := g.builtins[name]; ok {
+		g.builtins[name] = value
+		return true
+	}
+	return false
+}
+
+type Class struct {
+	name    string
+	methods map[string]*Closure
+}
+
+func newClass(name string) *Class {
+	return &Class{name: name, methods: make(map[string]*Closure)}
+}
+
+func (c *Class) String() string {
+	return c.name
+}
+
+type Instance struct {
+	class  *Class
+	fields map[string]Value
+}
+
+func newInstance(class *Class) *Instance {
+	return &Instance{class: class, fields: make(map[string]Value)}
+}
+
+func (i *Instance) String() string {
+	return i.class.name + " instance"
+}
+
+type BoundMethod struct {
+	receiver *Instance
+	method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.method.String()
+}
+
+// Native is a built-in function. An error it returns is raised as a
+// runtime error at the call.
+type Native struct {
+	name  string
+	arity int
+	fn    func(vm *VM, args []Value) (Value, error)
+}
+
+func (n *Native) String() string {
+	return "<native fn>"
+}
+
+type List struct {
+	elements []Value
+}
+
+func (l *List) String() string {
+	elements := make([]string, 0, len(l.elements))
+	for _, e := range l.elements {
+		elements = append(elements, stringify(e))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (l *List) index(index Value) (int, string) {
+	n, ok := index.(float64)
+	if !ok || n != math.Trunc(n) {
+		return 0, "List index must be an integer."
+	}
+	if n < 0 || n >= float64(len(l.elements)) {
+		return 0, "List index out of range."
+	}
+	return int(n), ""
+}
+
+// Map keeps its keys in insertion order, like the tree walker's.
+type Map struct {
+	entries map[Value]Value
+	keys    []Value
+}
+
+func newMap() *Map {
+	return &Map{entries: make(map[Value]Value)}
+}
+
+func (m *Map) String() string {
+	entries := make([]string, 0, len(m.keys))
+	for _, k := range m.keys {
+		entries = append(entries, stringify(k)+": "+stringify(m.entries[k]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func (m *Map) set(key, value Value) {
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+}
+
+func isHashable(key Value) bool {
+	switch key.(type) {
+	case nil, bool, float64, string:
+		return true
+	}
+	return false
+}
+
+type Module struct {
+	name    string
+	path    string
+	globals *globals
+	loaded  bool
+}
+
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
+
+func (m *Module) dir() string {
+	return filepath.Dir(m.path)
+}
@@ -0,0 +1,46 @@
+package bytecode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Value is a Lox value: nil, bool, float64, string, or one of the objects
+// in object.go.
+type Value = interface{}
+
+func isFalsey(value Value) bool {
+	if value == nil {
+		return true
+	}
+	if b, ok := value.(bool); ok {
+		return !b
+	}
+	return false
+}
+
+func valuesEqual(a, b Value) bool {
+	return a == b
+}
+
+// stringify formats value as the print statement does. It matches the tree
+// walker's formatting exactly.
+func stringify(value Value) string {
+	if value == nil {
+		return "nil"
+	}
+
+	if v, ok := value.(float64); ok {
+		s := fmt.Sprintf("%f", v)
+		if strings.HasSuffix(s, ".000000") {
+			s = s[0 : len(s)-7]
+		}
+		return s
+	}
+
+	if s, ok := value.(interface{ String() string }); ok {
+		return s.String()
+	}
+
+	return fmt.Sprintf("%#v", value)
+}
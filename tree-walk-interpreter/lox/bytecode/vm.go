@@ -0,0 +1,773 @@
+package bytecode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fosmjo/lox/interpreter"
+)
+
+// defaultMaxCallDepth matches the tree-walk interpreter's default.
+const defaultMaxCallDepth = 10000
+
+type callFrame struct {
+	closure *Closure
+	ip      int
+	// slots is the index in the stack of the frame's slot zero.
+	slots int
+	// module is set for the frame running the top level of a module.
+	module *Module
+}
+
+// handler is the exception handler installed by a try statement.
+type handler struct {
+	frame int
+	stack int
+	ip    int
+}
+
+type VM struct {
+	stack        []Value
+	frames       []callFrame
+	handlers     []handler
+	openUpvalues *Upvalue
+
+	builtins map[string]Value
+	globals  *globals
+	modules  map[string]*Module
+	loader   interpreter.Loader
+
+	stdout       io.Writer
+	maxCallDepth int
+	maxSteps     int
+	steps        int
+	done         <-chan struct{}
+
+	// start is the offset of the instruction being executed.
+	start int
+}
+
+type Option func(*VM)
+
+func New(options ...Option) *VM {
+	builtins := defineBuiltins()
+	vm := &VM{
+		builtins:     builtins,
+		globals:      newGlobals(builtins),
+		modules:      make(map[string]*Module),
+		stdout:       os.Stdout,
+		maxCallDepth: defaultMaxCallDepth,
+	}
+
+	for _, option := range options {
+		option(vm)
+	}
+
+	return vm
+}
+
+// WithStdout sends the output of print statements to w instead of the
+// process's standard output.
+func WithStdout(w io.Writer) Option {
+	return func(vm *VM) {
+		vm.stdout = w
+	}
+}
+
+// WithLoader enables import statements, using loader to find and compile
+// the imported files.
+func WithLoader(loader interpreter.Loader) Option {
+	return func(vm *VM) {
+		vm.loader = loader
+	}
+}
+
+// WithMaxCallDepth limits how deeply calls may nest. A limit of zero or
+// less means no limit.
+func WithMaxCallDepth(n int) Option {
+	return func(vm *VM) {
+		vm.maxCallDepth = n
+	}
+}
+
+// WithMaxSteps limits the number of statements a single Run or Call may
+// run. A limit of zero or less means no limit, which is the default.
+func WithMaxSteps(n int) Option {
+	return func(vm *VM) {
+		vm.maxSteps = n
+	}
+}
+
+// Run runs a compiled script and returns the value it returns. Globals
+// persist from one run to the next. Running stops with an error once ctx
+// is done.
+func (vm *VM) Run(ctx context.Context, script *Function) (Value, error) {
+	vm.reset(ctx)
+
+	closure := &Closure{function: script, globals: vm.globals}
+	vm.push(closure)
+	vm.frames = append(vm.frames, callFrame{closure: closure})
+	return vm.run()
+}
+
+// RunFile is like Run, for the script compiled from the file at the
+// absolute path. The script counts as a module being loaded while it runs,
+// so that a module importing it back is reported as an import cycle
+// instead of running it a second time.
+func (vm *VM) RunFile(ctx context.Context, path string, script *Function) (Value, error) {
+	module := &Module{name: moduleName(path), path: path, globals: vm.globals}
+	vm.modules[path] = module
+	value, err := vm.Run(ctx, script)
+	if err != nil {
+		delete(vm.modules, path)
+		return nil, err
+	}
+	module.loaded = true
+	return value, nil
+}
+
+// Call calls callee with arguments from outside of any script, as a host
+// program does.
+func (vm *VM) Call(ctx context.Context, callee Value, arguments []Value) (Value, error) {
+	vm.reset(ctx)
+
+	vm.push(callee)
+	for _, arg := range arguments {
+		vm.push(arg)
+	}
+	if err := vm.callValue(callee, len(arguments)); err != nil {
+		err.Trace = vm.traceback(0)
+		return nil, err
+	}
+	if len(vm.frames) == 0 {
+		return vm.pop(), nil
+	}
+	return vm.run()
+}
+
+func (vm *VM) SetGlobal(name string, value Value) {
+	vm.globals.vars[name] = value
+}
+
+func (vm *VM) GetGlobal(name string) (Value, bool) {
+	return vm.globals.get(name)
+}
+
+func (vm *VM) reset(ctx context.Context) {
+	vm.stack = vm.stack[:0]
+	vm.frames = vm.frames[:0]
+	vm.handlers = vm.handlers[:0]
+	vm.openUpvalues = nil
+	vm.steps = 0
+	vm.done = ctx.Done()
+}
+
+func (vm *VM) run() (Value, error) {
+	for {
+		frame := &vm.frames[len(vm.frames)-1]
+		chunk := &frame.closure.function.Chunk
+
+		vm.start = frame.ip
+		op := OpCode(chunk.Code[frame.ip])
+		frame.ip++
+
+		if vm.maxSteps > 0 && chunk.Steps[vm.start] {
+			if err := vm.step(); err != nil {
+				vm.throw(err)
+				vm.closeUpvalues(0)
+				return nil, err
+			}
+		}
+
+		var err *RuntimeError
+		switch op {
+		case OpConstant:
+			vm.push(chunk.Constants[vm.readShort(frame)])
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+		case OpGetLocal:
+			slot := vm.readByte(frame)
+			vm.push(vm.stack[frame.slots+int(slot)])
+		case OpSetLocal:
+			slot := vm.readByte(frame)
+			vm.stack[frame.slots+int(slot)] = vm.peek(0)
+		case OpGetGlobal:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			value, ok := frame.closure.globals.get(name)
+			if !ok {
+				err = vm.error("Undefined variable '" + name + "'.")
+				break
+			}
+			vm.push(value)
+		case OpDefineGlobal:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			frame.closure.globals.vars[name] = vm.pop()
+		case OpSetGlobal:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			if !frame.closure.globals.set(name, vm.peek(0)) {
+				err = vm.error("Undefined variable '" + name + "'.")
+			}
+		case OpGetUpvalue:
+			uv := frame.closure.upvalues[vm.readByte(frame)]
+			if uv.open {
+				vm.push(vm.stack[uv.slot])
+			} else {
+				vm.push(uv.closed)
+			}
+		case OpSetUpvalue:
+			uv := frame.closure.upvalues[vm.readByte(frame)]
+			if uv.open {
+				vm.stack[uv.slot] = vm.peek(0)
+			} else {
+				uv.closed = vm.peek(0)
+			}
+		case OpGetProperty:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			err = vm.getProperty(name)
+		case OpSetProperty:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			instance, ok := vm.peek(1).(*Instance)
+			if !ok {
+				err = vm.error("Only instances have fields")
+				break
+			}
+			value := vm.pop()
+			instance.fields[name] = value
+			vm.pop()
+			vm.push(value)
+		case OpGetSuper:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			superclass := vm.pop().(*Class)
+			method, ok := superclass.methods[name]
+			if !ok {
+				err = vm.error("Undefined property '" + name + "'.")
+				break
+			}
+			receiver := vm.pop().(*Instance)
+			vm.push(&BoundMethod{receiver: receiver, method: method})
+		case OpGetIndex:
+			err = vm.getIndex()
+		case OpSetIndex:
+			err = vm.setIndex()
+		case OpEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(valuesEqual(a, b))
+		case OpNotEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(!valuesEqual(a, b))
+		case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpSubtract, OpMultiply, OpDivide:
+			err = vm.binaryOp(op)
+		case OpAdd:
+			switch b := vm.peek(0).(type) {
+			case float64:
+				if a, ok := vm.peek(1).(float64); ok {
+					vm.stack = vm.stack[:len(vm.stack)-2]
+					vm.push(a + b)
+					continue
+				}
+			case string:
+				if a, ok := vm.peek(1).(string); ok {
+					vm.stack = vm.stack[:len(vm.stack)-2]
+					vm.push(a + b)
+					continue
+				}
+			}
+			err = vm.error("Operands must be two numbers or two strings.")
+		case OpNot:
+			vm.push(isFalsey(vm.pop()))
+		case OpNegate:
+			n, ok := vm.peek(0).(float64)
+			if !ok {
+				err = vm.error("Operand must be a number.")
+				break
+			}
+			vm.stack[len(vm.stack)-1] = -n
+		case OpPrint:
+			fmt.Fprintln(vm.stdout, stringify(vm.pop()))
+		case OpJump:
+			offset := vm.readShort(frame)
+			frame.ip += offset
+		case OpJumpIfFalse:
+			offset := vm.readShort(frame)
+			if isFalsey(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case OpLoop:
+			offset := vm.readShort(frame)
+			frame.ip -= offset
+			err = vm.checkCancelled()
+		case OpCall:
+			argCount := int(vm.readByte(frame))
+			err = vm.callValue(vm.peek(argCount), argCount)
+		case OpClosure:
+			function := chunk.Constants[vm.readShort(frame)].(*Function)
+			closure := &Closure{
+				function: function,
+				upvalues: make([]*Upvalue, function.UpvalueCount),
+				globals:  frame.closure.globals,
+			}
+			for i := range closure.upvalues {
+				isLocal := vm.readByte(frame)
+				index := int(vm.readByte(frame))
+				if isLocal == 1 {
+					closure.upvalues[i] = vm.captureUpvalue(frame.slots + index)
+				} else {
+					closure.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.slots)
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.stack = vm.stack[:frame.slots]
+
+			if frame.module != nil {
+				frame.module.loaded = true
+				result = frame.module
+			}
+			if len(vm.frames) == 0 {
+				return result, nil
+			}
+			vm.push(result)
+		case OpClass:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			vm.push(newClass(name))
+		case OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				err = vm.error("Superclass must be a class.")
+				break
+			}
+			subclass := vm.pop().(*Class)
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+		case OpMethod:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			method := vm.pop().(*Closure)
+			class := vm.peek(0).(*Class)
+			method.class = class
+			class.methods[name] = method
+		case OpList:
+			n := vm.readShort(frame)
+			elements := make([]Value, n)
+			copy(elements, vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(&List{elements: elements})
+		case OpMap:
+			vm.push(newMap())
+		case OpMapAdd:
+			key := vm.peek(1)
+			if !isHashable(key) {
+				err = vm.error("Map key must be a number, string, boolean or nil.")
+				break
+			}
+			value := vm.pop()
+			vm.pop()
+			vm.peek(0).(*Map).set(key, value)
+		case OpImport:
+			path := chunk.Constants[vm.readShort(frame)].(string)
+			err = vm.importModule(path)
+		case OpImportName:
+			name := chunk.Constants[vm.readShort(frame)].(string)
+			module := vm.peek(0).(*Module)
+			value, ok := module.globals.vars[name]
+			if !ok {
+				err = vm.error("Module '" + module.name + "' has no export '" + name + "'.")
+				break
+			}
+			vm.stack[len(vm.stack)-1] = value
+		case OpTry:
+			offset := vm.readShort(frame)
+			vm.handlers = append(vm.handlers, handler{
+				frame: len(vm.frames) - 1,
+				stack: len(vm.stack),
+				ip:    frame.ip + offset,
+			})
+		case OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OpCatch:
+			vm.push(vm.pop().(*RuntimeError).Value())
+		case OpThrow:
+			value := vm.pop()
+			err = vm.error("Uncaught exception: " + stringify(value))
+			err.value, err.thrown = value, true
+		case OpRethrow:
+			err = vm.pop().(*RuntimeError)
+		default:
+			panic(fmt.Sprintf("unknown opcode %d", op))
+		}
+
+		if err != nil && !vm.throw(err) {
+			// Closures that outlive the run must not refer to the stack.
+			vm.closeUpvalues(0)
+			return nil, err
+		}
+	}
+}
+
+func (vm *VM) readByte(frame *callFrame) byte {
+	b := frame.closure.function.Chunk.Code[frame.ip]
+	frame.ip++
+	return b
+}
+
+func (vm *VM) readShort(frame *callFrame) int {
+	code := frame.closure.function.Chunk.Code
+	frame.ip += 2
+	return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+}
+
+func (vm *VM) push(value Value) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() Value {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) Value {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+func (vm *VM) binaryOp(op OpCode) *RuntimeError {
+	a, ok1 := vm.peek(1).(float64)
+	b, ok2 := vm.peek(0).(float64)
+	if !ok1 || !ok2 {
+		return vm.error("Operands must be numbers.")
+	}
+	if op == OpDivide && b == 0 {
+		return vm.error("Division by zero.")
+	}
+	vm.stack = vm.stack[:len(vm.stack)-2]
+
+	switch op {
+	case OpGreater:
+		vm.push(a > b)
+	case OpGreaterEqual:
+		vm.push(a >= b)
+	case OpLess:
+		vm.push(a < b)
+	case OpLessEqual:
+		vm.push(a <= b)
+	case OpSubtract:
+		vm.push(a - b)
+	case OpMultiply:
+		vm.push(a * b)
+	case OpDivide:
+		vm.push(a / b)
+	}
+	return nil
+}
+
+func (vm *VM) callValue(callee Value, argCount int) *RuntimeError {
+	var arity int
+	switch callee := callee.(type) {
+	case *Closure:
+		arity = callee.function.Arity
+	case *BoundMethod:
+		arity = callee.method.function.Arity
+	case *Class:
+		if initializer, ok := callee.methods["init"]; ok {
+			arity = initializer.function.Arity
+		}
+	case *Native:
+		arity = callee.arity
+	default:
+		return vm.error("Can only call functions and classes.")
+	}
+
+	if argCount != arity {
+		return vm.error(fmt.Sprintf("Expected %d arguments but got %d.", arity, argCount))
+	}
+	if vm.maxCallDepth > 0 && len(vm.frames)-1 >= vm.maxCallDepth {
+		err := vm.error("Stack overflow.")
+		err.limit = interpreter.ErrStackOverflow
+		return err
+	}
+
+	switch callee := callee.(type) {
+	case *Closure:
+		vm.call(callee, argCount)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.receiver
+		vm.call(callee.method, argCount)
+	case *Class:
+		vm.stack[len(vm.stack)-argCount-1] = newInstance(callee)
+		if initializer, ok := callee.methods["init"]; ok {
+			vm.call(initializer, argCount)
+		}
+	case *Native:
+		args := make([]Value, argCount)
+		copy(args, vm.stack[len(vm.stack)-argCount:])
+		result, err := callee.fn(vm, args)
+		if err != nil {
+			return vm.error(err.Error())
+		}
+		vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+		vm.push(result)
+	}
+	return vm.checkCancelled()
+}
+
+func (vm *VM) call(closure *Closure, argCount int) {
+	vm.frames = append(vm.frames, callFrame{
+		closure: closure,
+		slots:   len(vm.stack) - argCount - 1,
+	})
+}
+
+func (vm *VM) getProperty(name string) *RuntimeError {
+	switch object := vm.peek(0).(type) {
+	case *Instance:
+		if value, ok := object.fields[name]; ok {
+			vm.stack[len(vm.stack)-1] = value
+			return nil
+		}
+		if method, ok := object.class.methods[name]; ok {
+			vm.stack[len(vm.stack)-1] = &BoundMethod{receiver: object, method: method}
+			return nil
+		}
+		return vm.error("Undefined property '" + name + "'.")
+	case *Module:
+		if value, ok := object.globals.vars[name]; ok {
+			vm.stack[len(vm.stack)-1] = value
+			return nil
+		}
+		return vm.error("Module '" + object.name + "' has no export '" + name + "'.")
+	}
+	return vm.error("Only instances and modules have properties.")
+}
+
+func (vm *VM) getIndex() *RuntimeError {
+	index := vm.peek(0)
+	switch object := vm.peek(1).(type) {
+	case *List:
+		i, msg := object.index(index)
+		if msg != "" {
+			return vm.error(msg)
+		}
+		vm.stack = vm.stack[:len(vm.stack)-2]
+		vm.push(object.elements[i])
+		return nil
+	case *Map:
+		if !isHashable(index) {
+			return vm.error("Map key must be a number, string, boolean or nil.")
+		}
+		vm.stack = vm.stack[:len(vm.stack)-2]
+		vm.push(object.entries[index])
+		return nil
+	}
+	return vm.error("Only lists and maps can be indexed.")
+}
+
+func (vm *VM) setIndex() *RuntimeError {
+	value, index := vm.peek(0), vm.peek(1)
+	switch object := vm.peek(2).(type) {
+	case *List:
+		i, msg := object.index(index)
+		if msg != "" {
+			return vm.error(msg)
+		}
+		object.elements[i] = value
+	case *Map:
+		if !isHashable(index) {
+			return vm.error("Map key must be a number, string, boolean or nil.")
+		}
+		object.set(index, value)
+	default:
+		return vm.error("Only lists and maps can be indexed.")
+	}
+
+	vm.stack = vm.stack[:len(vm.stack)-3]
+	vm.push(value)
+	return nil
+}
+
+// importModule pushes the module at path, first calling its top level if
+// this is its first import.
+func (vm *VM) importModule(file string) *RuntimeError {
+	if vm.loader == nil {
+		return vm.error("Imports are not supported here.")
+	}
+
+	var dir string
+	if module := vm.frames[len(vm.frames)-1].closure.globals.module; module != nil {
+		dir = module.dir()
+	}
+
+	abs, err := vm.loader.Find(file, dir)
+	if err != nil {
+		return vm.error("Can't find module '" + file + "'.")
+	}
+
+	if module, ok := vm.modules[abs]; ok {
+		if !module.loaded {
+			return vm.error("Import cycle: module '" + file + "' is still being loaded.")
+		}
+		vm.push(module)
+		return nil
+	}
+
+	stmts, err := vm.loader.Load(abs)
+	if err != nil {
+		return vm.error("Can't load module '" + file + "': " + err.Error())
+	}
+	script, diags := Compile(stmts)
+	if diags.HasErrors() {
+		return vm.error("Can't load module '" + file + "': " + diags.Error())
+	}
+
+	module := &Module{name: moduleName(abs), path: abs, globals: newGlobals(vm.builtins)}
+	module.globals.module = module
+	vm.modules[abs] = module
+
+	closure := &Closure{function: script, globals: module.globals}
+	vm.push(closure)
+	vm.frames = append(vm.frames, callFrame{closure: closure, slots: len(vm.stack) - 1, module: module})
+	return nil
+}
+
+// moduleName returns the name of the module in the file at path.
+func moduleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// forgetModules forgets the modules whose top levels run in the frames
+// from first on, which are being unwound by an error, so that a later
+// import tries them again.
+func (vm *VM) forgetModules(first int) {
+	for _, f := range vm.frames[first:] {
+		if f.module != nil && !f.module.loaded {
+			delete(vm.modules, f.module.path)
+		}
+	}
+}
+
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	var prev *Upvalue
+	uv := vm.openUpvalues
+	for uv != nil && uv.slot > slot {
+		prev, uv = uv, uv.next
+	}
+	if uv != nil && uv.slot == slot {
+		return uv
+	}
+
+	created := &Upvalue{slot: slot, open: true, next: uv}
+	if prev == nil {
+		vm.openUpvalues = created
+	} else {
+		prev.next = created
+	}
+	return created
+}
+
+// closeUpvalues closes the upvalues of the stack slots from last up.
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= last {
+		uv := vm.openUpvalues
+		uv.closed = vm.stack[uv.slot]
+		uv.open = false
+		vm.openUpvalues = uv.next
+	}
+}
+
+// step counts the statement starting at the instruction being executed
+// against the step limit.
+func (vm *VM) step() *RuntimeError {
+	vm.steps++
+	if vm.steps > vm.maxSteps {
+		err := vm.error("Step limit exceeded.")
+		err.limit = interpreter.ErrStepLimit
+		return err
+	}
+	return nil
+}
+
+func (vm *VM) checkCancelled() *RuntimeError {
+	select {
+	case <-vm.done:
+		err := vm.error("Execution cancelled.")
+		err.limit = interpreter.ErrCancelled
+		return err
+	default:
+		return nil
+	}
+}
+
+// error returns a runtime error raised by the instruction being executed.
+func (vm *VM) error(msg string) *RuntimeError {
+	if len(vm.frames) == 0 {
+		return &RuntimeError{Message: msg}
+	}
+
+	chunk := &vm.frames[len(vm.frames)-1].closure.function.Chunk
+	return &RuntimeError{Message: msg, Span: chunk.Spans[vm.start]}
+}
+
+// throw unwinds to the innermost try statement's handler and reports
+// whether there was one. Exceeded execution limits can't be caught.
+func (vm *VM) throw(err *RuntimeError) bool {
+	if err.Trace == nil {
+		err.Trace = vm.traceback(err.Span.Start.Line)
+	}
+	if err.limit != nil || len(vm.handlers) == 0 {
+		vm.forgetModules(0)
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.forgetModules(h.frame + 1)
+	vm.frames = vm.frames[:h.frame+1]
+	vm.closeUpvalues(h.stack)
+	vm.stack = vm.stack[:h.stack]
+	vm.push(err)
+	vm.frames[h.frame].ip = h.ip
+	return true
+}
+
+// traceback returns the call stack, innermost frame last, for an error
+// raised on line. The top level of a module isn't a frame of its own, as in
+// the tree walker, so its lines are attributed to the frame importing it.
+func (vm *VM) traceback(line int) []interpreter.Frame {
+	trace := make([]interpreter.Frame, 0, len(vm.frames))
+	for i, f := range vm.frames {
+		frameLine := line
+		if i < len(vm.frames)-1 {
+			frameLine = f.closure.function.Chunk.Lines[f.ip-1]
+		}
+
+		if f.module != nil && len(trace) > 0 {
+			trace[len(trace)-1].Line = frameLine
+			continue
+		}
+
+		frame := interpreter.Frame{Line: frameLine, Function: f.closure.function.Name}
+		if f.closure.function.Kind == KindScript {
+			frame.Script = true
+		}
+		if f.closure.class != nil {
+			frame.Class = f.closure.class.name
+		}
+		trace = append(trace, frame)
+	}
+	return trace
+}
@@ -0,0 +1,580 @@
+// Package checker checks the types of Lox programs gradually. Where
+// annotations and literals make types known, it reports values of the
+// wrong type, operands that operators reject, calls with the wrong number
+// of arguments, and properties that annotated classes don't declare. Where
+// they don't, it assumes the best and leaves the interpreter to catch
+// mistakes as the program runs.
+//
+// It runs after the resolver, on programs that resolve without errors.
+package checker
+
+import (
+	"fmt"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+type checker struct {
+	globals map[string]Type
+	// scopes holds the types of the local variables in scope, innermost
+	// last.
+	scopes []map[string]Type
+
+	functions map[*parser.FunctionStmt]*function
+	classes   map[*parser.ClassStmt]*class
+	// reassigned holds the names that the program assigns to anywhere.
+	// Functions and classes of those names may hold anything by the time
+	// they are used, so their uses aren't checked.
+	reassigned map[string]bool
+
+	// result is the annotated result type of the function being checked,
+	// and this the type of 'this' in it.
+	result Type
+	this   *instance
+	// catching is set in the body of a try statement with a catch clause,
+	// where the program expects errors and handles them.
+	catching bool
+
+	diags diag.List
+}
+
+// Check checks stmts and returns the diagnostics for every problem found,
+// in order.
+func Check(stmts []parser.Stmt) diag.List {
+	// A first pass finds the names that are assigned to, since a function
+	// may be called before the assignment that replaces it is checked.
+	first := newChecker(make(map[string]bool))
+	first.checkProgram(stmts)
+	c := newChecker(first.reassigned)
+	c.checkProgram(stmts)
+	return c.diags
+}
+
+func newChecker(reassigned map[string]bool) *checker {
+	return &checker{
+		globals:    make(map[string]Type),
+		functions:  make(map[*parser.FunctionStmt]*function),
+		classes:    make(map[*parser.ClassStmt]*class),
+		reassigned: reassigned,
+	}
+}
+
+func (c *checker) checkProgram(stmts []parser.Stmt) {
+	// Functions and classes declared at the top level may be used before
+	// their declarations, from the bodies of functions.
+	var classes []*parser.ClassStmt
+	for _, stmt := range stmts {
+		if stmt, ok := stmt.(*parser.ClassStmt); ok {
+			c.declare(stmt.Name, c.newClass(stmt))
+			classes = append(classes, stmt)
+		}
+	}
+	for _, stmt := range classes {
+		c.defineClass(stmt)
+	}
+	for _, stmt := range stmts {
+		if stmt, ok := stmt.(*parser.FunctionStmt); ok {
+			c.declare(stmt.Name, c.signature(stmt))
+		}
+	}
+
+	c.checkStmts(stmts)
+}
+
+func (c *checker) checkStmts(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		_, _ = stmt.Accept(c)
+	}
+}
+
+// check returns the type of expr, reporting the problems in it.
+func (c *checker) check(expr parser.Expr) Type {
+	t, _ := expr.Accept(c)
+	return t.(Type)
+}
+
+func (c *checker) error(span scanner.Span, code diag.Code, msg string) {
+	if c.catching {
+		return
+	}
+	c.diags.Add(code, diag.Error, span, msg)
+}
+
+// expect reports a value of type got, at span, where one of type want is
+// expected.
+func (c *checker) expect(got, want Type, span scanner.Span) {
+	if !assignable(got, want) {
+		c.error(span, diag.TypeMismatch, fmt.Sprintf("Expected %s but got %s.", want, got))
+	}
+}
+
+func (c *checker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]Type))
+}
+
+func (c *checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *checker) declare(name scanner.Token, t Type) {
+	if len(c.scopes) == 0 {
+		c.globals[name.Lexeme] = t
+		return
+	}
+	c.scopes[len(c.scopes)-1][name.Lexeme] = t
+}
+
+// lookup returns the type of the variable name, which is anyType for the
+// globals that aren't declared, such as builtins and those defined by
+// earlier REPL lines.
+func (c *checker) lookup(name string) Type {
+	for j := len(c.scopes) - 1; j >= 0; j-- {
+		if t, ok := c.scopes[j][name]; ok {
+			return t
+		}
+	}
+	if t, ok := c.globals[name]; ok {
+		return t
+	}
+	return anyType
+}
+
+// assign changes the type of the variable name in the scope that declares
+// it.
+func (c *checker) assign(name string, t Type) {
+	for j := len(c.scopes) - 1; j >= 0; j-- {
+		if _, ok := c.scopes[j][name]; ok {
+			c.scopes[j][name] = t
+			return
+		}
+	}
+	if _, ok := c.globals[name]; ok {
+		c.globals[name] = t
+	}
+}
+
+// annotation returns the type an annotation names, or anyType if there is
+// none.
+func (c *checker) annotation(a *parser.Annotation) Type {
+	if a == nil {
+		return anyType
+	}
+	if t, ok := basics[a.Name.Lexeme]; ok {
+		return t
+	}
+	if k, ok := c.lookup(a.Name.Lexeme).(*class); ok {
+		return &instance{class: k}
+	}
+	c.error(a.Name.Span, diag.UnknownType, fmt.Sprintf("Unknown type '%s'.", a.Name.Lexeme))
+	return anyType
+}
+
+// signature returns the type of the function that stmt declares.
+func (c *checker) signature(stmt *parser.FunctionStmt) *function {
+	if f, ok := c.functions[stmt]; ok {
+		return f
+	}
+
+	f := &function{params: make([]Type, len(stmt.Params)), result: c.annotation(stmt.ReturnType)}
+	for j := range stmt.Params {
+		f.params[j] = c.annotation(stmt.ParamTypes[j])
+	}
+	c.functions[stmt] = f
+	return f
+}
+
+// checkFunction checks the body of the function stmt, which is a method of
+// this if this is set.
+func (c *checker) checkFunction(stmt *parser.FunctionStmt, this *instance) {
+	f := c.signature(stmt)
+	enclosingResult, enclosingThis, enclosingCatching := c.result, c.this, c.catching
+	c.result, c.this, c.catching = f.result, this, false
+
+	c.beginScope()
+	for j, param := range stmt.Params {
+		c.declare(param, f.params[j])
+	}
+	c.checkStmts(stmt.Body)
+	c.endScope()
+
+	c.result, c.this, c.catching = enclosingResult, enclosingThis, enclosingCatching
+}
+
+// newClass returns the type of the class stmt declares, whose members are
+// filled in by defineClass.
+func (c *checker) newClass(stmt *parser.ClassStmt) *class {
+	k := &class{
+		name:    stmt.Name.Lexeme,
+		fields:  make(map[string]Type),
+		methods: make(map[string]*function),
+	}
+	c.classes[stmt] = k
+	return k
+}
+
+func (c *checker) defineClass(stmt *parser.ClassStmt) {
+	k := c.classes[stmt]
+	if stmt.Superclass != nil {
+		k.super, _ = c.lookup(stmt.Superclass.Name.Lexeme).(*class)
+		k.unknownSuper = k.super == nil
+	}
+	for _, field := range stmt.Fields {
+		k.fields[field.Name.Lexeme] = c.annotation(field.Annotation)
+	}
+	for _, method := range stmt.Methods {
+		k.methods[method.Name.Lexeme] = c.signature(method)
+	}
+}
+
+// call checks a call, at paren, of a function of type f with arguments
+// of the types args.
+func (c *checker) call(f *function, paren scanner.Token, args []parser.Expr, types []Type) {
+	if len(args) != len(f.params) {
+		c.error(paren.Span, diag.ArityMismatch, fmt.Sprintf("Expected %d arguments but got %d.", len(f.params), len(args)))
+		return
+	}
+	for j, arg := range args {
+		c.expect(types[j], f.params[j], arg.Span())
+	}
+}
+
+func (c *checker) VisitBlockStmt(stmt *parser.BlockStmt) (interface{}, error) {
+	c.beginScope()
+	c.checkStmts(stmt.Statements)
+	c.endScope()
+	return nil, nil
+}
+
+func (c *checker) VisitBreakStmt(stmt *parser.BreakStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *checker) VisitClassStmt(stmt *parser.ClassStmt) (interface{}, error) {
+	k, ok := c.classes[stmt]
+	if !ok {
+		k = c.newClass(stmt)
+		c.defineClass(stmt)
+	}
+	c.declare(stmt.Name, k)
+
+	this := &instance{class: k}
+	for _, method := range stmt.Methods {
+		c.checkFunction(method, this)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitContinueStmt(stmt *parser.ContinueStmt) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *checker) VisitExpressionStmt(stmt *parser.ExpressionStmt) (interface{}, error) {
+	c.check(stmt.Expression)
+	return nil, nil
+}
+
+func (c *checker) VisitFunctionStmt(stmt *parser.FunctionStmt) (interface{}, error) {
+	c.declare(stmt.Name, c.signature(stmt))
+	c.checkFunction(stmt, nil)
+	return nil, nil
+}
+
+func (c *checker) VisitIfStmt(stmt *parser.IfStmt) (interface{}, error) {
+	c.check(stmt.Condition)
+	_, _ = stmt.ThenBranch.Accept(c)
+	if stmt.ElseBranch != nil {
+		_, _ = stmt.ElseBranch.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitImportStmt(stmt *parser.ImportStmt) (interface{}, error) {
+	if len(stmt.Names) == 0 {
+		c.declare(stmt.Alias, anyType)
+	}
+	for _, name := range stmt.Names {
+		c.declare(name, anyType)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitPrintStmt(stmt *parser.PrintStmt) (interface{}, error) {
+	c.check(stmt.Expression)
+	return nil, nil
+}
+
+func (c *checker) VisitReturnStmt(stmt *parser.ReturnStmt) (interface{}, error) {
+	if stmt.Value != nil {
+		t := c.check(stmt.Value)
+		if c.result != nil {
+			c.expect(t, c.result, stmt.Value.Span())
+		}
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitThrowStmt(stmt *parser.ThrowStmt) (interface{}, error) {
+	c.check(stmt.Value)
+	return nil, nil
+}
+
+func (c *checker) VisitTryStmt(stmt *parser.TryStmt) (interface{}, error) {
+	enclosingCatching := c.catching
+	c.catching = c.catching || stmt.CatchBody != nil
+	c.beginScope()
+	c.checkStmts(stmt.Body)
+	c.endScope()
+	c.catching = enclosingCatching
+
+	if stmt.CatchBody != nil {
+		c.beginScope()
+		c.declare(stmt.CatchName, anyType)
+		c.checkStmts(stmt.CatchBody)
+		c.endScope()
+	}
+
+	if stmt.FinallyBody != nil {
+		c.beginScope()
+		c.checkStmts(stmt.FinallyBody)
+		c.endScope()
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitVarStmt(stmt *parser.VarStmt) (interface{}, error) {
+	t := c.annotation(stmt.Annotation)
+	if stmt.Initializer != nil {
+		c.expect(c.check(stmt.Initializer), t, stmt.Initializer.Span())
+	}
+	c.declare(stmt.Name, t)
+	return nil, nil
+}
+
+func (c *checker) VisitWhileStmt(stmt *parser.WhileStmt) (interface{}, error) {
+	c.check(stmt.Condition)
+	_, _ = stmt.Body.Accept(c)
+	if stmt.Increment != nil {
+		c.check(stmt.Increment)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, error) {
+	t := c.check(expr.Value)
+	c.reassigned[expr.Name.Lexeme] = true
+	switch want := c.lookup(expr.Name.Lexeme).(type) {
+	case *function, *class:
+		// Functions and classes may be redefined with anything, after which
+		// nothing is known about the name.
+		c.assign(expr.Name.Lexeme, anyType)
+	default:
+		c.expect(t, want, expr.Value.Span())
+	}
+	return t, nil
+}
+
+func (c *checker) VisitBinaryExpr(expr *parser.BinaryExpr) (interface{}, error) {
+	left, right := c.check(expr.Left), c.check(expr.Right)
+
+	switch expr.Operator.Type {
+	case scanner.EQUAL_EQUAL, scanner.BANG_EQUAL:
+		return boolType, nil
+	case scanner.PLUS:
+		if left == anyType || right == anyType {
+			for _, t := range []Type{left, right} {
+				if t != anyType && t != numberType && t != stringType {
+					c.error(expr.Operator.Span, diag.InvalidOperand, "Operands must be two numbers or two strings.")
+					return anyType, nil
+				}
+			}
+			if left == anyType {
+				return right, nil
+			}
+			return left, nil
+		}
+		if left != right || left != numberType && left != stringType {
+			c.error(expr.Operator.Span, diag.InvalidOperand, "Operands must be two numbers or two strings.")
+			return anyType, nil
+		}
+		return left, nil
+	}
+
+	if !assignableOperand(left) || !assignableOperand(right) {
+		c.error(expr.Operator.Span, diag.InvalidOperand, "Operands must be numbers.")
+	}
+	switch expr.Operator.Type {
+	case scanner.GREATER, scanner.GREATER_EQUAL, scanner.LESS, scanner.LESS_EQUAL:
+		return boolType, nil
+	}
+	return numberType, nil
+}
+
+// assignableOperand reports whether a value of type t may be a number, as
+// arithmetic and comparison need. Unlike assignable, it rejects nil.
+func assignableOperand(t Type) bool {
+	return t == anyType || t == numberType
+}
+
+func (c *checker) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
+	callee := c.check(expr.Callee)
+	types := make([]Type, len(expr.Arguments))
+	for j, arg := range expr.Arguments {
+		types[j] = c.check(arg)
+	}
+
+	switch callee := callee.(type) {
+	case *function:
+		c.call(callee, expr.Paren, expr.Arguments, types)
+		return callee.result, nil
+	case *class:
+		if init, ok := callee.method("init"); ok {
+			c.call(init, expr.Paren, expr.Arguments, types)
+		} else if callee.annotated() || callee.super == nil && !callee.unknownSuper {
+			c.call(&function{}, expr.Paren, expr.Arguments, types)
+		}
+		return &instance{class: callee}, nil
+	case basic:
+		if callee == anyType || callee == functionType {
+			return anyType, nil
+		}
+	}
+	c.error(expr.Paren.Span, diag.NotCallable, "Can only call functions and classes.")
+	return anyType, nil
+}
+
+func (c *checker) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
+	object := c.check(expr.Object)
+	return c.property(object, expr.Name, "Only instances and modules have properties."), nil
+}
+
+// property returns the type of the property name of a value of type
+// object, reporting notInstance if the value can't have properties.
+func (c *checker) property(object Type, name scanner.Token, notInstance string) Type {
+	switch object := object.(type) {
+	case *instance:
+		if t, ok := object.class.property(name.Lexeme); ok {
+			return t
+		}
+		if object.class.annotated() {
+			c.error(name.Span, diag.UndefinedProperty, fmt.Sprintf("Undefined property '%s' on %s.", name.Lexeme, object))
+		}
+		return anyType
+	case basic:
+		if object == anyType {
+			return anyType
+		}
+	}
+	c.error(name.Span, diag.NotAnInstance, notInstance)
+	return anyType
+}
+
+func (c *checker) VisitGroupingExpr(expr *parser.GroupingExpr) (interface{}, error) {
+	return c.check(expr.Expression), nil
+}
+
+func (c *checker) VisitIndexExpr(expr *parser.IndexExpr) (interface{}, error) {
+	c.check(expr.Object)
+	c.check(expr.Index)
+	return anyType, nil
+}
+
+func (c *checker) VisitIndexSetExpr(expr *parser.IndexSetExpr) (interface{}, error) {
+	c.check(expr.Object)
+	c.check(expr.Index)
+	return c.check(expr.Value), nil
+}
+
+func (c *checker) VisitLambdaExpr(expr *parser.LambdaExpr) (interface{}, error) {
+	c.checkFunction(expr.Declaration, c.this)
+	return c.signature(expr.Declaration), nil
+}
+
+func (c *checker) VisitListExpr(expr *parser.ListExpr) (interface{}, error) {
+	for _, element := range expr.Elements {
+		c.check(element)
+	}
+	return listType, nil
+}
+
+func (c *checker) VisitLiteralExpr(expr *parser.LiteralExpr) (interface{}, error) {
+	switch expr.Value.(type) {
+	case nil:
+		return nilType, nil
+	case bool:
+		return boolType, nil
+	case float64:
+		return numberType, nil
+	case string:
+		return stringType, nil
+	}
+	return anyType, nil
+}
+
+func (c *checker) VisitLogicalExpr(expr *parser.LogicalExpr) (interface{}, error) {
+	left, right := c.check(expr.Left), c.check(expr.Right)
+	if left == right {
+		return left, nil
+	}
+	return anyType, nil
+}
+
+func (c *checker) VisitMapExpr(expr *parser.MapExpr) (interface{}, error) {
+	for j := range expr.Keys {
+		c.check(expr.Keys[j])
+		c.check(expr.Values[j])
+	}
+	return mapType, nil
+}
+
+func (c *checker) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
+	t := c.check(expr.Value)
+	object := c.check(expr.Object)
+	if object, ok := object.(*instance); ok {
+		if _, ok := object.class.method(expr.Name.Lexeme); ok {
+			// A field may hide a method with any value.
+			return t, nil
+		}
+	}
+	c.expect(t, c.property(object, expr.Name, "Only instances have fields."), expr.Value.Span())
+	return t, nil
+}
+
+func (c *checker) VisitSuperExpr(expr *parser.SuperExpr) (interface{}, error) {
+	if c.this != nil && c.this.class.super != nil {
+		if method, ok := c.this.class.super.method(expr.Method.Lexeme); ok {
+			return method, nil
+		}
+	}
+	return anyType, nil
+}
+
+func (c *checker) VisitThisExpr(expr *parser.ThisExpr) (interface{}, error) {
+	if c.this == nil {
+		return anyType, nil
+	}
+	return c.this, nil
+}
+
+func (c *checker) VisitUnaryExpr(expr *parser.UnaryExpr) (interface{}, error) {
+	right := c.check(expr.Right)
+	if expr.Operator.Type == scanner.BANG {
+		return boolType, nil
+	}
+	if !assignableOperand(right) {
+		c.error(expr.Operator.Span, diag.InvalidOperand, "Operand must be a number.")
+	}
+	return numberType, nil
+}
+
+func (c *checker) VisitVariableExpr(expr *parser.VariableExpr) (interface{}, error) {
+	t := c.lookup(expr.Name.Lexeme)
+	switch t.(type) {
+	case *function, *class:
+		if c.reassigned[expr.Name.Lexeme] {
+			return anyType, nil
+		}
+	}
+	return t, nil
+}
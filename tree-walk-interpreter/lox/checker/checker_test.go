@@ -0,0 +1,64 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/resolver"
+	"github.com/fosmjo/lox/scanner"
+)
+
+func check(t *testing.T, src string) diag.List {
+	t.Helper()
+	tokens := scanner.New(src).ScanTokens()
+	stmts, diags := parser.NewParser(tokens).Parse()
+	diags = append(diags, resolver.NewResolver(nil).Resolve(stmts)...)
+	if diags.HasErrors() {
+		t.Fatalf("%q doesn't compile: %v", src, diags)
+	}
+	return Check(stmts)
+}
+
+// TestUntypedPrograms checks programs without annotations that run without
+// errors, which the checker mustn't reject.
+func TestUntypedPrograms(t *testing.T) {
+	for _, src := range []string{
+		`fun f(a) {} f = fun (a, b) { return a + b; }; print f(1, 2);`,
+		`{ fun f(a) {} f = fun (a, b) { return a + b; }; print f(1, 2); }`,
+		`class K {} K = 3; print K + 1;`,
+		`fun g() { print f(1, 2); } fun f(a) {} f = fun (a, b) { return a + b; }; g();`,
+		`try { print nil + 1; } catch (e) { print e; }`,
+		`var x = 1; x = "one"; print x + "!";`,
+	} {
+		if diags := check(t, src); len(diags) > 0 {
+			t.Errorf("%s: unexpected %v", src, diags)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		code diag.Code
+	}{
+		{`fun f(a) {} f(1, 2);`, diag.ArityMismatch},
+		{`class K { init(a) {} } K();`, diag.ArityMismatch},
+		{`var x: number = "one";`, diag.TypeMismatch},
+		{`fun f(a: string): number { return a; }`, diag.TypeMismatch},
+		{`fun f(a: string) {} f(1);`, diag.TypeMismatch},
+		{`class P { x: number; } var p: P = P(); print p.y;`, diag.UndefinedProperty},
+		{`class P { x: number; } P().x = "one";`, diag.TypeMismatch},
+		{`print -"a";`, diag.InvalidOperand},
+		{`print 1 + "a";`, diag.InvalidOperand},
+		{`class K {} print K + 1;`, diag.InvalidOperand},
+		{`print "a"();`, diag.NotCallable},
+		{`print nil.x;`, diag.NotAnInstance},
+		{`var x: Nope;`, diag.UnknownType},
+	} {
+		diags := check(t, tt.src)
+		if len(diags) != 1 || diags[0].Code != tt.code {
+			t.Errorf("%s: got %v, want one %s", tt.src, diags, tt.code)
+		}
+	}
+}
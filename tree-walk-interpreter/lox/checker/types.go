@@ -0,0 +1,142 @@
+package checker
+
+// Type is the static type of a value.
+type Type interface {
+	String() string
+}
+
+// basic is a type that annotations name directly.
+type basic string
+
+func (b basic) String() string {
+	return string(b)
+}
+
+const (
+	// anyType is the type of values whose type isn't known, which the
+	// checker assumes are of whatever type they need to be.
+	anyType      basic = "any"
+	nilType      basic = "nil"
+	boolType     basic = "bool"
+	numberType   basic = "number"
+	stringType   basic = "string"
+	listType     basic = "list"
+	mapType      basic = "map"
+	functionType basic = "function"
+)
+
+var basics = map[string]basic{
+	"any":      anyType,
+	"nil":      nilType,
+	"bool":     boolType,
+	"number":   numberType,
+	"string":   stringType,
+	"list":     listType,
+	"map":      mapType,
+	"function": functionType,
+}
+
+// function is the type of a function whose declaration is known.
+type function struct {
+	params []Type
+	// result is the type the function returns, or anyType if it isn't
+	// annotated.
+	result Type
+}
+
+func (f *function) String() string {
+	return "function"
+}
+
+// class is the type of a class whose declaration is known.
+type class struct {
+	name string
+	// super is the superclass, if it is known. unknownSuper is set if the
+	// class has a superclass whose type isn't known.
+	super        *class
+	unknownSuper bool
+	fields       map[string]Type
+	methods      map[string]*function
+}
+
+func (c *class) String() string {
+	return "class " + c.name
+}
+
+// annotated reports whether c and its superclasses are all known and one
+// of them declares fields, in which case their instances have no other
+// properties.
+func (c *class) annotated() bool {
+	declares := false
+	for ; c != nil; c = c.super {
+		if c.unknownSuper {
+			return false
+		}
+		declares = declares || len(c.fields) > 0
+	}
+	return declares
+}
+
+// property returns the type of the field or method name of c's instances.
+func (c *class) property(name string) (Type, bool) {
+	for k := c; k != nil; k = k.super {
+		if t, ok := k.fields[name]; ok {
+			return t, true
+		}
+	}
+	if method, ok := c.method(name); ok {
+		return method, true
+	}
+	return nil, false
+}
+
+func (c *class) method(name string) (*function, bool) {
+	for ; c != nil; c = c.super {
+		if method, ok := c.methods[name]; ok {
+			return method, true
+		}
+	}
+	return nil, false
+}
+
+// subclassOf reports whether c may be other or a subclass of it.
+func (c *class) subclassOf(other *class) bool {
+	for ; c != nil; c = c.super {
+		if c == other || c.unknownSuper {
+			return true
+		}
+	}
+	return false
+}
+
+// instance is the type of the instances of a class and its subclasses.
+type instance struct {
+	class *class
+}
+
+func (i *instance) String() string {
+	return i.class.name
+}
+
+// assignable reports whether a value of type got may be used where one of
+// type want is expected. nil may be used anywhere, as that is what
+// variables hold before they are assigned.
+func assignable(got, want Type) bool {
+	if want == anyType || got == anyType || got == nilType {
+		return true
+	}
+
+	switch want := want.(type) {
+	case *instance:
+		got, ok := got.(*instance)
+		return ok && got.class.subclassOf(want.class)
+	case basic:
+		if want == functionType {
+			switch got.(type) {
+			case *function, *class:
+				return true
+			}
+		}
+	}
+	return got == want
+}
@@ -0,0 +1,154 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/fosmjo/lox/internal/framing"
+)
+
+// Message is the part common to every Debug Adapter Protocol message.
+type Message struct {
+	Seq  int    `json:"seq"`
+	Type string `json:"type"`
+}
+
+// Request is a request from the client.
+type Request struct {
+	Message
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// Response answers a request.
+type Response struct {
+	Message
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	ErrMessage string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// Event is sent to the client unprompted.
+type Event struct {
+	Message
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// The types that follow are those of the protocol's specification, less the
+// fields this server doesn't use.
+
+type Source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type SourceBreakpoint struct {
+	Line int `json:"line"`
+}
+
+type Breakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line"`
+}
+
+type StackFrame struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Source *Source `json:"source,omitempty"`
+	Line   int     `json:"line"`
+	Column int     `json:"column"`
+}
+
+type Scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type LaunchArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+	NoDebug     bool   `json:"noDebug"`
+}
+
+type SetBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+type StackTraceArguments struct {
+	ThreadID   int `json:"threadId"`
+	StartFrame int `json:"startFrame"`
+	Levels     int `json:"levels"`
+}
+
+type ScopesArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+type VariablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type EvaluateArguments struct {
+	Expression string `json:"expression"`
+	FrameID    int    `json:"frameId"`
+}
+
+// Conn reads and writes messages framed by Content-Length headers, as
+// both ends of the protocol do. Writes may come from several goroutines.
+type Conn struct {
+	r *framing.Reader
+
+	mu  sync.Mutex
+	w   io.Writer
+	seq int
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{r: framing.NewReader(r), w: w}
+}
+
+// Read reads the body of the next message.
+func (c *Conn) Read() ([]byte, error) {
+	return c.r.Read()
+}
+
+// Write numbers msg, which must be a *Request, *Response or *Event, and
+// sends it.
+func (c *Conn) Write(msg interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	switch msg := msg.(type) {
+	case *Request:
+		msg.Seq, msg.Type = c.seq, "request"
+	case *Response:
+		msg.Seq, msg.Type = c.seq, "response"
+	case *Event:
+		msg.Seq, msg.Type = c.seq, "event"
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return framing.Write(c.w, body)
+}
@@ -0,0 +1,473 @@
+// Package dap serves the Debug Adapter Protocol over a pair of streams, so
+// that editors can debug scripts run by the tree-walk interpreter.
+package dap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fosmjo/lox/debugger"
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/lox"
+)
+
+// threadID is the ID of the only thread a Lox script has.
+const threadID = 1
+
+// Server debugs one script for one client.
+type Server struct {
+	conn        *Conn
+	searchPaths []string
+	debugger    *debugger.Debugger
+
+	program     string
+	source      string
+	stopOnEntry bool
+	noDebug     bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// done is closed once the script has finished. quit makes sure it
+	// is only stopped once.
+	done chan struct{}
+	quit sync.Once
+
+	mu   sync.Mutex
+	stop *debugger.Stop
+	// refs holds what variable references refer to while the script is
+	// paused. Reference n is refs[n-1].
+	refs   []reference
+	resume chan struct{}
+}
+
+// reference is a scope of a frame, or a value with members.
+type reference struct {
+	scope string
+	frame int
+	value interface{}
+}
+
+const (
+	localsScope  = "Locals"
+	globalsScope = "Globals"
+)
+
+// NewServer returns a server that reads requests from r and writes
+// responses and events to w. Imports are looked up in the directory of the
+// script and then in searchPaths.
+func NewServer(r io.Reader, w io.Writer, searchPaths []string) *Server {
+	s := &Server{
+		conn:        NewConn(r, w),
+		searchPaths: searchPaths,
+		resume:      make(chan struct{}),
+	}
+	s.debugger = debugger.New(s)
+	return s
+}
+
+// Serve handles requests until the client disconnects or r runs out.
+func (s *Server) Serve() error {
+	defer s.shutdown()
+
+	for {
+		body, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("dap: %v", err)
+		}
+		if req.Command == "disconnect" {
+			s.shutdown()
+			s.respond(&req, nil, nil)
+			return nil
+		}
+		s.handle(&req)
+	}
+}
+
+func (s *Server) handle(req *Request) {
+	var body interface{}
+	var err error
+
+	switch req.Command {
+	case "initialize":
+		body = map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}
+	case "launch":
+		if err = s.launch(req.Arguments); err == nil {
+			s.respond(req, nil, nil)
+			s.send("initialized", nil)
+			return
+		}
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(req.Arguments)
+	case "configurationDone":
+		if err = s.start(); err == nil {
+			s.respond(req, nil, nil)
+			go s.run()
+			return
+		}
+	case "threads":
+		body = map[string][]Thread{"threads": {{ID: threadID, Name: "main"}}}
+	case "continue", "next", "stepIn", "stepOut":
+		if _, err = s.paused(); err == nil {
+			s.respond(req, map[string]bool{"allThreadsContinued": true}, nil)
+			s.step(req.Command)
+			return
+		}
+	case "stackTrace":
+		body, err = s.stackTrace()
+	case "scopes":
+		body, err = s.scopes(req.Arguments)
+	case "variables":
+		body, err = s.variables(req.Arguments)
+	case "evaluate":
+		body, err = s.evaluate(req.Arguments)
+	case "terminate":
+		s.shutdown()
+	default:
+		err = fmt.Errorf("Unsupported request '%s'.", req.Command)
+	}
+
+	s.respond(req, body, err)
+}
+
+func (s *Server) respond(req *Request, body interface{}, err error) {
+	resp := &Response{RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.ErrMessage = err.Error()
+	}
+	s.conn.Write(resp)
+}
+
+func (s *Server) send(event string, body interface{}) {
+	s.conn.Write(&Event{Event: event, Body: body})
+}
+
+func (s *Server) launch(raw json.RawMessage) error {
+	var args LaunchArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return err
+	}
+	if args.Program == "" {
+		return errors.New("No program to launch.")
+	}
+
+	data, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	s.program = args.Program
+	s.source = string(data)
+	s.stopOnEntry = args.StopOnEntry
+	s.noDebug = args.NoDebug
+	return nil
+}
+
+func (s *Server) setBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var args SetBreakpointsArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, bp := range s.debugger.Breakpoints() {
+		if bp.File == path {
+			s.debugger.ClearBreakpoint(bp.File, bp.Line)
+		}
+	}
+
+	bps := make([]Breakpoint, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		err := s.debugger.SetBreakpoint(path, bp.Line)
+		bps = append(bps, Breakpoint{Verified: err == nil, Line: bp.Line})
+	}
+	return map[string][]Breakpoint{"breakpoints": bps}, nil
+}
+
+func (s *Server) start() error {
+	if s.program == "" {
+		return errors.New("No program has been launched.")
+	}
+	if s.done != nil {
+		return errors.New("The program is already running.")
+	}
+	s.done = make(chan struct{})
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return nil
+}
+
+// run runs the script and reports its exit.
+func (s *Server) run() {
+	defer close(s.done)
+
+	opts := lox.Options{
+		Stdout:      output{s, "stdout"},
+		Errors:      errorSink{s},
+		SearchPaths: append([]string{filepath.Dir(s.program)}, s.searchPaths...),
+	}
+	if !s.noDebug {
+		opts.Hook = s.debugger
+	}
+
+	vm, err := lox.New(opts)
+	if err == nil {
+		_, err = vm.EvalSource(s.ctx, s.program, s.source)
+	}
+	exitCode := 0
+	switch err.(type) {
+	case nil:
+	case *lox.CompileError:
+		exitCode = 65
+	case *lox.RuntimeError:
+		exitCode = 70
+	default:
+		s.send("output", map[string]string{"category": "stderr", "output": err.Error() + "\n"})
+		exitCode = 1
+	}
+
+	s.send("exited", map[string]int{"exitCode": exitCode})
+	s.send("terminated", nil)
+}
+
+// shutdown stops the script, if it is running, and waits for it to finish.
+func (s *Server) shutdown() {
+	s.quit.Do(func() {
+		close(s.resume)
+		if s.done != nil {
+			s.cancel()
+			<-s.done
+		}
+
+		s.mu.Lock()
+		s.stop = nil
+		s.mu.Unlock()
+	})
+}
+
+// Paused implements debugger.Handler. The script stays paused until a
+// request resumes it, while other requests inspect it.
+func (s *Server) Paused(stop *debugger.Stop) error {
+	if stop.Reason == debugger.ReasonEntry && !s.stopOnEntry {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.stop = stop
+	s.mu.Unlock()
+
+	s.send("stopped", map[string]interface{}{
+		"reason":            string(stop.Reason),
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	if _, ok := <-s.resume; !ok {
+		return debugger.ErrQuit
+	}
+	return nil
+}
+
+func (s *Server) paused() (*debugger.Stop, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return nil, errors.New("The program isn't paused.")
+	}
+	return s.stop, nil
+}
+
+// step resumes the paused script as command says.
+func (s *Server) step(command string) {
+	switch command {
+	case "continue":
+		s.debugger.Continue()
+	case "next":
+		s.debugger.StepOver()
+	case "stepIn":
+		s.debugger.StepIn()
+	case "stepOut":
+		s.debugger.StepOut()
+	}
+
+	s.mu.Lock()
+	s.stop = nil
+	s.refs = nil
+	s.mu.Unlock()
+	s.resume <- struct{}{}
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	stop, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+
+	stack := stop.Stack()
+	frames := make([]StackFrame, 0, len(stack))
+	for j := len(stack) - 1; j >= 0; j-- {
+		f := stack[j]
+		frame := StackFrame{
+			ID:     len(frames),
+			Name:   f.Name(),
+			Line:   f.Span.Start.Line,
+			Column: f.Span.Start.Column,
+		}
+		if f.Span.File != nil {
+			path, _ := filepath.Abs(f.Span.File.Name)
+			frame.Source = &Source{Name: filepath.Base(path), Path: path}
+		}
+		frames = append(frames, frame)
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (s *Server) scopes(raw json.RawMessage) (interface{}, error) {
+	var args ScopesArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if _, err := s.paused(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scopes := []Scope{
+		{Name: localsScope, VariablesReference: s.reference(reference{scope: localsScope, frame: args.FrameID})},
+		{Name: globalsScope, VariablesReference: s.reference(reference{scope: globalsScope})},
+	}
+	return map[string][]Scope{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(raw json.RawMessage) (interface{}, error) {
+	var args VariablesArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	stop, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if args.VariablesReference < 1 || args.VariablesReference > len(s.refs) {
+		return nil, fmt.Errorf("Unknown variables reference %d.", args.VariablesReference)
+	}
+
+	var vars []interpreter.Variable
+	switch ref := s.refs[args.VariablesReference-1]; ref.scope {
+	case localsScope:
+		// Leave out variables shadowed by inner scopes.
+		seen := make(map[string]bool)
+		for _, scope := range stop.Locals(ref.frame) {
+			for _, v := range scope {
+				if v.Name != "" && !seen[v.Name] {
+					seen[v.Name] = true
+					vars = append(vars, v)
+				}
+			}
+		}
+	case globalsScope:
+		vars = stop.Globals()
+	default:
+		vars = interpreter.Members(ref.value)
+	}
+
+	variables := make([]Variable, 0, len(vars))
+	for _, v := range vars {
+		variables = append(variables, s.variable(v.Name, v.Value))
+	}
+	return map[string][]Variable{"variables": variables}, nil
+}
+
+func (s *Server) evaluate(raw json.RawMessage) (interface{}, error) {
+	var args EvaluateArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	stop, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := stop.Evaluate(args.Expression, args.FrameID)
+	if diags, ok := err.(diag.List); ok && len(diags) > 0 {
+		return nil, errors.New(diags[0].Message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.variable("", value)
+	return map[string]interface{}{"result": v.Value, "variablesReference": v.VariablesReference}, nil
+}
+
+// variable describes value, giving it a reference if it can be expanded.
+// s.mu must be held.
+func (s *Server) variable(name string, value interface{}) Variable {
+	v := Variable{Name: name, Value: interpreter.Stringify(value)}
+	if len(interpreter.Members(value)) > 0 {
+		v.VariablesReference = s.reference(reference{value: value})
+	}
+	return v
+}
+
+func (s *Server) reference(ref reference) int {
+	s.refs = append(s.refs, ref)
+	return len(s.refs)
+}
+
+// output sends what the script prints to the client.
+type output struct {
+	s        *Server
+	category string
+}
+
+func (o output) Write(p []byte) (int, error) {
+	o.s.send("output", map[string]string{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+// errorSink reports the script's errors as output.
+type errorSink struct {
+	s *Server
+}
+
+func (e errorSink) Diagnostic(d diag.Diagnostic) {
+	var buf bytes.Buffer
+	d.Render(&buf)
+	output{e.s, "stderr"}.Write(buf.Bytes())
+}
+
+func (e errorSink) RuntimeError(err *lox.RuntimeError) {
+	var buf bytes.Buffer
+	diag.Render(&buf, "error", err.Message, err.Span)
+	for _, frame := range err.Trace {
+		fmt.Fprintln(&buf, frame)
+	}
+	output{e.s, "stderr"}.Write(buf.Bytes())
+}
@@ -0,0 +1,188 @@
+package dap
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a server over a pipe as an editor would.
+type client struct {
+	t    *testing.T
+	conn *Conn
+	msgs chan map[string]interface{}
+	// output is what the script has printed so far.
+	output strings.Builder
+}
+
+func newClient(t *testing.T) (*client, chan error) {
+	serverEnd, clientEnd := net.Pipe()
+	t.Cleanup(func() { clientEnd.Close() })
+
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer(serverEnd, serverEnd, nil).Serve()
+		serverEnd.Close()
+	}()
+
+	c := &client{t: t, conn: NewConn(clientEnd, clientEnd), msgs: make(chan map[string]interface{})}
+	go func() {
+		defer close(c.msgs)
+		for {
+			body, err := c.conn.Read()
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	return c, served
+}
+
+func (c *client) request(command string, args interface{}) {
+	c.t.Helper()
+	raw, err := json.Marshal(args)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.Write(&Request{Command: command, Arguments: raw}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next returns the next message other than an output event, which it adds
+// to c.output instead.
+func (c *client) next() map[string]interface{} {
+	c.t.Helper()
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatal("the server hung up")
+			}
+			if msg["event"] == "output" {
+				c.output.WriteString(msg["body"].(map[string]interface{})["output"].(string))
+				continue
+			}
+			return msg
+		case <-time.After(5 * time.Second):
+			c.t.Fatal("timed out waiting for the server")
+		}
+	}
+}
+
+// response reads the response to command, which must have succeeded, and
+// returns its body.
+func (c *client) response(command string) map[string]interface{} {
+	c.t.Helper()
+	msg := c.next()
+	if msg["type"] != "response" || msg["command"] != command || msg["success"] != true {
+		c.t.Fatalf("got %v, want a successful %s response", msg, command)
+	}
+	body, _ := msg["body"].(map[string]interface{})
+	return body
+}
+
+// event reads the event, and returns its body.
+func (c *client) event(event string) map[string]interface{} {
+	c.t.Helper()
+	msg := c.next()
+	if msg["type"] != "event" || msg["event"] != event {
+		c.t.Fatalf("got %v, want a %s event", msg, event)
+	}
+	body, _ := msg["body"].(map[string]interface{})
+	return body
+}
+
+// stopped reads a stopped event and checks the line paused on and the
+// value of j there.
+func (c *client) stopped(reason string, line int, j string) {
+	c.t.Helper()
+	if body := c.event("stopped"); body["reason"] != reason {
+		c.t.Errorf("stopped for %v, want %s", body["reason"], reason)
+	}
+
+	c.request("stackTrace", StackTraceArguments{ThreadID: threadID})
+	frames := c.response("stackTrace")["stackFrames"].([]interface{})
+	if got := frames[0].(map[string]interface{})["line"]; got != float64(line) {
+		c.t.Errorf("stopped at line %v, want %d", got, line)
+	}
+	c.request("evaluate", EvaluateArguments{Expression: "j"})
+	if got := c.response("evaluate")["result"]; got != j {
+		c.t.Errorf("j is %v, want %s", got, j)
+	}
+}
+
+func TestSession(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "main.lox")
+	src := "print \"start\";\nfor (var j = 0; j < 3; j = j + 1) {\n  print j;\n}\n"
+	if err := os.WriteFile(program, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, served := newClient(t)
+
+	c.request("initialize", map[string]string{"adapterID": "lox"})
+	if body := c.response("initialize"); body["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("got capabilities %v", body)
+	}
+	c.request("launch", LaunchArguments{Program: program})
+	c.response("launch")
+	c.event("initialized")
+
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 3}},
+	})
+	bps := c.response("setBreakpoints")["breakpoints"].([]interface{})
+	if len(bps) != 1 || bps[0].(map[string]interface{})["verified"] != true {
+		t.Errorf("got breakpoints %v, want one verified", bps)
+	}
+	c.request("configurationDone", nil)
+	c.response("configurationDone")
+
+	c.stopped("breakpoint", 3, "0")
+	c.request("continue", map[string]int{"threadId": threadID})
+	c.response("continue")
+	c.stopped("breakpoint", 3, "1")
+
+	// With the breakpoint cleared, the script runs to the end.
+	c.request("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: program}})
+	c.response("setBreakpoints")
+	c.request("continue", map[string]int{"threadId": threadID})
+	c.response("continue")
+	if body := c.event("exited"); body["exitCode"] != 0.0 {
+		t.Errorf("exited with %v, want 0", body["exitCode"])
+	}
+	c.event("terminated")
+	if got, want := c.output.String(), "\"start\"\n0\n1\n2\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+
+	c.request("disconnect", nil)
+	c.response("disconnect")
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
+
+func TestContinueWhileRunning(t *testing.T) {
+	c, served := newClient(t)
+	c.request("continue", map[string]int{"threadId": threadID})
+	if msg := c.next(); msg["success"] != false || msg["message"] != "The program isn't paused." {
+		t.Errorf("got %v, want an error", msg)
+	}
+	c.request("disconnect", nil)
+	c.response("disconnect")
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/interpreter"
+)
+
+const help = `Commands:
+  break, b [file:]line   set a breakpoint, or list them without a line
+  delete, d [file:]line  remove a breakpoint
+  continue, c            run to the next breakpoint
+  step, s                run to the next statement, stepping into calls
+  next, n                run to the next statement, stepping over calls
+  finish, out, o         run until the current function returns
+  print, p expr          evaluate expr where the script is paused
+  locals, l              list the local variables in scope
+  globals, g             list the globals
+  backtrace, bt          list the calls in progress, innermost first
+  quit, q                stop the script
+An empty line repeats the previous command.
+`
+
+// CLI is a line-oriented Handler that reads commands from a terminal.
+type CLI struct {
+	in   *bufio.Scanner
+	out  io.Writer
+	last string
+}
+
+// NewCLI returns a CLI that reads commands from in and writes to out.
+func NewCLI(in io.Reader, out io.Writer) *CLI {
+	return &CLI{in: bufio.NewScanner(in), out: out}
+}
+
+// Paused implements Handler. It prompts for commands until one resumes the
+// script, and quits once in runs out.
+func (c *CLI) Paused(stop *Stop) error {
+	fmt.Fprintf(c.out, "Paused at %s (%s)\n", stop.Span, stop.Reason)
+	fmt.Fprintf(c.out, "%5d | %s\n", stop.Span.Start.Line, stop.Line())
+
+	for {
+		fmt.Fprint(c.out, "(lox) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return ErrQuit
+		}
+
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+		cmd, arg := line, ""
+		if j := strings.IndexAny(line, " \t"); j >= 0 {
+			cmd, arg = line[:j], strings.TrimSpace(line[j+1:])
+		}
+
+		switch cmd {
+		case "":
+		case "continue", "c":
+			stop.Debugger.Continue()
+			return nil
+		case "step", "s":
+			stop.Debugger.StepIn()
+			return nil
+		case "next", "n":
+			stop.Debugger.StepOver()
+			return nil
+		case "finish", "out", "o":
+			stop.Debugger.StepOut()
+			return nil
+		case "break", "b":
+			c.breakpoint(stop, arg, true)
+		case "delete", "d":
+			c.breakpoint(stop, arg, false)
+		case "print", "p":
+			c.print(stop, arg)
+		case "locals", "l":
+			c.locals(stop)
+		case "globals", "g":
+			for _, v := range stop.Globals() {
+				fmt.Fprintf(c.out, "%s = %s\n", v.Name, interpreter.Stringify(v.Value))
+			}
+		case "backtrace", "bt":
+			stack := stop.Stack()
+			for j := len(stack) - 1; j >= 0; j-- {
+				fmt.Fprintf(c.out, "#%d %s\n", len(stack)-1-j, stack[j].Frame)
+			}
+		case "help", "h":
+			fmt.Fprint(c.out, help)
+		case "quit", "q":
+			return ErrQuit
+		default:
+			fmt.Fprintf(c.out, "Unknown command %q. Try help.\n", cmd)
+		}
+	}
+}
+
+// breakpoint sets or deletes the breakpoint at loc, which is a line of the
+// file the script is paused in or a file:line pair.
+func (c *CLI) breakpoint(stop *Stop, loc string, set bool) {
+	if loc == "" {
+		if !set {
+			fmt.Fprintln(c.out, "Usage: delete [file:]line")
+			return
+		}
+		for _, bp := range stop.Debugger.Breakpoints() {
+			fmt.Fprintf(c.out, "%s:%d\n", bp.File, bp.Line)
+		}
+		return
+	}
+
+	file := stop.Span.File.Name
+	if j := strings.LastIndexByte(loc, ':'); j >= 0 {
+		file, loc = loc[:j], loc[j+1:]
+	}
+	line, err := strconv.Atoi(loc)
+	if err != nil || line < 1 {
+		fmt.Fprintf(c.out, "Invalid line %q.\n", loc)
+		return
+	}
+
+	if !set {
+		if !stop.Debugger.ClearBreakpoint(file, line) {
+			fmt.Fprintf(c.out, "No breakpoint at %s:%d.\n", file, line)
+		}
+		return
+	}
+	if err := stop.Debugger.SetBreakpoint(file, line); err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+	fmt.Fprintf(c.out, "Breakpoint at %s:%d.\n", file, line)
+}
+
+func (c *CLI) print(stop *Stop, src string) {
+	if src == "" {
+		fmt.Fprintln(c.out, "Usage: print expr")
+		return
+	}
+
+	value, err := stop.Evaluate(src, 0)
+	if diags, ok := err.(diag.List); ok {
+		for _, d := range diags {
+			d.Render(c.out)
+		}
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(c.out, "error: %s\n", err)
+		return
+	}
+	fmt.Fprintln(c.out, interpreter.Stringify(value))
+}
+
+// locals lists the variables in scope, leaving out those that are
+// shadowed.
+func (c *CLI) locals(stop *Stop) {
+	seen := make(map[string]bool)
+	for _, scope := range stop.Locals(0) {
+		for _, v := range scope {
+			if v.Name == "" || seen[v.Name] {
+				continue
+			}
+			seen[v.Name] = true
+			fmt.Fprintf(c.out, "%s = %s\n", v.Name, interpreter.Stringify(v.Value))
+		}
+	}
+	if len(seen) == 0 {
+		fmt.Fprintln(c.out, "No locals.")
+	}
+}
@@ -0,0 +1,303 @@
+// Package debugger pauses scripts run by the tree-walk interpreter at
+// breakpoints and after steps, and inspects them while they are paused.
+package debugger
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/resolver"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// ErrQuit is returned by a Handler to stop the script being debugged.
+var ErrQuit = errors.New("debugger: quit")
+
+// Reason says why a script paused.
+type Reason string
+
+const (
+	ReasonEntry      Reason = "entry"
+	ReasonBreakpoint Reason = "breakpoint"
+	ReasonStep       Reason = "step"
+)
+
+// Handler is called whenever the script pauses. The script stays paused
+// until Paused returns, and carries on as the debugger was last told to:
+// by default, until the next breakpoint. An error stops the script.
+type Handler interface {
+	Paused(stop *Stop) error
+}
+
+// Breakpoint is a line of a source file.
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+type mode int
+
+const (
+	running mode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+// Debugger is an interpreter.Hook that pauses the script at breakpoints
+// and steps. It pauses on the first statement of the script, so that
+// breakpoints can be set before anything runs.
+type Debugger struct {
+	handler Handler
+
+	mu sync.Mutex
+	// breakpoints maps a line to the absolute paths of the files with a
+	// breakpoint on it.
+	breakpoints map[int][]string
+	files       map[*scanner.File]string
+	mode        mode
+
+	// stmt and depth are the statement last paused on and the number of
+	// calls in progress then.
+	stmt  parser.Stmt
+	depth int
+}
+
+// New returns a debugger that tells handler whenever the script pauses.
+func New(handler Handler) *Debugger {
+	return &Debugger{
+		handler:     handler,
+		breakpoints: make(map[int][]string),
+		files:       make(map[*scanner.File]string),
+		mode:        stepIn,
+	}
+}
+
+// SetBreakpoint sets a breakpoint on line of file.
+func (d *Debugger) SetBreakpoint(file string, line int) error {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, p := range d.breakpoints[line] {
+		if p == path {
+			return nil
+		}
+	}
+	d.breakpoints[line] = append(d.breakpoints[line], path)
+	return nil
+}
+
+// ClearBreakpoint removes the breakpoint on line of file, and reports
+// whether there was one.
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	paths := d.breakpoints[line]
+	for j, p := range paths {
+		if p == path {
+			d.breakpoints[line] = append(paths[:j:j], paths[j+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns the breakpoints that are set, ordered by file and
+// line. Their files are absolute paths.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var bps []Breakpoint
+	for line, paths := range d.breakpoints {
+		for _, path := range paths {
+			bps = append(bps, Breakpoint{File: path, Line: line})
+		}
+	}
+	sort.Slice(bps, func(a, b int) bool {
+		if bps[a].File != bps[b].File {
+			return bps[a].File < bps[b].File
+		}
+		return bps[a].Line < bps[b].Line
+	})
+	return bps
+}
+
+// Continue makes the script run on to the next breakpoint once the
+// handler returns.
+func (d *Debugger) Continue() {
+	d.setMode(running)
+}
+
+// StepIn makes the script pause again at the next statement, inside any
+// function it calls.
+func (d *Debugger) StepIn() {
+	d.setMode(stepIn)
+}
+
+// StepOver makes the script pause again at the next statement that isn't
+// inside a function it calls.
+func (d *Debugger) StepOver() {
+	d.setMode(stepOver)
+}
+
+// StepOut makes the script pause again once the current function returns.
+func (d *Debugger) StepOut() {
+	d.setMode(stepOut)
+}
+
+func (d *Debugger) setMode(m mode) {
+	d.mu.Lock()
+	d.mode = m
+	d.mu.Unlock()
+}
+
+// Statement implements interpreter.Hook.
+func (d *Debugger) Statement(i *interpreter.Interpreter, stmt parser.Stmt) error {
+	// Pause at the statements in a block rather than at its brace.
+	if _, ok := stmt.(*parser.BlockStmt); ok {
+		return nil
+	}
+
+	reason, ok := d.shouldPause(stmt, i.Depth())
+	if !ok {
+		return nil
+	}
+	return d.handler.Paused(&Stop{
+		Reason:      reason,
+		Span:        stmt.Span(),
+		Debugger:    d,
+		interpreter: i,
+	})
+}
+
+func (d *Debugger) shouldPause(stmt parser.Stmt, depth int) (Reason, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// A statement nested in the one last paused on and starting on the
+	// same line, such as the body of a one-line loop, is part of the same
+	// step.
+	if d.stmt != nil && stmt != d.stmt && depth == d.depth && within(stmt.Span(), d.stmt.Span()) {
+		return "", false
+	}
+
+	var reason Reason
+	switch {
+	case d.isBreakpoint(stmt.Span()):
+		reason = ReasonBreakpoint
+	case d.stmt == nil:
+		reason = ReasonEntry
+	case d.mode == stepIn,
+		d.mode == stepOver && depth <= d.depth,
+		d.mode == stepOut && depth < d.depth:
+		reason = ReasonStep
+	default:
+		return "", false
+	}
+
+	d.stmt, d.depth = stmt, depth
+	d.mode = running
+	return reason, true
+}
+
+func (d *Debugger) isBreakpoint(span scanner.Span) bool {
+	paths := d.breakpoints[span.Start.Line]
+	if len(paths) == 0 || span.File == nil || span.File.Name == "" {
+		return false
+	}
+
+	path, ok := d.files[span.File]
+	if !ok {
+		path, _ = filepath.Abs(span.File.Name)
+		d.files[span.File] = path
+	}
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether inner lies within outer and starts on the same
+// line.
+func within(inner, outer scanner.Span) bool {
+	return inner.File == outer.File &&
+		inner.Start.Line == outer.Start.Line &&
+		inner.Start.Offset >= outer.Start.Offset &&
+		inner.End.Offset <= outer.End.Offset
+}
+
+// Stop is a paused script. Its methods may only be used until the
+// handler it was passed to returns.
+type Stop struct {
+	Reason Reason
+	// Span is that of the statement about to run.
+	Span     scanner.Span
+	Debugger *Debugger
+
+	interpreter *interpreter.Interpreter
+}
+
+// Line returns the text of the line the script is paused on.
+func (s *Stop) Line() string {
+	if s.Span.File == nil {
+		return ""
+	}
+	return s.Span.File.Line(s.Span.Start.Line)
+}
+
+// Stack returns the calls in progress, innermost last.
+func (s *Stop) Stack() []interpreter.StackFrame {
+	return s.interpreter.CallStack(s.Span)
+}
+
+// Locals returns the local variables in scope in frame, innermost scope
+// first. Frames count out from the innermost, which is frame 0.
+func (s *Stop) Locals(frame int) [][]interpreter.Variable {
+	return s.interpreter.Locals(frame)
+}
+
+// Globals returns the globals of the running module, sorted by name.
+func (s *Stop) Globals() []interpreter.Variable {
+	return s.interpreter.Globals()
+}
+
+// Evaluate evaluates the Lox expression src in frame. The error is a
+// diag.List if src doesn't compile.
+func (s *Stop) Evaluate(src string, frame int) (interface{}, error) {
+	tokens := scanner.New(src).ScanTokens()
+	expr, errs := parser.NewParser(tokens).ParseExpression()
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	locals := s.interpreter.Locals(frame)
+	scopes := make([][]string, len(locals))
+	for j, scope := range locals {
+		names := make([]string, len(scope))
+		for k, v := range scope {
+			names[k] = v.Name
+		}
+		scopes[len(locals)-1-j] = names
+	}
+	if errs := resolver.NewResolver(s.interpreter).ResolveExpr(expr, scopes); errs.HasErrors() {
+		return nil, errs
+	}
+
+	return s.interpreter.Evaluate(expr, frame)
+}
@@ -0,0 +1,169 @@
+package debugger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fosmjo/lox/lox"
+)
+
+const script = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var x = 1;
+var y = add(x, 2);
+print y;
+for (var j = 0; j < 3; j = j + 1) {
+  x = x + j;
+}
+print x;
+`
+
+// step is a pause the script is expected to make, and what to do there.
+type step struct {
+	reason Reason
+	line   int
+	do     func(t *testing.T, stop *Stop)
+}
+
+// scripted is a Handler that checks each pause against the next step.
+type scripted struct {
+	t     *testing.T
+	steps []step
+}
+
+func (s *scripted) Paused(stop *Stop) error {
+	if len(s.steps) == 0 {
+		s.t.Errorf("paused for %s at line %d after the last step", stop.Reason, stop.Span.Start.Line)
+		return ErrQuit
+	}
+	step := s.steps[0]
+	s.steps = s.steps[1:]
+	if stop.Reason != step.reason || stop.Span.Start.Line != step.line {
+		s.t.Errorf("paused for %s at line %d, want %s at line %d",
+			stop.Reason, stop.Span.Start.Line, step.reason, step.line)
+	}
+	if step.do != nil {
+		step.do(s.t, stop)
+	}
+	return nil
+}
+
+// evaluates returns a step action that checks the value of src.
+func evaluates(src string, want interface{}) func(t *testing.T, stop *Stop) {
+	return func(t *testing.T, stop *Stop) {
+		got, err := stop.Evaluate(src, 0)
+		if err != nil || got != want {
+			t.Errorf("%s: got %v and error %v, want %v", src, got, err, want)
+		}
+	}
+}
+
+// debug runs script under a debugger with handler, returning what it
+// printed.
+func debug(t *testing.T, handler Handler, setup func(d *Debugger, file string)) (string, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "main.lox")
+	if err := os.WriteFile(file, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(handler)
+	if setup != nil {
+		setup(d, file)
+	}
+	var out bytes.Buffer
+	vm, err := lox.New(lox.Options{Stdout: &out, Hook: d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = vm.EvalSource(context.Background(), file, script)
+	return out.String(), err
+}
+
+func TestStepping(t *testing.T) {
+	h := &scripted{t: t}
+	h.steps = []step{
+		{ReasonEntry, 1, func(t *testing.T, stop *Stop) { stop.Debugger.StepOver() }},
+		{ReasonStep, 5, func(t *testing.T, stop *Stop) { stop.Debugger.StepOver() }},
+		{ReasonStep, 6, func(t *testing.T, stop *Stop) { stop.Debugger.StepIn() }},
+		{ReasonStep, 2, func(t *testing.T, stop *Stop) {
+			stack := stop.Stack()
+			if len(stack) != 2 || stack[0].Name() != "script" || stack[1].Name() != "add()" {
+				t.Errorf("got stack %v, want add() called from the script", stack)
+			}
+			evaluates("a + b", 3.0)(t, stop)
+			stop.Debugger.StepOver()
+		}},
+		{ReasonStep, 3, func(t *testing.T, stop *Stop) {
+			evaluates("sum", 3.0)(t, stop)
+			stop.Debugger.StepOut()
+		}},
+		{ReasonStep, 7, func(t *testing.T, stop *Stop) {
+			evaluates("y", 3.0)(t, stop)
+		}},
+	}
+
+	// Without being told otherwise after line 7, the script runs to the end.
+	out, err := debug(t, h, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.steps) > 0 {
+		t.Errorf("never paused at line %d", h.steps[0].line)
+	}
+	if out != "3\n4\n" {
+		t.Errorf("got output %q", out)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	h := &scripted{t: t}
+	h.steps = []step{
+		{ReasonEntry, 1, func(t *testing.T, stop *Stop) { stop.Debugger.Continue() }},
+		{ReasonBreakpoint, 9, evaluates("j", 0.0)},
+		{ReasonBreakpoint, 9, func(t *testing.T, stop *Stop) {
+			evaluates("j", 1.0)(t, stop)
+			if !stop.Debugger.ClearBreakpoint(stop.Span.File.Name, 9) {
+				t.Error("there was no breakpoint to clear")
+			}
+		}},
+	}
+
+	out, err := debug(t, h, func(d *Debugger, file string) {
+		if err := d.SetBreakpoint(file, 9); err != nil {
+			t.Fatal(err)
+		}
+		// A breakpoint in another file doesn't stop this one.
+		if err := d.SetBreakpoint(filepath.Join(filepath.Dir(file), "other.lox"), 7); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.steps) > 0 {
+		t.Errorf("never paused at line %d", h.steps[0].line)
+	}
+	if out != "3\n4\n" {
+		t.Errorf("got output %q", out)
+	}
+}
+
+// quitter quits as soon as the script pauses.
+type quitter struct{}
+
+func (quitter) Paused(stop *Stop) error {
+	return ErrQuit
+}
+
+func TestQuit(t *testing.T) {
+	out, err := debug(t, quitter{}, nil)
+	if err == nil || out != "" {
+		t.Errorf("got output %q and error %v, want the script stopped before it printed", out, err)
+	}
+}
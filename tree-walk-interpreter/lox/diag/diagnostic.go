@@ -0,0 +1,133 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return "error"
+}
+
+// Code identifies the kind of problem a diagnostic reports, so that tools
+// can tell problems apart without matching on messages.
+type Code string
+
+const (
+	// Scanner
+	InvalidToken Code = "E0001"
+
+	// Parser
+	UnexpectedToken   Code = "E0100"
+	ExpectExpression  Code = "E0101"
+	InvalidAssignment Code = "E0102"
+	TooManyArguments  Code = "E0103"
+	InvalidModuleName Code = "E0104"
+	IncompleteTry     Code = "E0105"
+
+	// Resolver
+	AlreadyDeclared    Code = "E0200"
+	OwnInitializer     Code = "E0201"
+	InvalidReturn      Code = "E0202"
+	InvalidThis        Code = "E0203"
+	InvalidSuper       Code = "E0204"
+	OutsideLoop        Code = "E0205"
+	InheritsFromItself Code = "E0206"
+
+	// Bytecode compiler
+	TooManyConstants Code = "E0300"
+	TooManyLocals    Code = "E0301"
+	TooManyUpvalues  Code = "E0302"
+	TooManyElements  Code = "E0303"
+	JumpTooLarge     Code = "E0304"
+
+	// Type checker
+	TypeMismatch      Code = "E0500"
+	UndefinedProperty Code = "E0501"
+	ArityMismatch     Code = "E0502"
+	NotCallable       Code = "E0503"
+	InvalidOperand    Code = "E0504"
+	NotAnInstance     Code = "E0505"
+	UnknownType       Code = "E0506"
+
+	// Linter
+	UnusedVariable   Code = "W0400"
+	UnusedParameter  Code = "W0401"
+	ShadowedVariable Code = "W0402"
+	UnreachableCode  Code = "W0403"
+	UndefinedGlobal  Code = "W0404"
+	ThisInFunction   Code = "W0405"
+	UnknownRule      Code = "W0406"
+)
+
+// Diagnostic is a problem found in a source file.
+type Diagnostic struct {
+	Code     Code
+	Severity Severity
+	Message  string
+	Span     scanner.Span
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Span, d.Severity, d.Code, d.Message)
+}
+
+// Render writes d with the offending source underlined, as Render does.
+func (d Diagnostic) Render(w io.Writer) {
+	Render(w, fmt.Sprintf("%s[%s]", d.Severity, d.Code), d.Message, d.Span)
+}
+
+// List is an ordered list of diagnostics.
+type List []Diagnostic
+
+func (l *List) Add(code Code, severity Severity, span scanner.Span, msg string) {
+	*l = append(*l, Diagnostic{Code: code, Severity: severity, Message: msg, Span: span})
+}
+
+// HasErrors reports whether any diagnostic in l has severity Error.
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders l by position in the source, keeping diagnostics at the same
+// position in the order they were added.
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Span, l[j].Span
+		if a.File != b.File && a.File != nil && b.File != nil && a.File.Name != b.File.Name {
+			return a.File.Name < b.File.Name
+		}
+		return a.Start.Offset < b.Start.Offset
+	})
+}
+
+func (l List) Error() string {
+	msgs := make([]string, 0, len(l))
+	for _, d := range l {
+		msgs = append(msgs, d.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,77 @@
+// Package diag describes problems found in Lox source and formats them, and
+// runtime errors, for people to read.
+package diag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Render writes msg in the style of rustc, pointing at span with a line of
+// carets beneath the offending source:
+//
+//	error: Expect ';' after value.
+//	 --> foo.lox:3:8
+//	  |
+//	3 | print a
+//	  |        ^
+func Render(w io.Writer, severity, msg string, span scanner.Span) {
+	fmt.Fprintf(w, "%s: %s\n", severity, msg)
+	if span.Start.Line == 0 {
+		return
+	}
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(span.Start.Line)))
+	fmt.Fprintf(w, "%s--> %s\n", gutter, span)
+	if span.File == nil {
+		return
+	}
+
+	line := span.File.Line(span.Start.Line)
+	start := clamp(span.Start.Column-1, len(line))
+	end := len(line)
+	if span.End.Line == span.Start.Line {
+		end = clamp(span.End.Column-1, len(line))
+	}
+
+	fmt.Fprintf(w, "%s |\n", gutter)
+	fmt.Fprintf(w, "%d | %s\n", span.Start.Line, line)
+	fmt.Fprintf(w, "%s | %s%s\n", gutter, indent(line[:start]), carets(line[start:end]))
+}
+
+// indent returns blanks as wide as text, keeping tabs so that the carets
+// line up however the terminal expands them.
+func indent(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return b.String()
+}
+
+func carets(text string) string {
+	n := utf8.RuneCountInString(text)
+	if n == 0 {
+		n = 1
+	}
+	return strings.Repeat("^", n)
+}
+
+func clamp(n, max int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/format"
+)
+
+// formatFiles runs `lox fmt` with args, the arguments after the command.
+// It formats the named files in place, and the .lox files in the named
+// directories, or formats standard input to standard output if none are
+// named.
+func formatFiles(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list the files whose formatting differs instead of rewriting them")
+	showDiff := flags.Bool("d", false, "print diffs instead of rewriting files")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lox fmt [-l] [-d] [path]...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	hadError := false
+	// formatFile formats src, read from path, and passes the result to
+	// write unless only asked to report the differences.
+	formatFile := func(path string, src []byte, write func([]byte) error) {
+		formatted, err := format.Source(path, string(src))
+		if err != nil {
+			for _, d := range err.(diag.List) {
+				d.Render(os.Stderr)
+			}
+			hadError = true
+			return
+		}
+
+		if !*list && !*showDiff {
+			if err := write(formatted); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
+		if bytes.Equal(src, formatted) {
+			return
+		}
+		if *list {
+			fmt.Println(path)
+		}
+		if *showDiff {
+			fmt.Print(diff(path+".orig", path, src, formatted))
+		}
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		formatFile("<stdin>", src, func(formatted []byte) error {
+			_, err := os.Stdout.Write(formatted)
+			return err
+		})
+	}
+
+	forEachFile(flags.Args(), func(path string, src []byte, mode fs.FileMode) {
+		formatFile(path, src, func(formatted []byte) error {
+			if bytes.Equal(src, formatted) {
+				return nil
+			}
+			return os.WriteFile(path, formatted, mode)
+		})
+	})
+
+	if hadError {
+		os.Exit(65)
+	}
+}
+
+// forEachFile calls f with the contents and permissions of each file named
+// in paths and each .lox file in the directories named in paths.
+func forEachFile(paths []string, f func(path string, src []byte, mode fs.FileMode)) {
+	for _, arg := range paths {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || path != arg && filepath.Ext(path) != ".lox" {
+				return nil
+			}
+
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			f(path, src, info.Mode().Perm())
+			return nil
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// diff returns a unified diff that turns a, the file aName, into b, the
+// file bName.
+func diff(aName, bName string, a, b []byte) string {
+	x, y := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// An edit keeps, deletes or inserts a line, and notes the lines of a
+	// and b that precede it.
+	type edit struct {
+		op         byte
+		line       string
+		aPos, bPos int
+	}
+	var edits []edit
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			edits = append(edits, edit{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', x[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	for k := 0; k < len(edits); k++ {
+		if edits[k].op == ' ' {
+			continue
+		}
+
+		// A hunk runs from the context before the change at k to the
+		// context after the last change that follows closely enough.
+		last := k
+		for e := k + 1; e < len(edits) && e-last <= 2*context; e++ {
+			if edits[e].op != ' ' {
+				last = e
+			}
+		}
+		start, end := k-context, last+context+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "diff %s %s\n--- %s\n+++ %s\n", aName, bName, aName, bName)
+		}
+		var aLen, bLen int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				aLen++
+			}
+			if e.op != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(edits[start].aPos, aLen), hunkRange(edits[start].bPos, bLen))
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = end - 1
+	}
+	return out.String()
+}
+
+// hunkRange formats the range of count lines after the first pos lines of
+// a file.
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, count)
+}
+
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,720 @@
+// Package format prints Lox programs in one canonical layout: two-space
+// indentation, a statement to a line, opening braces at the end of the line
+// that opens them, and single spaces around binary operators and after
+// commas. Comments are kept, as are single blank lines between statements.
+package format
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Source formats src, a program read from the file name. Programs that
+// don't scan or parse aren't formatted; the error is then the diag.List of
+// what's wrong with them.
+func Source(name, src string) ([]byte, error) {
+	tokens := scanner.New(src, scanner.WithFileName(name)).ScanTokens()
+	stmts, diags := parser.NewParser(tokens).Parse()
+	if diags.HasErrors() {
+		diags.Sort()
+		return nil, diags
+	}
+
+	p := &printer{tokens: tokens, lineStart: true, fresh: true}
+	for _, token := range tokens {
+		p.comments = append(p.comments, token.Comments...)
+	}
+	p.stmts(stmts)
+	p.flush(len(src) + 1)
+	return p.out.Bytes(), nil
+}
+
+const indent = "  "
+
+// printer prints statements and expressions. The syntax tree doesn't hold
+// every token, so the printer looks up those it needs, such as braces and
+// the comments before them, by their offsets in the source.
+type printer struct {
+	out    bytes.Buffer
+	indent int
+	// wrapped is whether the statement being printed has been broken over
+	// lines, after which its lines are indented once more.
+	wrapped bool
+	// lineStart is whether nothing has been written to the current line.
+	lineStart bool
+	// line is the source line of the last thing printed, and fresh whether
+	// that was the start of a block, before which blank lines are dropped.
+	line  int
+	fresh bool
+
+	tokens []scanner.Token
+	// comments holds the comments yet to be printed, in source order.
+	comments []scanner.Comment
+}
+
+func (p *printer) write(s string) {
+	if p.lineStart {
+		n := p.indent
+		if p.wrapped {
+			n++
+		}
+		p.out.WriteString(strings.Repeat(indent, n))
+		p.lineStart = false
+	}
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.out.WriteByte('\n')
+	p.lineStart = true
+}
+
+// startLine begins a line for something that starts on source line line,
+// after a blank line if the source had one.
+func (p *printer) startLine(line int) {
+	if !p.lineStart {
+		p.newline()
+	}
+	if !p.fresh && line > p.line+1 {
+		p.newline()
+	}
+	p.fresh = false
+}
+
+// endLine ends the line on which the source position end was printed,
+// adding the comment that followed it in the source, if any.
+func (p *printer) endLine(end scanner.Position) {
+	next := p.next(end.Offset).Span.Start.Offset
+	for j, c := range p.comments {
+		start := c.Span.Start
+		if start.Offset >= next {
+			break
+		}
+		if start.Offset >= end.Offset && start.Line == end.Line {
+			p.write(" " + c.Text)
+			p.comments = append(p.comments[:j], p.comments[j+1:]...)
+			break
+		}
+	}
+	p.newline()
+	p.line = end.Line
+}
+
+// flush prints the comments before offset on lines of their own.
+func (p *printer) flush(offset int) {
+	for len(p.comments) > 0 && p.comments[0].Span.Start.Offset < offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.startLine(c.Span.Start.Line)
+		p.write(c.Text)
+		p.newline()
+		// Comments from within a statement are printed after it.
+		if c.Span.End.Line > p.line {
+			p.line = c.Span.End.Line
+		}
+	}
+}
+
+// breakAfter prints the comments between the source position end, the end
+// of a token within a statement, and the token after it, so that they stay
+// where they were written. A comment on the same line as end follows it,
+// and others go on lines of their own. breakAfter reports whether there
+// were any, in which case it has ended the line.
+func (p *printer) breakAfter(end scanner.Position) bool {
+	next := p.next(end.Offset).Span.Start.Offset
+	broke := false
+	for j := 0; j < len(p.comments); {
+		c := p.comments[j]
+		if c.Span.Start.Offset >= next {
+			break
+		}
+		if c.Span.Start.Offset < end.Offset {
+			j++
+			continue
+		}
+		if c.Span.Start.Line == end.Line {
+			p.write(" " + c.Text)
+		} else {
+			if !p.lineStart {
+				p.newline()
+			}
+			p.write(c.Text)
+		}
+		p.newline()
+		p.line = c.Span.End.Line
+		p.comments = append(p.comments[:j], p.comments[j+1:]...)
+		broke = true
+	}
+	return broke
+}
+
+// space separates the token ending at end from the next part of an
+// expression, breaking the line if comments follow the token.
+func (p *printer) space(end scanner.Position) {
+	wrapped := p.wrapped
+	p.wrapped = true
+	if !p.breakAfter(end) {
+		p.wrapped = wrapped
+		p.write(" ")
+	}
+}
+
+// body prints the body of an if, else, while or for statement, which
+// follows the token ending at end. If comments follow that token, a body
+// other than a block goes on the next line, indented.
+func (p *printer) body(end scanner.Position, body parser.Stmt) {
+	switch {
+	case !p.breakAfter(end):
+		p.write(" ")
+		p.stmt(body)
+	case p.isBlock(body):
+		p.stmt(body)
+	default:
+		p.indent++
+		p.stmt(body)
+		p.indent--
+	}
+}
+
+// prev returns the last token that ends at or before offset.
+func (p *printer) prev(offset int) scanner.Token {
+	j := sort.Search(len(p.tokens), func(j int) bool {
+		return p.tokens[j].Span.End.Offset > offset
+	})
+	if j > 0 {
+		j--
+	}
+	return p.tokens[j]
+}
+
+// next returns the first token at or after offset.
+func (p *printer) next(offset int) scanner.Token {
+	j := sort.Search(len(p.tokens), func(j int) bool {
+		return p.tokens[j].Span.Start.Offset >= offset
+	})
+	if j == len(p.tokens) {
+		j--
+	}
+	return p.tokens[j]
+}
+
+// find returns the first token of type t at or after offset.
+func (p *printer) find(offset int, t scanner.TokenType) scanner.Token {
+	token := p.next(offset)
+	for token.Type != t && token.Type != scanner.EOF {
+		token = p.next(token.Span.End.Offset)
+	}
+	return token
+}
+
+// lines prints n items on lines of their own, the ith of which covers
+// span(i) in the source and is printed by print(i).
+func (p *printer) lines(n int, span func(int) scanner.Span, print func(int)) {
+	for j := 0; j < n; j++ {
+		s := span(j)
+		p.flush(s.Start.Offset)
+		p.startLine(s.Start.Line)
+		p.wrapped = false
+		print(j)
+		p.wrapped = false
+		p.endLine(s.End)
+	}
+}
+
+func (p *printer) stmts(stmts []parser.Stmt) {
+	p.lines(len(stmts),
+		func(j int) scanner.Span { return stmts[j].Span() },
+		func(j int) { p.stmt(stmts[j]) })
+}
+
+// block prints a body of n items between the brace open and its closing
+// brace, which it returns. The items are as for lines.
+func (p *printer) block(open scanner.Token, n int, span func(int) scanner.Span, print func(int)) scanner.Token {
+	end := open.Span.End.Offset
+	if n > 0 {
+		end = span(n - 1).End.Offset
+	}
+	close := p.next(end)
+
+	p.write("{")
+	if n == 0 && (len(p.comments) == 0 || p.comments[0].Span.Start.Offset >= close.Span.Start.Offset) {
+		p.write("}")
+		p.line = close.Span.End.Line
+		return close
+	}
+
+	// The body of a function in a wrapped expression is indented along
+	// with it.
+	wrapped := p.wrapped
+	if wrapped {
+		p.indent++
+		p.wrapped = false
+	}
+	p.indent++
+	p.endLine(open.Span.End)
+	p.fresh = true
+	p.lines(n, span, print)
+	p.flush(close.Span.Start.Offset)
+	p.indent--
+	if wrapped {
+		p.indent--
+		p.wrapped = true
+	}
+	p.write("}")
+	p.line = close.Span.End.Line
+	return close
+}
+
+// list prints n items, separated by commas, between the bracket open and
+// its closing bracket close. The items are as for lines. They go on one
+// line unless comments fall between them, in which case each item goes on
+// a line of its own, keeping the comments next to the items they were
+// written next to.
+func (p *printer) list(open, close scanner.Token, n int, span func(int) scanner.Span, print func(int)) {
+	p.write(open.Lexeme)
+	if !p.commentsBetween(open, close, n, span) {
+		for j := 0; j < n; j++ {
+			if j > 0 {
+				p.write(", ")
+			}
+			print(j)
+		}
+		p.write(close.Lexeme)
+		return
+	}
+
+	p.indent++
+	p.endLine(open.Span.End)
+	p.fresh = true
+	for j := 0; j < n; j++ {
+		s := span(j)
+		p.flush(s.Start.Offset)
+		p.startLine(s.Start.Line)
+		print(j)
+		end := s.End
+		if j < n-1 {
+			p.write(",")
+			end = p.next(end.Offset).Span.End
+		}
+		p.endLine(end)
+	}
+	p.flush(close.Span.Start.Offset)
+	p.indent--
+	p.write(close.Lexeme)
+	p.line = close.Span.End.Line
+}
+
+// commentsBetween reports whether any comments lie between open and close
+// other than within the n items between them, which print their own.
+func (p *printer) commentsBetween(open, close scanner.Token, n int, span func(int) scanner.Span) bool {
+	for _, c := range p.comments {
+		offset := c.Span.Start.Offset
+		if offset >= close.Span.Start.Offset {
+			break
+		}
+		if offset < open.Span.End.Offset {
+			continue
+		}
+		within := false
+		for j := 0; j < n && !within; j++ {
+			s := span(j)
+			within = s.Start.Offset <= offset && offset < s.End.Offset
+		}
+		if !within {
+			return true
+		}
+	}
+	return false
+}
+
+// exprList prints exprs between the bracket open and its closing bracket
+// of type t.
+func (p *printer) exprList(open scanner.Token, t scanner.TokenType, exprs []parser.Expr) {
+	end := open.Span.End.Offset
+	if len(exprs) > 0 {
+		end = exprs[len(exprs)-1].Span().End.Offset
+	}
+	p.list(open, p.find(end, t), len(exprs),
+		func(j int) scanner.Span { return exprs[j].Span() },
+		func(j int) { p.expr(exprs[j]) })
+}
+
+func (p *printer) stmtBlock(open scanner.Token, stmts []parser.Stmt) scanner.Token {
+	return p.block(open, len(stmts),
+		func(j int) scanner.Span { return stmts[j].Span() },
+		func(j int) { p.stmt(stmts[j]) })
+}
+
+// isBlock reports whether stmt is written as a block, rather than being a
+// for loop that the parser wrapped in one.
+func (p *printer) isBlock(stmt parser.Stmt) bool {
+	_, ok := stmt.(*parser.BlockStmt)
+	return ok && p.next(stmt.Span().Start.Offset).Type == scanner.LEFT_BRACE
+}
+
+func (p *printer) stmt(stmt parser.Stmt) {
+	_, _ = stmt.Accept(p)
+}
+
+func (p *printer) expr(expr parser.Expr) {
+	_, _ = expr.Accept(p)
+}
+
+func (p *printer) VisitBlockStmt(stmt *parser.BlockStmt) (interface{}, error) {
+	if !p.isBlock(stmt) {
+		// The parser wraps a for loop with an initializer in a block.
+		p.forLoop(stmt.Statements[0], stmt.Statements[1].(*parser.WhileStmt))
+		return nil, nil
+	}
+	p.stmtBlock(p.next(stmt.Span().Start.Offset), stmt.Statements)
+	return nil, nil
+}
+
+func (p *printer) VisitBreakStmt(stmt *parser.BreakStmt) (interface{}, error) {
+	p.write("break;")
+	return nil, nil
+}
+
+func (p *printer) VisitExpressionStmt(stmt *parser.ExpressionStmt) (interface{}, error) {
+	p.expr(stmt.Expression)
+	p.write(";")
+	return nil, nil
+}
+
+func (p *printer) VisitClassStmt(stmt *parser.ClassStmt) (interface{}, error) {
+	p.write("class " + stmt.Name.Lexeme + " ")
+	if stmt.Superclass != nil {
+		p.write("< " + stmt.Superclass.Name.Lexeme + " ")
+	}
+
+	// The fields and methods are printed in source order.
+	type member struct {
+		span  scanner.Span
+		print func()
+	}
+	var members []member
+	for _, field := range stmt.Fields {
+		field := field
+		semicolon := p.find(field.Name.Span.End.Offset, scanner.SEMICOLON)
+		members = append(members, member{field.Name.Span.Join(semicolon.Span), func() {
+			p.write(field.Name.Lexeme + annotation(field.Annotation) + ";")
+		}})
+	}
+	for _, method := range stmt.Methods {
+		method := method
+		members = append(members, member{method.Span(), func() {
+			p.write(method.Name.Lexeme)
+			p.function(method)
+		}})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].span.Start.Offset < members[j].span.Start.Offset
+	})
+
+	p.block(p.find(stmt.Name.Span.End.Offset, scanner.LEFT_BRACE), len(members),
+		func(j int) scanner.Span { return members[j].span },
+		func(j int) { members[j].print() })
+	return nil, nil
+}
+
+func (p *printer) VisitContinueStmt(stmt *parser.ContinueStmt) (interface{}, error) {
+	p.write("continue;")
+	return nil, nil
+}
+
+func (p *printer) VisitFunctionStmt(stmt *parser.FunctionStmt) (interface{}, error) {
+	p.write("fun " + stmt.Name.Lexeme)
+	p.function(stmt)
+	return nil, nil
+}
+
+// function prints the parameters and body of a function, whose name has
+// been printed.
+func (p *printer) function(stmt *parser.FunctionStmt) {
+	open := p.find(stmt.Name.Span.End.Offset, scanner.LEFT_PAREN)
+	end := open.Span.End.Offset
+	if len(stmt.Params) > 0 {
+		end = p.paramSpan(stmt, len(stmt.Params)-1).End.Offset
+	}
+	close := p.find(end, scanner.RIGHT_PAREN)
+	p.list(open, close, len(stmt.Params),
+		func(j int) scanner.Span { return p.paramSpan(stmt, j) },
+		func(j int) { p.write(stmt.Params[j].Lexeme + annotation(stmt.ParamTypes[j])) })
+	p.write(annotation(stmt.ReturnType) + " ")
+	p.stmtBlock(p.find(close.Span.End.Offset, scanner.LEFT_BRACE), stmt.Body)
+}
+
+// paramSpan returns the span of stmt's jth parameter with its annotation.
+func (p *printer) paramSpan(stmt *parser.FunctionStmt, j int) scanner.Span {
+	span := stmt.Params[j].Span
+	if a := stmt.ParamTypes[j]; a != nil {
+		span = span.Join(a.Name.Span)
+	}
+	return span
+}
+
+// annotation returns the type annotation a as written after a name, or
+// nothing if a is nil.
+func annotation(a *parser.Annotation) string {
+	if a == nil {
+		return ""
+	}
+	return ": " + a.Name.Lexeme
+}
+
+func (p *printer) VisitIfStmt(stmt *parser.IfStmt) (interface{}, error) {
+	p.write("if (")
+	p.expr(stmt.Condition)
+	p.write(")")
+	p.body(p.prev(stmt.ThenBranch.Span().Start.Offset).Span.End, stmt.ThenBranch)
+	if stmt.ElseBranch == nil {
+		return nil, nil
+	}
+
+	switch {
+	case p.breakAfter(stmt.ThenBranch.Span().End):
+	case p.isBlock(stmt.ThenBranch):
+		p.write(" ")
+	default:
+		p.newline()
+	}
+	p.write("else")
+	p.body(p.prev(stmt.ElseBranch.Span().Start.Offset).Span.End, stmt.ElseBranch)
+	return nil, nil
+}
+
+func (p *printer) VisitImportStmt(stmt *parser.ImportStmt) (interface{}, error) {
+	p.write("import " + stmt.Path.Lexeme)
+	switch {
+	case len(stmt.Names) > 0:
+		names := make([]string, len(stmt.Names))
+		for j, name := range stmt.Names {
+			names[j] = name.Lexeme
+		}
+		p.write(" for " + strings.Join(names, ", "))
+	case stmt.Alias.Span != stmt.Path.Span:
+		// The alias wasn't derived from the path.
+		p.write(" as " + stmt.Alias.Lexeme)
+	}
+	p.write(";")
+	return nil, nil
+}
+
+func (p *printer) VisitPrintStmt(stmt *parser.PrintStmt) (interface{}, error) {
+	p.write("print")
+	p.space(p.next(stmt.Span().Start.Offset).Span.End)
+	p.expr(stmt.Expression)
+	p.write(";")
+	return nil, nil
+}
+
+func (p *printer) VisitReturnStmt(stmt *parser.ReturnStmt) (interface{}, error) {
+	p.keywordStmt("return", stmt.Value)
+	return nil, nil
+}
+
+func (p *printer) VisitThrowStmt(stmt *parser.ThrowStmt) (interface{}, error) {
+	p.keywordStmt("throw", stmt.Value)
+	return nil, nil
+}
+
+func (p *printer) keywordStmt(keyword string, value parser.Expr) {
+	p.write(keyword)
+	if value != nil {
+		p.space(p.prev(value.Span().Start.Offset).Span.End)
+		p.expr(value)
+	}
+	p.write(";")
+}
+
+func (p *printer) VisitTryStmt(stmt *parser.TryStmt) (interface{}, error) {
+	p.write("try ")
+	close := p.stmtBlock(p.find(stmt.Span().Start.Offset, scanner.LEFT_BRACE), stmt.Body)
+	if stmt.CatchBody != nil {
+		p.write(" catch (" + stmt.CatchName.Lexeme + ") ")
+		close = p.stmtBlock(p.find(stmt.CatchName.Span.End.Offset, scanner.LEFT_BRACE), stmt.CatchBody)
+	}
+	if stmt.FinallyBody != nil {
+		p.write(" finally ")
+		p.stmtBlock(p.find(close.Span.End.Offset, scanner.LEFT_BRACE), stmt.FinallyBody)
+	}
+	return nil, nil
+}
+
+func (p *printer) VisitVarStmt(stmt *parser.VarStmt) (interface{}, error) {
+	p.write("var " + stmt.Name.Lexeme + annotation(stmt.Annotation))
+	if stmt.Initializer != nil {
+		p.assignment(stmt.Initializer)
+	}
+	p.write(";")
+	return nil, nil
+}
+
+func (p *printer) VisitWhileStmt(stmt *parser.WhileStmt) (interface{}, error) {
+	if p.next(stmt.Span().Start.Offset).Type == scanner.FOR {
+		p.forLoop(nil, stmt)
+		return nil, nil
+	}
+	p.write("while (")
+	p.expr(stmt.Condition)
+	p.write(")")
+	p.body(p.prev(stmt.Body.Span().Start.Offset).Span.End, stmt.Body)
+	return nil, nil
+}
+
+// forLoop prints the for loop that the parser turned into loop.
+func (p *printer) forLoop(initializer parser.Stmt, loop *parser.WhileStmt) {
+	p.write("for (")
+	if initializer != nil {
+		p.stmt(initializer)
+	} else {
+		p.write(";")
+	}
+	// The parser stands in 'true' for a missing condition.
+	if p.next(loop.Condition.Span().Start.Offset).Type != scanner.SEMICOLON {
+		p.write(" ")
+		p.expr(loop.Condition)
+	}
+	p.write(";")
+	if loop.Increment != nil {
+		p.write(" ")
+		p.expr(loop.Increment)
+	}
+	p.write(")")
+	p.body(p.prev(loop.Body.Span().Start.Offset).Span.End, loop.Body)
+}
+
+func (p *printer) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, error) {
+	p.write(expr.Name.Lexeme)
+	p.assignment(expr.Value)
+	return nil, nil
+}
+
+// assignment prints the '=' before value and then value, keeping the
+// comments on either side of the '=' where they were.
+func (p *printer) assignment(value parser.Expr) {
+	equal := p.prev(value.Span().Start.Offset)
+	p.space(p.prev(equal.Span.Start.Offset).Span.End)
+	p.write("=")
+	p.space(equal.Span.End)
+	p.expr(value)
+}
+
+func (p *printer) VisitBinaryExpr(expr *parser.BinaryExpr) (interface{}, error) {
+	p.binary(expr.Left, expr.Operator, expr.Right)
+	return nil, nil
+}
+
+func (p *printer) binary(left parser.Expr, operator scanner.Token, right parser.Expr) {
+	p.expr(left)
+	p.space(left.Span().End)
+	p.write(operator.Lexeme)
+	p.space(operator.Span.End)
+	p.expr(right)
+}
+
+func (p *printer) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
+	p.expr(expr.Callee)
+	p.exprList(p.find(expr.Callee.Span().End.Offset, scanner.LEFT_PAREN), scanner.RIGHT_PAREN, expr.Arguments)
+	return nil, nil
+}
+
+func (p *printer) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
+	p.expr(expr.Object)
+	p.write("." + expr.Name.Lexeme)
+	return nil, nil
+}
+
+func (p *printer) VisitGroupingExpr(expr *parser.GroupingExpr) (interface{}, error) {
+	p.write("(")
+	p.expr(expr.Expression)
+	p.write(")")
+	return nil, nil
+}
+
+func (p *printer) VisitIndexExpr(expr *parser.IndexExpr) (interface{}, error) {
+	p.expr(expr.Object)
+	p.write("[")
+	p.expr(expr.Index)
+	p.write("]")
+	return nil, nil
+}
+
+func (p *printer) VisitIndexSetExpr(expr *parser.IndexSetExpr) (interface{}, error) {
+	p.expr(expr.Object)
+	p.write("[")
+	p.expr(expr.Index)
+	p.write("]")
+	p.assignment(expr.Value)
+	return nil, nil
+}
+
+func (p *printer) VisitLambdaExpr(expr *parser.LambdaExpr) (interface{}, error) {
+	p.write("fun ")
+	p.function(expr.Declaration)
+	return nil, nil
+}
+
+func (p *printer) VisitListExpr(expr *parser.ListExpr) (interface{}, error) {
+	p.exprList(p.next(expr.Span().Start.Offset), scanner.RIGHT_BRACKET, expr.Elements)
+	return nil, nil
+}
+
+// VisitLiteralExpr prints literals as they were written, so that numbers
+// keep their digits.
+func (p *printer) VisitLiteralExpr(expr *parser.LiteralExpr) (interface{}, error) {
+	p.write(p.next(expr.Span().Start.Offset).Lexeme)
+	return nil, nil
+}
+
+func (p *printer) VisitLogicalExpr(expr *parser.LogicalExpr) (interface{}, error) {
+	p.binary(expr.Left, expr.Operator, expr.Right)
+	return nil, nil
+}
+
+func (p *printer) VisitMapExpr(expr *parser.MapExpr) (interface{}, error) {
+	end := expr.Brace.Span.End.Offset
+	if n := len(expr.Values); n > 0 {
+		end = expr.Values[n-1].Span().End.Offset
+	}
+	p.list(expr.Brace, p.find(end, scanner.RIGHT_BRACE), len(expr.Keys),
+		func(j int) scanner.Span { return expr.Keys[j].Span().Join(expr.Values[j].Span()) },
+		func(j int) {
+			p.expr(expr.Keys[j])
+			p.write(": ")
+			p.expr(expr.Values[j])
+		})
+	return nil, nil
+}
+
+func (p *printer) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
+	p.expr(expr.Object)
+	p.write("." + expr.Name.Lexeme)
+	p.assignment(expr.Value)
+	return nil, nil
+}
+
+func (p *printer) VisitSuperExpr(expr *parser.SuperExpr) (interface{}, error) {
+	p.write("super." + expr.Method.Lexeme)
+	return nil, nil
+}
+
+func (p *printer) VisitThisExpr(expr *parser.ThisExpr) (interface{}, error) {
+	p.write("this")
+	return nil, nil
+}
+
+func (p *printer) VisitUnaryExpr(expr *parser.UnaryExpr) (interface{}, error) {
+	p.write(expr.Operator.Lexeme)
+	p.expr(expr.Right)
+	return nil, nil
+}
+
+func (p *printer) VisitVariableExpr(expr *parser.VariableExpr) (interface{}, error) {
+	p.write(expr.Name.Lexeme)
+	return nil, nil
+}
@@ -0,0 +1,73 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFixtures formats each testdata/*.input and compares the result with
+// the .golden file next to it, which must itself be formatted already.
+func TestFixtures(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden := strings.TrimSuffix(input, ".input") + ".golden"
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, src := range map[string][]byte{input: src, golden: want} {
+			got, err := Source(name, string(src))
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+				continue
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s: got\n%s\nwant\n%s", name, got, want)
+			}
+		}
+	}
+}
+
+// TestCommentsInStatements checks that comments within a statement stay
+// after the token they followed.
+func TestCommentsInStatements(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{
+			"if (true) { print 1; } // after then\nelse { print 2; }\n",
+			"if (true) {\n  print 1;\n} // after then\nelse {\n  print 2;\n}\n",
+		},
+		{
+			"var x = 1 + // mid\n2;\n",
+			"var x = 1 + // mid\n  2;\n",
+		},
+		{
+			"while (false) // why\nprint 3;\n",
+			"while (false) // why\n  print 3;\n",
+		},
+	} {
+		got, err := Source("test.lox", tt.src)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", tt.src, got, tt.want)
+		}
+		if again, _ := Source("test.lox", string(got)); string(again) != string(got) {
+			t.Errorf("%q: formatting again gives\n%s", tt.src, again)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package framing reads and writes messages framed by Content-Length
+// headers, as the language server and debug adapter protocols send them.
+package framing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+type Reader struct {
+	r *textproto.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: textproto.NewReader(bufio.NewReader(r))}
+}
+
+// Read reads the body of the next message.
+func (r *Reader) Read() ([]byte, error) {
+	header, err := r.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("framing: bad Content-Length: %v", err)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.r.R, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Write writes a message with body to w.
+func Write(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
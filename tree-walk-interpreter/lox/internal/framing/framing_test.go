@@ -0,0 +1,35 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, body := range []string{`{"a":1}`, `{}`} {
+		if err := Write(&buf, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewReader(&buf)
+	for _, want := range []string{`{"a":1}`, `{}`} {
+		got, err := r.Read()
+		if err != nil || string(got) != want {
+			t.Errorf("got %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("got %v at the end, want EOF", err)
+	}
+}
+
+func TestBadLength(t *testing.T) {
+	r := NewReader(strings.NewReader("Content-Length: many\r\n\r\n{}"))
+	if _, err := r.Read(); err == nil || !strings.Contains(err.Error(), "Content-Length") {
+		t.Errorf("got %v, want a bad Content-Length", err)
+	}
+}
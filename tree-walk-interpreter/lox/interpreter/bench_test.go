@@ -0,0 +1,27 @@
+package interpreter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchScript runs a script from ../bench, which exercise the lookups of
+// local variables in their environments.
+//
+//	go test ./interpreter -run '^$' -bench 'Fib|Loop'
+func benchScript(b *testing.B, name string) {
+	src, err := os.ReadFile(filepath.Join("..", "bench", name))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmark(b, string(src))
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchScript(b, "fib.lox")
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchScript(b, "loop.lox")
+}
@@ -1,25 +1,133 @@
 package interpreter
 
 import (
+	"fmt"
 	"time"
 )
 
 type Callable interface {
 	Arity() int
-	Call(interpreter *Interpreter, arguments []interface{}) interface{}
+	Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
 	String() string
 }
 
+// variadic is implemented by callables that may be called with more than
+// Arity arguments.
+type variadic interface {
+	Variadic() bool
+}
+
+// checkArity returns the error message for calling function with count
+// arguments, or the empty string if that is allowed.
+func checkArity(function Callable, count int) string {
+	if v, ok := function.(variadic); ok && v.Variadic() {
+		if count < function.Arity() {
+			return fmt.Sprintf("Expected at least %d arguments but got %d.", function.Arity(), count)
+		}
+		return ""
+	}
+
+	if count != function.Arity() {
+		return fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), count)
+	}
+	return ""
+}
+
 type clock struct{}
 
 func (clock) Arity() int {
 	return 0
 }
 
-func (clock) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
-	return time.Now()
+func (clock) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return time.Now(), nil
 }
 
 func (clock) String() string {
 	return "<native fn>"
 }
+
+type length struct{}
+
+func (length) Arity() int {
+	return 1
+}
+
+func (length) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	switch v := arguments[0].(type) {
+	case *List:
+		return float64(v.Len()), nil
+	case *Map:
+		return float64(v.Len()), nil
+	case string:
+		return float64(len(v)), nil
+	}
+
+	return nil, RuntimeError{msg: "Argument to 'len' must be a list, a map or a string."}
+}
+
+func (length) String() string {
+	return "<native fn>"
+}
+
+type push struct{}
+
+func (push) Arity() int {
+	return 2
+}
+
+func (push) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, RuntimeError{msg: "First argument to 'push' must be a list."}
+	}
+
+	list.push(arguments[1])
+	return nil, nil
+}
+
+func (push) String() string {
+	return "<native fn>"
+}
+
+type pop struct{}
+
+func (pop) Arity() int {
+	return 1
+}
+
+func (pop) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, RuntimeError{msg: "Argument to 'pop' must be a list."}
+	}
+
+	value, ok := list.pop()
+	if !ok {
+		return nil, RuntimeError{msg: "Can't pop from an empty list."}
+	}
+	return value, nil
+}
+
+func (pop) String() string {
+	return "<native fn>"
+}
+
+type keys struct{}
+
+func (keys) Arity() int {
+	return 1
+}
+
+func (keys) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	m, ok := arguments[0].(*Map)
+	if !ok {
+		return nil, RuntimeError{msg: "Argument to 'keys' must be a map."}
+	}
+
+	return NewList(m.Keys()), nil
+}
+
+func (keys) String() string {
+	return "<native fn>"
+}
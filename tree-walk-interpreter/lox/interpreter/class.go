@@ -7,7 +7,11 @@ type Class struct {
 }
 
 func NewClass(name string, methods map[string]*Function, superclass *Class) *Class {
-	return &Class{name: name, methods: methods, superclass: superclass}
+	c := &Class{name: name, methods: methods, superclass: superclass}
+	for _, m := range methods {
+		m.class = c
+	}
+	return c
 }
 
 func (c *Class) Arity() int {
@@ -18,13 +22,15 @@ func (c *Class) Arity() int {
 	return initializer.Arity()
 }
 
-func (c *Class) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+func (c *Class) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
 	ins := NewInstance(c)
 	initializer := c.findMethod("init")
 	if initializer != nil {
-		initializer.bind(ins).Call(interpreter, arguments)
+		if _, err := initializer.bind(ins).Call(interpreter, arguments); err != nil {
+			return nil, err
+		}
 	}
-	return ins
+	return ins, nil
 }
 
 func (c *Class) String() string {
@@ -0,0 +1,14 @@
+package interpreter
+
+// completion is how a statement finished running. Statements that don't
+// finish normally stop the blocks around them, up to the loop or call that
+// handles them. Runtime errors are returned alongside instead.
+type completion int
+
+const (
+	normal completion = iota
+	// returning carries the value in Interpreter.returnValue.
+	returning
+	breaking
+	continuing
+)
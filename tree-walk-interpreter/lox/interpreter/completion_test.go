@@ -0,0 +1,150 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/resolver"
+	"github.com/fosmjo/lox/scanner"
+)
+
+type discardErrors struct{}
+
+func (discardErrors) RuntimeError(err RuntimeError) {}
+
+// compile returns an interpreter printing to out and the program in src,
+// resolved for it.
+func compile(tb testing.TB, src string, out *bytes.Buffer) (*Interpreter, []parser.Stmt) {
+	tb.Helper()
+	i := NewInterpreter(discardErrors{}, WithStdout(out))
+	stmts, diags := parser.NewParser(scanner.New(src).ScanTokens()).Parse()
+	diags = append(diags, resolver.NewResolver(i).Resolve(stmts)...)
+	if diags.HasErrors() {
+		tb.Fatalf("%q doesn't compile: %v", src, diags)
+	}
+	return i, stmts
+}
+
+func TestCompletionsThroughFinally(t *testing.T) {
+	for _, tt := range []struct {
+		name, src, want string
+	}{
+		{"return", `
+fun f() {
+  try { return 1; } finally { print 2; }
+  print 3;
+}
+print f();`, "2\n1\n"},
+		{"return replaced by finally", `
+fun f() {
+  try { return 1; } finally { return 2; }
+}
+print f();`, "2\n"},
+		{"return value survives calls in finally", `
+fun id(x) { return x; }
+fun f() {
+  try { return 1; } finally { print id(2); }
+}
+print f();`, "2\n1\n"},
+		{"return from catch", `
+fun f() {
+  try { throw 1; } catch (e) { return e + 1; } finally { print 3; }
+}
+print f();`, "3\n2\n"},
+		{"break", `
+for (var j = 0; j < 3; j = j + 1) {
+  try { if (j == 1) break; print j; } finally { print 10 + j; }
+}
+print 99;`, "0\n10\n11\n99\n"},
+		{"continue", `
+for (var j = 0; j < 3; j = j + 1) {
+  try { if (j == 1) continue; print j; } finally { print 10 + j; }
+}`, "0\n10\n11\n2\n12\n"},
+		{"break replaces return", `
+fun f() {
+  while (true) {
+    try { return 1; } finally { break; }
+  }
+  return 2;
+}
+print f();`, "2\n"},
+		{"break from catch", `
+while (true) {
+  try { throw 1; } catch (e) { print e; break; } finally { print 2; }
+}
+print 3;`, "1\n2\n3\n"},
+		{"throw", `
+fun f() {
+  try { throw 1; } finally { print 2; }
+  print 3;
+}
+try { f(); } catch (e) { print e; }`, "2\n1\n"},
+		{"throw replaced by finally", `
+try {
+  try { throw 1; } finally { throw 2; }
+} catch (e) { print e; }`, "2\n"},
+		{"throw replaced by return", `
+fun f() {
+  try { throw 1; } finally { return 2; }
+}
+print f();`, "2\n"},
+		{"nested finally", `
+fun f() {
+  try {
+    try { return 1; } finally { print 2; }
+  } finally { print 3; }
+}
+print f();`, "2\n3\n1\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			i, stmts := compile(t, tt.src, &out)
+			if _, err := i.Execute(context.Background(), stmts); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func benchmark(b *testing.B, src string) {
+	var out bytes.Buffer
+	i, stmts := compile(b, src, &out)
+	b.ResetTimer()
+	for j := 0; j < b.N; j++ {
+		if _, err := i.Execute(context.Background(), stmts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReturn returns from deep within nested blocks and loops.
+func BenchmarkReturn(b *testing.B) {
+	benchmark(b, `
+fun f(n) {
+  while (true) {
+    {
+      if (n > 0) return n;
+      return 0;
+    }
+  }
+}
+for (var j = 0; j < 100000; j = j + 1) f(j);`)
+}
+
+// BenchmarkBreakContinue leaves nested blocks with break and continue.
+func BenchmarkBreakContinue(b *testing.B) {
+	benchmark(b, `
+for (var j = 0; j < 1000; j = j + 1) {
+  for (var k = 0; k < 100; k = k + 1) {
+    {
+      if (k == j) break;
+      if (k > 50) continue;
+    }
+  }
+}`)
+}
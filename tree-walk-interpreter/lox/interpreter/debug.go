@@ -0,0 +1,179 @@
+package interpreter
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Hook is told about each statement before it runs, so that a debugger can
+// pause the script there. An error it returns stops the script.
+type Hook interface {
+	Statement(i *Interpreter, stmt parser.Stmt) error
+}
+
+// WithHook attaches hook, usually a debugger, to the interpreter. Without
+// one, statements run without checking in with anything.
+func WithHook(hook Hook) InterpreterOption {
+	return func(i *Interpreter) {
+		i.hook = hook
+		// Local variables are only named for the debugger's sake.
+		i.builtins.names = new([]string)
+		i.globals.names = new([]string)
+	}
+}
+
+// Variable is a variable as a debugger shows it.
+type Variable struct {
+	Name  string
+	Value interface{}
+}
+
+// Depth returns the number of calls in progress.
+func (i *Interpreter) Depth() int {
+	return len(i.frames)
+}
+
+// StackFrame is a call in progress as a debugger shows it.
+type StackFrame struct {
+	Frame
+	// Span is that of the statement or call the frame is executing.
+	Span scanner.Span
+}
+
+// CallStack returns the calls in progress, innermost last, with the
+// innermost one at span.
+func (i *Interpreter) CallStack(span scanner.Span) []StackFrame {
+	trace := i.traceback(span.Start.Line)
+	stack := make([]StackFrame, len(trace))
+	for j := range trace {
+		stack[j].Frame = trace[j]
+	}
+
+	stack[len(stack)-1].Span = span
+	for j, k := len(stack)-2, len(i.frames)-1; j >= 0 && k >= 0; j, k = j-1, k-1 {
+		stack[j].Span = i.frames[k].site.Span
+	}
+	return stack
+}
+
+// Locals returns the local variables in scope in the given frame, counting
+// out from the innermost, which is frame 0. There is one slice per scope,
+// innermost scope first, holding the variables in the order they were
+// declared. Scopes without any variables are included, so that the slices
+// line up with the scopes an expression is resolved in.
+func (i *Interpreter) Locals(frame int) [][]Variable {
+	var scopes [][]Variable
+	for env := i.frameEnv(frame); env != nil && env.vars == nil; env = env.enclosing {
+		scope := make([]Variable, len(env.slots))
+		for j, value := range env.slots {
+			scope[j].Value = value
+			if env.names != nil && j < len(*env.names) {
+				scope[j].Name = (*env.names)[j]
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// Globals returns the globals of the running module, or of the script,
+// sorted by name. Built-in functions are left out.
+func (i *Interpreter) Globals() []Variable {
+	globals := make([]Variable, 0, len(i.globals.vars))
+	for name, value := range i.globals.vars {
+		globals = append(globals, Variable{Name: name, Value: value})
+	}
+	sortVariables(globals)
+	return globals
+}
+
+// Builtins returns the names of the native functions that every module
+// sees, sorted.
+func (i *Interpreter) Builtins() []string {
+	names := make([]string, 0, len(i.builtins.vars))
+	for name := range i.builtins.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// frameEnv returns the environment the given frame is executing in.
+func (i *Interpreter) frameEnv(frame int) *Environment {
+	if frame == 0 {
+		return i.env
+	}
+	if frame > len(i.frames) {
+		return nil
+	}
+	return i.frames[len(i.frames)-frame].env
+}
+
+// Evaluate evaluates expr in the given frame, in the scope of the statement
+// the frame is executing. expr must have been resolved against the scopes
+// that Locals returns for the frame. The hook isn't told about statements
+// that run while evaluating it.
+func (i *Interpreter) Evaluate(expr parser.Expr, frame int) (interface{}, error) {
+	hook, env := i.hook, i.env
+	i.hook = nil
+	if frame > 0 {
+		i.env = i.frameEnv(frame)
+	}
+	defer func() { i.hook, i.env = hook, env }()
+
+	return i.evaluate(expr)
+}
+
+// Members returns the parts of value that a debugger can expand it into:
+// the fields of an instance, the variables a closure has captured, the
+// elements of a list or map and the globals of a module. It returns nil for
+// other values.
+func Members(value interface{}) []Variable {
+	var members []Variable
+	switch value := value.(type) {
+	case *Instance:
+		for name, v := range value.fields {
+			members = append(members, Variable{Name: name, Value: v})
+		}
+		sortVariables(members)
+	case *Function:
+		seen := make(map[string]bool)
+		for env := value.closure; env != nil && env.vars == nil; env = env.enclosing {
+			for j, v := range env.slots {
+				if env.names == nil || j >= len(*env.names) || seen[(*env.names)[j]] {
+					continue
+				}
+				seen[(*env.names)[j]] = true
+				members = append(members, Variable{Name: (*env.names)[j], Value: v})
+			}
+		}
+	case *List:
+		for j, v := range value.elements {
+			members = append(members, Variable{Name: "[" + strconv.Itoa(j) + "]", Value: v})
+		}
+	case *Map:
+		for _, k := range value.keys {
+			members = append(members, Variable{Name: "[" + stringify(k) + "]", Value: value.entries[k]})
+		}
+	case *Module:
+		for name, v := range value.globals.vars {
+			members = append(members, Variable{Name: name, Value: v})
+		}
+		sortVariables(members)
+	}
+	return members
+}
+
+func sortVariables(vars []Variable) {
+	sort.Slice(vars, func(a, b int) bool {
+		return vars[a].Name < vars[b].Name
+	})
+}
+
+// Stringify formats value as a print statement does.
+func Stringify(value interface{}) string {
+	return stringify(value)
+}
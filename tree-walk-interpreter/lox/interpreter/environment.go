@@ -2,16 +2,25 @@ package interpreter
 
 import "github.com/fosmjo/lox/scanner"
 
+// Environment holds the variables of a scope. Globals are kept by name.
+// Locals are kept in slots, numbered by the resolver in the order they are
+// declared, and are looked up by index.
 type Environment struct {
 	vars      map[string]interface{}
+	slots     []interface{}
 	enclosing *Environment
+
+	// names holds the names of the slots in interpreters with a debugger
+	// attached, and is nil otherwise. It is a pointer to keep environments
+	// small when it isn't used.
+	names *[]string
 }
 
 type Option func(*Environment)
 
+// NewEnvironment returns the environment of a local scope.
 func NewEnvironment(options ...Option) *Environment {
-	vars := make(map[string]interface{})
-	env := &Environment{vars: vars}
+	env := &Environment{}
 
 	for _, option := range options {
 		option(env)
@@ -20,50 +29,66 @@ func NewEnvironment(options ...Option) *Environment {
 	return env
 }
 
+// NewGlobalEnvironment returns an environment for globals, which the
+// resolver leaves unresolved and which are therefore looked up by name.
+func NewGlobalEnvironment(options ...Option) *Environment {
+	env := NewEnvironment(options...)
+	env.vars = make(map[string]interface{})
+	return env
+}
+
 func WithEnclosing(enclosing *Environment) Option {
 	return func(env *Environment) {
 		env.enclosing = enclosing
+		if enclosing.names != nil {
+			env.names = new([]string)
+		}
 	}
 }
 
+// Define defines a variable. In a local scope, it takes the next slot.
 func (e *Environment) Define(name string, value interface{}) {
-	e.vars[name] = value
+	if e.vars != nil {
+		e.vars[name] = value
+		return
+	}
+	e.slots = append(e.slots, value)
+	if e.names != nil {
+		*e.names = append(*e.names, name)
+	}
 }
 
-func (e *Environment) Get(name scanner.Token) interface{} {
+func (e *Environment) Get(name scanner.Token) (interface{}, error) {
 	if v, ok := e.vars[name.Lexeme]; ok {
-		return v
+		return v, nil
 	}
 
 	if e.enclosing != nil {
 		return e.enclosing.Get(name)
 	}
 
-	err := RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."}
-	panic(err)
+	return nil, RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."}
 }
 
-func (e *Environment) Assign(name scanner.Token, value interface{}) {
+func (e *Environment) Assign(name scanner.Token, value interface{}) error {
 	if _, ok := e.vars[name.Lexeme]; ok {
 		e.vars[name.Lexeme] = value
-		return
+		return nil
 	}
 
 	if e.enclosing != nil {
-		e.enclosing.Assign(name, value)
-		return
+		return e.enclosing.Assign(name, value)
 	}
 
-	err := RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."}
-	panic(err)
+	return RuntimeError{token: name, msg: "Undefined variable '" + name.Lexeme + "'."}
 }
 
-func (e *Environment) GetAt(distance int, name string) interface{} {
-	return e.Ancestor(distance).vars[name]
+func (e *Environment) GetAt(distance, slot int) interface{} {
+	return e.Ancestor(distance).slots[slot]
 }
 
-func (e *Environment) AssignAt(distance int, name scanner.Token, value interface{}) {
-	e.Ancestor(distance).vars[name.Lexeme] = value
+func (e *Environment) AssignAt(distance, slot int, value interface{}) {
+	e.Ancestor(distance).slots[slot] = value
 }
 
 func (e *Environment) Ancestor(distance int) *Environment {
@@ -2,9 +2,23 @@ package interpreter
 
 import "github.com/fosmjo/lox/scanner"
 
+// errorClass is the class of the objects that built-in runtime errors are
+// exposed as when caught by Lox code.
+var errorClass = NewClass("Error", map[string]*Function{}, nil)
+
 type RuntimeError struct {
 	token scanner.Token
 	msg   string
+
+	// value is the Lox value passed to 'throw', if thrown is set.
+	value  interface{}
+	thrown bool
+
+	// limit is the execution limit that was exceeded, if any.
+	limit error
+
+	// trace is the call stack at the point the error was raised.
+	trace []Frame
 }
 
 func (re RuntimeError) Token() scanner.Token {
@@ -12,9 +26,28 @@ func (re RuntimeError) Token() scanner.Token {
 }
 
 func (re RuntimeError) Error() string {
-	return re.msg + re.token.Lexeme
+	return re.msg
 }
 
-type Return struct {
-	value interface{}
+// Trace returns the calls that were in progress when the error was raised,
+// innermost last.
+func (re RuntimeError) Trace() []Frame {
+	return re.trace
+}
+
+func (re RuntimeError) Unwrap() error {
+	return re.limit
+}
+
+// Value returns the value a catch clause binds for this error: the thrown
+// value itself, or an Error instance with 'message' and 'line' fields.
+func (re RuntimeError) Value() interface{} {
+	if re.thrown {
+		return re.value
+	}
+
+	ins := NewInstance(errorClass)
+	ins.fields["message"] = re.msg
+	ins.fields["line"] = float64(re.token.Line)
+	return ins
 }
@@ -1,55 +1,78 @@
 package interpreter
 
-import "github.com/fosmjo/lox/parser"
+import (
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
 
 type Function struct {
 	closure       *Environment
+	globals       *Environment
 	declaration   *parser.FunctionStmt
 	isInitializer bool
+
+	// class is the class the function is a method of, if it is one.
+	class *Class
 }
 
-func NewFunction(declaration *parser.FunctionStmt, closure *Environment, isInitializer bool) *Function {
-	return &Function{declaration: declaration, closure: closure, isInitializer: isInitializer}
+// NewFunction creates a function closing over closure. Variables that the
+// resolver left unresolved are looked up in globals, the globals of the
+// module the function was declared in.
+func NewFunction(declaration *parser.FunctionStmt, closure, globals *Environment, isInitializer bool) *Function {
+	return &Function{declaration: declaration, closure: closure, globals: globals, isInitializer: isInitializer}
 }
 
 func (f *Function) Arity() int {
 	return len(f.declaration.Params)
 }
 
-func (f *Function) Call(interpreter *Interpreter, arguments []interface{}) (ret interface{}) {
+func (f *Function) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
 	env := NewEnvironment(WithEnclosing(f.closure))
 	for i := 0; i < len(f.declaration.Params); i++ {
 		env.Define(f.declaration.Params[i].Lexeme, arguments[i])
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			if v, ok := r.(Return); ok {
-				if f.isInitializer {
-					ret = f.closure.GetAt(0, "this")
-					return
-				}
-
-				ret = v.value
-			} else {
-				panic(r)
-			}
+	interpreter.pushFrame(f)
+	preGlobals := interpreter.globals
+	interpreter.globals = f.globals
+
+	c, err := interpreter.executeBlock(f.declaration.Body, env)
+
+	interpreter.globals = preGlobals
+	if err != nil {
+		// The innermost frame an error passes through records the stack
+		// before it unwinds.
+		if e, ok := err.(RuntimeError); ok && e.trace == nil {
+			e.trace = interpreter.traceback(e.token.Line)
+			err = e
 		}
-	}()
+		interpreter.popFrame()
+		return nil, err
+	}
+	interpreter.popFrame()
 
-	interpreter.executeBlock(f.declaration.Body, env)
 	if f.isInitializer {
-		ret = f.closure.GetAt(0, "this")
+		return f.closure.GetAt(0, 0), nil
+	}
+	if c == returning {
+		value := interpreter.returnValue
+		interpreter.returnValue = nil
+		return value, nil
 	}
-	return
+	return nil, nil
 }
 
 func (f *Function) String() string {
+	if f.declaration.Name.Type != scanner.IDENTIFIER {
+		return "<fn anonymous>"
+	}
 	return "<fn " + f.declaration.Name.Lexeme + ">"
 }
 
 func (f *Function) bind(i *Instance) *Function {
 	env := NewEnvironment(WithEnclosing(f.closure))
 	env.Define("this", i)
-	return NewFunction(f.declaration, env, f.isInitializer)
+	bound := NewFunction(f.declaration, env, f.globals, f.isInitializer)
+	bound.class = f.class
+	return bound
 }
@@ -12,18 +12,17 @@ func NewInstance(class *Class) *Instance {
 	return &Instance{class: class, fields: fields}
 }
 
-func (i *Instance) Get(name scanner.Token) interface{} {
+func (i *Instance) Get(name scanner.Token) (interface{}, error) {
 	if v, ok := i.fields[name.Lexeme]; ok {
-		return v
+		return v, nil
 	}
 
 	method := i.class.findMethod(name.Lexeme)
 	if method != nil {
-		return method.bind(i)
+		return method.bind(i), nil
 	}
 
-	err := RuntimeError{token: name, msg: "Undefined property '" + name.Lexeme + "'."}
-	panic(err)
+	return nil, RuntimeError{token: name, msg: "Undefined property '" + name.Lexeme + "'."}
 }
 
 func (i *Instance) Set(name scanner.Token, value interface{}) {
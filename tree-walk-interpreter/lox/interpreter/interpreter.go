@@ -1,295 +1,687 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fosmjo/lox/parser"
 	"github.com/fosmjo/lox/scanner"
 )
 
 type Interpreter struct {
-	globals *Environment
-	env     *Environment
-	locals  map[parser.Expr]int
-	lox     loxer
+	builtins *Environment
+	globals  *Environment
+	env      *Environment
+	locals   map[parser.Expr]local
+	lox      loxer
+
+	// returnValue is the value of the return statement that the innermost
+	// function call is completing with.
+	returnValue interface{}
+
+	stdout io.Writer
+	stdin  io.Reader
+
+	loader  Loader
+	modules map[string]*Module
+	module  *Module
+
+	done      <-chan struct{}
+	timeout   time.Duration
+	steps     int
+	maxSteps  int
+	callDepth int
+
+	frames       []frame
+	callSite     *scanner.Token
+	maxCallDepth int
+
+	hook Hook
 }
 
 type loxer interface {
 	RuntimeError(err RuntimeError)
 }
 
-func NewInterpreter(lox loxer) *Interpreter {
-	globals := NewEnvironment()
-	globals.Define("clock", clock{})
-	locals := make(map[parser.Expr]int)
+type InterpreterOption func(*Interpreter)
+
+func NewInterpreter(lox loxer, options ...InterpreterOption) *Interpreter {
+	builtins := NewGlobalEnvironment()
+	builtins.Define("clock", clock{})
+	builtins.Define("len", length{})
+	builtins.Define("push", push{})
+	builtins.Define("pop", pop{})
+	builtins.Define("keys", keys{})
+	globals := NewGlobalEnvironment(WithEnclosing(builtins))
+	locals := make(map[parser.Expr]local)
+
+	i := &Interpreter{
+		builtins:     builtins,
+		globals:      globals,
+		env:          globals,
+		locals:       locals,
+		lox:          lox,
+		stdout:       os.Stdout,
+		stdin:        os.Stdin,
+		modules:      make(map[string]*Module),
+		maxCallDepth: defaultMaxCallDepth,
+	}
 
-	return &Interpreter{
-		globals: globals,
-		env:     globals,
-		locals:  locals,
-		lox:     lox,
+	for _, option := range options {
+		option(i)
+	}
+
+	return i
+}
+
+// WithStdout sends the output of print statements to w instead of the
+// process's standard output.
+func WithStdout(w io.Writer) InterpreterOption {
+	return func(i *Interpreter) {
+		i.stdout = w
+	}
+}
+
+// WithStdin makes natives that read input read from r instead of the
+// process's standard input.
+func WithStdin(r io.Reader) InterpreterOption {
+	return func(i *Interpreter) {
+		i.stdin = r
+	}
+}
+
+// WithLoader enables import statements, using loader to find and compile
+// the imported files.
+func WithLoader(loader Loader) InterpreterOption {
+	return func(i *Interpreter) {
+		i.loader = loader
 	}
 }
 
 func (i *Interpreter) Interpret(stmts []parser.Stmt) {
-	defer func() {
-		if r := recover(); r != nil {
-			if err, ok := r.(RuntimeError); ok {
-				i.lox.RuntimeError(err)
-			} else {
-				panic(r)
+	if _, err := i.Execute(context.Background(), stmts); err != nil {
+		i.lox.RuntimeError(err.(RuntimeError))
+	}
+}
+
+// Execute runs stmts like Interpret, but returns a runtime error instead of
+// reporting it. The value is that of the last statement if it is an
+// expression statement. Execution stops with an error once ctx is done.
+func (i *Interpreter) Execute(ctx context.Context, stmts []parser.Stmt) (interface{}, error) {
+	defer i.start(ctx)()
+
+	value, err := i.executeScript(stmts)
+	if err != nil {
+		if e, ok := err.(RuntimeError); ok && e.trace == nil {
+			e.trace = i.traceback(e.token.Line)
+			err = e
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+// ExecuteFile is like Execute, for the script read from the file at the
+// absolute path. The script counts as a module being loaded while it runs,
+// so that a module importing it back is reported as an import cycle
+// instead of running it a second time.
+func (i *Interpreter) ExecuteFile(ctx context.Context, path string, stmts []parser.Stmt) (interface{}, error) {
+	module := NewModule(moduleName(path), path, i.globals)
+	i.modules[path] = module
+	value, err := i.Execute(ctx, stmts)
+	if err != nil {
+		delete(i.modules, path)
+		return nil, err
+	}
+	module.loaded = true
+	return value, nil
+}
+
+func (i *Interpreter) executeScript(stmts []parser.Stmt) (interface{}, error) {
+	for j, stmt := range stmts {
+		if exprStmt, ok := stmt.(*parser.ExpressionStmt); ok && j == len(stmts)-1 {
+			if i.hook != nil {
+				if err := i.hook.Statement(i, stmt); err != nil {
+					return nil, err
+				}
 			}
+			return i.evaluate(exprStmt.Expression)
+		}
+		if _, err := i.execute(stmt); err != nil {
+			return nil, err
 		}
-	}()
+	}
+	return nil, nil
+}
 
-	for _, stmt := range stmts {
-		i.execute(stmt)
+// Call calls callee with arguments from outside of any script, as a host
+// program does.
+func (i *Interpreter) Call(ctx context.Context, callee interface{}, arguments []interface{}) (interface{}, error) {
+	defer i.start(ctx)()
+
+	function, ok := callee.(Callable)
+	if !ok {
+		return nil, RuntimeError{msg: "Can only call functions and classes."}
+	}
+	if msg := checkArity(function, len(arguments)); msg != "" {
+		return nil, RuntimeError{msg: msg}
+	}
+	return function.Call(i, arguments)
+}
+
+// start resets the execution limits for a run under ctx, and returns a
+// function that releases the run's resources.
+func (i *Interpreter) start(ctx context.Context) func() {
+	cancel := func() {}
+	if i.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, i.timeout)
+	}
+
+	i.done = ctx.Done()
+	i.steps = 0
+	i.callDepth = 0
+	i.frames = i.frames[:0]
+	i.callSite = nil
+	return cancel
+}
+
+func (i *Interpreter) SetGlobal(name string, value interface{}) {
+	i.globals.Define(name, value)
+}
+
+func (i *Interpreter) GetGlobal(name string) (interface{}, bool) {
+	for env := i.globals; env != nil; env = env.enclosing {
+		if v, ok := env.vars[name]; ok {
+			return v, true
+		}
 	}
+	return nil, false
 }
 
-func (i *Interpreter) VisitBinaryExpr(expr *parser.BinaryExpr) interface{} {
-	left := i.evaluate(expr.Left)
-	right := i.evaluate(expr.Right)
+func (i *Interpreter) VisitBinaryExpr(expr *parser.BinaryExpr) (interface{}, error) {
+	left, err := i.evaluate(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := i.evaluate(expr.Right)
+	if err != nil {
+		return nil, err
+	}
 
 	switch expr.Operator.Type {
-	case scanner.GREATER:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) > right.(float64)
-	case scanner.GREATER_EQUAL:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) >= right.(float64)
-	case scanner.LESS:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) < right.(float64)
-	case scanner.LESS_EQUAL:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) <= right.(float64)
 	case scanner.EQUAL_EQUAL:
-		return i.isEqual(left, right)
+		return i.isEqual(left, right), nil
 	case scanner.BANG_EQUAL:
-		return !i.isEqual(left, right)
-	case scanner.MINUS:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) - right.(float64)
+		return !i.isEqual(left, right), nil
 	case scanner.PLUS:
 		value1, ok1 := left.(float64)
 		value2, ok2 := right.(float64)
 		if ok1 && ok2 {
-			return value1 + value2
+			return value1 + value2, nil
 		}
 
 		value3, ok3 := left.(string)
 		value4, ok4 := right.(string)
 		if ok3 && ok4 {
-			return value3 + value4
+			return value3 + value4, nil
 		}
 
-		err := RuntimeError{token: expr.Operator, msg: "Operands must be two numbers or two strings."}
-		panic(err)
+		return nil, RuntimeError{token: expr.Operator, msg: "Operands must be two numbers or two strings."}
+	}
+
+	if err := i.checkNumberOperands(expr.Operator, left, right); err != nil {
+		return nil, err
+	}
+
+	switch expr.Operator.Type {
+	case scanner.GREATER:
+		return left.(float64) > right.(float64), nil
+	case scanner.GREATER_EQUAL:
+		return left.(float64) >= right.(float64), nil
+	case scanner.LESS:
+		return left.(float64) < right.(float64), nil
+	case scanner.LESS_EQUAL:
+		return left.(float64) <= right.(float64), nil
+	case scanner.MINUS:
+		return left.(float64) - right.(float64), nil
 	case scanner.SLASH:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) / right.(float64)
+		return left.(float64) / right.(float64), nil
 	case scanner.STAR:
-		i.checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) * right.(float64)
+		return left.(float64) * right.(float64), nil
 	}
 
-	return nil
+	return nil, nil
 }
 
-func (i *Interpreter) VisitCallExpr(expr *parser.CallExpr) interface{} {
-	callee := i.evaluate(expr.Callee)
+func (i *Interpreter) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
+	callee, err := i.evaluate(expr.Callee)
+	if err != nil {
+		return nil, err
+	}
 
-	arguments := make([]interface{}, 0)
+	arguments := make([]interface{}, 0, len(expr.Arguments))
 	for _, arg := range expr.Arguments {
-		arguments = append(arguments, i.evaluate(arg))
+		value, err := i.evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, value)
 	}
 
 	function, ok := callee.(Callable)
 	if !ok {
-		err := RuntimeError{token: expr.Paren, msg: "Can only call functions and classes."}
-		panic(err)
+		return nil, RuntimeError{token: expr.Paren, msg: "Can only call functions and classes."}
 	}
-	if len(arguments) != function.Arity() {
-		err := RuntimeError{token: expr.Paren, msg: fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments))}
-		panic(err)
+	if msg := checkArity(function, len(arguments)); msg != "" {
+		return nil, RuntimeError{token: expr.Paren, msg: msg}
 	}
-	return function.Call(i, arguments)
+
+	if err := i.enterCall(expr.Paren); err != nil {
+		return nil, err
+	}
+	i.callSite = &expr.Paren
+	value, err := function.Call(i, arguments)
+	i.callDepth--
+
+	if err != nil {
+		// Natives don't know where they were called from, so their
+		// errors are reported at the call site.
+		if e, ok := err.(RuntimeError); ok && e.token.Line == 0 {
+			e.token = expr.Paren
+			err = e
+		}
+		return nil, err
+	}
+	return value, nil
 }
 
-func (i *Interpreter) VisitGetExpr(expr *parser.GetExpr) interface{} {
-	object := i.evaluate(expr.Object)
+func (i *Interpreter) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
 
-	instance, ok := object.(*Instance)
-	if !ok {
-		err := RuntimeError{token: expr.Name, msg: "Only instances have properties."}
-		panic(err)
+	switch object := object.(type) {
+	case *Instance:
+		return object.Get(expr.Name)
+	case *Module:
+		return object.Get(expr.Name)
 	}
 
-	return instance.Get(expr.Name)
+	return nil, RuntimeError{token: expr.Name, msg: "Only instances and modules have properties."}
 }
 
-func (i *Interpreter) VisitGroupingExpr(expr *parser.GroupingExpr) interface{} {
+func (i *Interpreter) VisitGroupingExpr(expr *parser.GroupingExpr) (interface{}, error) {
 	return i.evaluate(expr.Expression)
 }
 
-func (i *Interpreter) VisitLiteralExpr(expr *parser.LiteralExpr) interface{} {
-	return expr.Value
+func (i *Interpreter) VisitIndexExpr(expr *parser.IndexExpr) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	switch object := object.(type) {
+	case *List:
+		return object.Get(expr.Bracket, index)
+	case *Map:
+		return object.Get(expr.Bracket, index)
+	}
+
+	return nil, RuntimeError{token: expr.Bracket, msg: "Only lists and maps can be indexed."}
+}
+
+func (i *Interpreter) VisitIndexSetExpr(expr *parser.IndexSetExpr) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	switch object := object.(type) {
+	case *List:
+		value, err := i.evaluate(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		if err := object.Set(expr.Bracket, index, value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case *Map:
+		value, err := i.evaluate(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		if err := object.Set(expr.Bracket, index, value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+
+	return nil, RuntimeError{token: expr.Bracket, msg: "Only lists and maps can be indexed."}
+}
+
+func (i *Interpreter) VisitLambdaExpr(expr *parser.LambdaExpr) (interface{}, error) {
+	return NewFunction(expr.Declaration, i.env, i.globals, false), nil
+}
+
+func (i *Interpreter) VisitListExpr(expr *parser.ListExpr) (interface{}, error) {
+	elements := make([]interface{}, 0, len(expr.Elements))
+	for _, element := range expr.Elements {
+		value, err := i.evaluate(element)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, value)
+	}
+	return NewList(elements), nil
+}
+
+func (i *Interpreter) VisitLiteralExpr(expr *parser.LiteralExpr) (interface{}, error) {
+	return expr.Value, nil
 }
 
-func (i *Interpreter) VisitLogicalExpr(expr *parser.LogicalExpr) interface{} {
-	left := i.evaluate(expr.Left)
+func (i *Interpreter) VisitLogicalExpr(expr *parser.LogicalExpr) (interface{}, error) {
+	left, err := i.evaluate(expr.Left)
+	if err != nil {
+		return nil, err
+	}
 
 	if expr.Operator.Type == scanner.OR {
 		if i.isTruthy(left) {
-			return left
+			return left, nil
 		}
 	} else {
 		if !i.isTruthy(left) {
-			return left
+			return left, nil
 		}
 	}
 
 	return i.evaluate(expr.Right)
 }
 
-func (i *Interpreter) VisitSetExpr(expr *parser.SetExpr) interface{} {
-	object := i.evaluate(expr.Object)
+func (i *Interpreter) VisitMapExpr(expr *parser.MapExpr) (interface{}, error) {
+	m := NewMap()
+	for j := range expr.Keys {
+		key, err := i.evaluate(expr.Keys[j])
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.evaluate(expr.Values[j])
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Set(expr.Brace, key, value); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (i *Interpreter) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
 
 	instance, ok := object.(*Instance)
 	if !ok {
-		err := RuntimeError{token: expr.Name, msg: "Only instances have fields"}
-		panic(err)
+		return nil, RuntimeError{token: expr.Name, msg: "Only instances have fields"}
 	}
 
-	value := i.evaluate(expr.Value)
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
 	instance.Set(expr.Name, value)
-	return value
+	return value, nil
 }
 
-func (i *Interpreter) VisitSuperExpr(expr *parser.SuperExpr) interface{} {
-	distance := i.locals[expr]
-	superclass := i.env.GetAt(distance, "super").(*Class)
-	object := i.env.GetAt(distance-1, "this").(*Instance)
+func (i *Interpreter) VisitSuperExpr(expr *parser.SuperExpr) (interface{}, error) {
+	// 'this' is the only variable in the scope just inside the one holding
+	// 'super'.
+	local := i.locals[expr]
+	superclass := i.env.GetAt(local.depth, local.slot).(*Class)
+	object := i.env.GetAt(local.depth-1, 0).(*Instance)
 
 	method := superclass.findMethod(expr.Method.Lexeme)
 	if method == nil {
-		err := RuntimeError{token: expr.Method, msg: "Undefined property '" + expr.Method.Lexeme + "'."}
-		panic(err)
+		return nil, RuntimeError{token: expr.Method, msg: "Undefined property '" + expr.Method.Lexeme + "'."}
 	}
 
-	return method.bind(object)
+	return method.bind(object), nil
 }
 
-func (i *Interpreter) VisitThisExpr(expr *parser.ThisExpr) interface{} {
+func (i *Interpreter) VisitThisExpr(expr *parser.ThisExpr) (interface{}, error) {
 	return i.lookUpVariable(expr.Keyword, expr)
 }
 
-func (i *Interpreter) VisitUnaryExpr(expr *parser.UnaryExpr) interface{} {
-	right := i.evaluate(expr.Right)
+func (i *Interpreter) VisitUnaryExpr(expr *parser.UnaryExpr) (interface{}, error) {
+	right, err := i.evaluate(expr.Right)
+	if err != nil {
+		return nil, err
+	}
 
 	switch expr.Operator.Type {
 	case scanner.BANG:
-		return !i.isTruthy(right)
+		return !i.isTruthy(right), nil
 	case scanner.MINUS:
-		return -right.(float64)
+		if err := i.checkNumberOperand(expr.Operator, right); err != nil {
+			return nil, err
+		}
+		return -right.(float64), nil
 	default:
-		return nil
+		return nil, nil
 	}
 }
 
-func (i *Interpreter) VisitVariableExpr(expr *parser.VariableExpr) interface{} {
+func (i *Interpreter) VisitVariableExpr(expr *parser.VariableExpr) (interface{}, error) {
 	return i.lookUpVariable(expr.Name, expr)
 }
 
-func (i *Interpreter) VisitAssignExpr(expr *parser.AssignExpr) interface{} {
-	value := i.evaluate(expr.Value)
+func (i *Interpreter) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, error) {
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
 
-	distance, ok := i.locals[expr]
+	local, ok := i.locals[expr]
 	if ok {
-		i.env.AssignAt(distance, expr.Name, value)
-	} else {
-		i.globals.Assign(expr.Name, value)
+		i.env.AssignAt(local.depth, local.slot, value)
+	} else if err := i.globals.Assign(expr.Name, value); err != nil {
+		return nil, err
 	}
 
-	return value
+	return value, nil
 }
 
-func (i *Interpreter) VisitExpressionStmt(stmt *parser.ExpressionStmt) interface{} {
-	i.evaluate(stmt.Expression)
-	return nil
+func (i *Interpreter) VisitExpressionStmt(stmt *parser.ExpressionStmt) (interface{}, error) {
+	_, err := i.evaluate(stmt.Expression)
+	return nil, err
 }
 
-func (i *Interpreter) VisitFunctionStmt(stmt *parser.FunctionStmt) interface{} {
-	function := NewFunction(stmt, i.env, false)
+func (i *Interpreter) VisitFunctionStmt(stmt *parser.FunctionStmt) (interface{}, error) {
+	function := NewFunction(stmt, i.env, i.globals, false)
 	i.env.Define(stmt.Name.Lexeme, function)
-	return nil
+	return nil, nil
 }
 
-func (i *Interpreter) VisitIfStmt(stmt *parser.IfStmt) interface{} {
-	cond := i.evaluate(stmt.Condition)
+func (i *Interpreter) VisitIfStmt(stmt *parser.IfStmt) (interface{}, error) {
+	cond, err := i.evaluate(stmt.Condition)
+	if err != nil {
+		return nil, err
+	}
+
 	if i.isTruthy(cond) {
-		i.execute(stmt.ThenBranch)
+		return i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
-		i.execute(stmt.ElseBranch)
+		return i.execute(stmt.ElseBranch)
 	}
-	return nil
+	return nil, nil
 }
 
-func (i *Interpreter) VisitPrintStmt(stmt *parser.PrintStmt) interface{} {
-	ret := i.evaluate(stmt.Expression)
-	fmt.Println(i.stringify(ret))
-	return nil
+func (i *Interpreter) VisitImportStmt(stmt *parser.ImportStmt) (interface{}, error) {
+	module, err := i.importModule(stmt.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stmt.Names) == 0 {
+		i.env.Define(stmt.Alias.Lexeme, module)
+		return nil, nil
+	}
+
+	for _, name := range stmt.Names {
+		value, err := module.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		i.env.Define(name.Lexeme, value)
+	}
+	return nil, nil
 }
 
-func (i *Interpreter) VisitReturnStmt(stmt *parser.ReturnStmt) interface{} {
+func (i *Interpreter) VisitPrintStmt(stmt *parser.PrintStmt) (interface{}, error) {
+	ret, err := i.evaluate(stmt.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(i.stdout, stringify(ret))
+	return nil, nil
+}
+
+func (i *Interpreter) VisitReturnStmt(stmt *parser.ReturnStmt) (interface{}, error) {
 	var value interface{}
 	if stmt.Value != nil {
-		value = i.evaluate(stmt.Value)
+		var err error
+		if value, err = i.evaluate(stmt.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	i.returnValue = value
+	return returning, nil
+}
+
+func (i *Interpreter) VisitThrowStmt(stmt *parser.ThrowStmt) (interface{}, error) {
+	value, err := i.evaluate(stmt.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, RuntimeError{token: stmt.Keyword, msg: "Uncaught exception: " + stringify(value), value: value, thrown: true}
+}
+
+func (i *Interpreter) VisitTryStmt(stmt *parser.TryStmt) (interface{}, error) {
+	c, err := i.executeBlock(stmt.Body, NewEnvironment(WithEnclosing(i.env)))
+
+	// Exceeded execution limits can't be caught.
+	if e, ok := err.(RuntimeError); ok && e.limit == nil && stmt.CatchBody != nil {
+		env := NewEnvironment(WithEnclosing(i.env))
+		env.Define(stmt.CatchName.Lexeme, e.Value())
+		c, err = i.executeBlock(stmt.CatchBody, env)
+	}
+
+	if stmt.FinallyBody != nil {
+		// A finally block that completes abruptly replaces how the try
+		// statement completes. Otherwise the value being returned, if any,
+		// must survive the calls the block makes.
+		value := i.returnValue
+		fc, ferr := i.executeBlock(stmt.FinallyBody, NewEnvironment(WithEnclosing(i.env)))
+		if ferr != nil || fc != normal {
+			return fc, ferr
+		}
+		i.returnValue = value
 	}
 
-	ret := Return{value: value}
-	panic(ret)
+	return c, err
 }
 
-func (i *Interpreter) VisitVarStmt(stmt *parser.VarStmt) interface{} {
+func (i *Interpreter) VisitVarStmt(stmt *parser.VarStmt) (interface{}, error) {
 	var value interface{}
 	if stmt.Initializer != nil {
-		value = i.evaluate(stmt.Initializer)
+		var err error
+		if value, err = i.evaluate(stmt.Initializer); err != nil {
+			return nil, err
+		}
 	}
 	i.env.Define(stmt.Name.Lexeme, value)
-	return nil
+	return nil, nil
 }
 
-func (i *Interpreter) VisitWhileStmt(stmt *parser.WhileStmt) interface{} {
-	for i.isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.Body)
+func (i *Interpreter) VisitWhileStmt(stmt *parser.WhileStmt) (interface{}, error) {
+	for {
+		cond, err := i.evaluate(stmt.Condition)
+		if err != nil {
+			return nil, err
+		}
+		if !i.isTruthy(cond) {
+			return nil, nil
+		}
+
+		if err := i.checkCancelled(stmt.Span()); err != nil {
+			return nil, err
+		}
+
+		c, err := i.execute(stmt.Body)
+		if err != nil || c == returning {
+			return c, err
+		}
+		if c == breaking {
+			return nil, nil
+		}
+
+		if stmt.Increment != nil {
+			if _, err := i.evaluate(stmt.Increment); err != nil {
+				return nil, err
+			}
+		}
 	}
-	return nil
 }
 
-func (i *Interpreter) VisitBlockStmt(stmt *parser.BlockStmt) interface{} {
+func (i *Interpreter) VisitBreakStmt(stmt *parser.BreakStmt) (interface{}, error) {
+	return breaking, nil
+}
+
+func (i *Interpreter) VisitContinueStmt(stmt *parser.ContinueStmt) (interface{}, error) {
+	return continuing, nil
+}
+
+func (i *Interpreter) VisitBlockStmt(stmt *parser.BlockStmt) (interface{}, error) {
 	env := NewEnvironment(WithEnclosing(i.env))
-	i.executeBlock(stmt.Statements, env)
-	return nil
+	return i.executeBlock(stmt.Statements, env)
 }
 
-func (i *Interpreter) VisitClassStmt(stmt *parser.ClassStmt) interface{} {
+func (i *Interpreter) VisitClassStmt(stmt *parser.ClassStmt) (interface{}, error) {
 	var superclass *Class
 
 	if stmt.Superclass != nil {
-		class := i.evaluate(stmt.Superclass)
+		class, err := i.evaluate(stmt.Superclass)
+		if err != nil {
+			return nil, err
+		}
+
 		var ok bool
 		superclass, ok = class.(*Class)
 		if !ok {
-			err := RuntimeError{token: stmt.Superclass.Name, msg: "Superclass must be a class."}
-			panic(err)
+			return nil, RuntimeError{token: stmt.Superclass.Name, msg: "Superclass must be a class."}
 		}
 	}
 
-	i.env.Define(stmt.Name.Lexeme, nil)
-
 	if stmt.Superclass != nil {
 		i.env = NewEnvironment(WithEnclosing(i.env))
 		i.env.Define("super", superclass)
@@ -297,7 +689,7 @@ func (i *Interpreter) VisitClassStmt(stmt *parser.ClassStmt) interface{} {
 
 	methods := make(map[string]*Function)
 	for _, m := range stmt.Methods {
-		fn := NewFunction(m, i.env, m.Name.Lexeme == "init")
+		fn := NewFunction(m, i.env, i.globals, m.Name.Lexeme == "init")
 		methods[m.Name.Lexeme] = fn
 	}
 
@@ -307,61 +699,144 @@ func (i *Interpreter) VisitClassStmt(stmt *parser.ClassStmt) interface{} {
 		i.env = i.env.enclosing
 	}
 
-	i.env.Assign(stmt.Name, class)
-	return nil
+	// Methods only look the class up once they are called, so it can be
+	// defined after them without taking a slot out of declaration order.
+	i.env.Define(stmt.Name.Lexeme, class)
+	return nil, nil
+}
+
+// importModule returns the module named by the path token, loading and
+// running it first if this is its first import.
+func (i *Interpreter) importModule(path scanner.Token) (*Module, error) {
+	if i.loader == nil {
+		return nil, RuntimeError{token: path, msg: "Imports are not supported here."}
+	}
+
+	var dir string
+	if i.module != nil {
+		dir = i.module.dir()
+	}
+
+	file := path.Literal.(string)
+	abs, err := i.loader.Find(file, dir)
+	if err != nil {
+		return nil, RuntimeError{token: path, msg: "Can't find module '" + file + "'."}
+	}
+
+	if module, ok := i.modules[abs]; ok {
+		if !module.loaded {
+			return nil, RuntimeError{token: path, msg: "Import cycle: module '" + file + "' is still being loaded."}
+		}
+		return module, nil
+	}
+
+	stmts, err := i.loader.Load(abs)
+	if err != nil {
+		return nil, RuntimeError{token: path, msg: "Can't load module '" + file + "': " + err.Error()}
+	}
+
+	module := NewModule(moduleName(abs), abs, NewGlobalEnvironment(WithEnclosing(i.builtins)))
+	i.modules[abs] = module
+	if err := i.executeModule(module, stmts); err != nil {
+		// A later import, after the error is caught, tries again.
+		delete(i.modules, abs)
+		return nil, err
+	}
+	module.loaded = true
+
+	return module, nil
+}
+
+// moduleName returns the name of the module in the file at path.
+func moduleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func (i *Interpreter) executeModule(module *Module, stmts []parser.Stmt) error {
+	preGlobals, preModule := i.globals, i.module
+	i.globals, i.module = module.globals, module
+
+	_, err := i.executeBlock(stmts, module.globals)
+	i.globals, i.module = preGlobals, preModule
+	return err
+}
+
+// local is where a resolved variable lives: in slot of the environment
+// depth scopes out from the one it is referenced in.
+type local struct {
+	depth, slot int
 }
 
-func (i *Interpreter) Resolve(expr parser.Expr, depth int) {
-	i.locals[expr] = depth
+func (i *Interpreter) Resolve(expr parser.Expr, depth, slot int) {
+	i.locals[expr] = local{depth: depth, slot: slot}
 }
 
-func (i *Interpreter) lookUpVariable(name scanner.Token, expr parser.Expr) interface{} {
-	distance, ok := i.locals[expr]
+func (i *Interpreter) lookUpVariable(name scanner.Token, expr parser.Expr) (interface{}, error) {
+	local, ok := i.locals[expr]
 	if ok {
-		return i.env.GetAt(distance, name.Lexeme)
+		return i.env.GetAt(local.depth, local.slot), nil
 	} else {
 		return i.globals.Get(name)
 	}
 }
 
-func (i *Interpreter) evaluate(expr parser.Expr) interface{} {
+func (i *Interpreter) evaluate(expr parser.Expr) (interface{}, error) {
 	return expr.Accept(i)
 }
 
-func (i *Interpreter) execute(stmt parser.Stmt) {
-	stmt.Accept(i)
+func (i *Interpreter) execute(stmt parser.Stmt) (completion, error) {
+	if err := i.step(stmt.Span()); err != nil {
+		return normal, err
+	}
+	if i.hook != nil {
+		if err := i.hook.Statement(i, stmt); err != nil {
+			return normal, err
+		}
+	}
+
+	c, err := stmt.Accept(i)
+	if c == nil {
+		return normal, err
+	}
+	return c.(completion), err
 }
 
-func (i *Interpreter) executeBlock(stmts []parser.Stmt, env *Environment) {
+// executeBlock runs stmts in env until one of them completes other than
+// normally, and returns how the block completed.
+func (i *Interpreter) executeBlock(stmts []parser.Stmt, env *Environment) (completion, error) {
 	preEnv := i.env
-	defer func() { i.env = preEnv }()
-
 	i.env = env
+
 	for _, stmt := range stmts {
-		i.execute(stmt)
+		if c, err := i.execute(stmt); err != nil || c != normal {
+			i.env = preEnv
+			return c, err
+		}
 	}
+
+	i.env = preEnv
+	return normal, nil
 }
 
-func (i *Interpreter) checkNumberOperand(operator scanner.Token, object interface{}) {
+func (i *Interpreter) checkNumberOperand(operator scanner.Token, object interface{}) error {
 	if _, ok := object.(float64); !ok {
-		err := RuntimeError{token: operator, msg: "Operand must be a number."}
-		panic(err)
+		return RuntimeError{token: operator, msg: "Operand must be a number."}
 	}
+	return nil
 }
 
-func (i *Interpreter) checkNumberOperands(operator scanner.Token, left, right interface{}) {
+func (i *Interpreter) checkNumberOperands(operator scanner.Token, left, right interface{}) error {
 	_, ok1 := left.(float64)
 	v2, ok2 := right.(float64)
 
 	if !ok1 || !ok2 {
-		err := RuntimeError{token: operator, msg: "Operands must be numbers."}
-		panic(err)
+		return RuntimeError{token: operator, msg: "Operands must be numbers."}
 	}
 
 	if operator.Type == scanner.SLASH && v2 == 0 {
-		err := RuntimeError{token: operator, msg: "Division by zero."}
-		panic(err)
+		return RuntimeError{token: operator, msg: "Division by zero."}
 	}
+	return nil
 }
 
 func (i *Interpreter) isTruthy(object interface{}) bool {
@@ -380,7 +855,7 @@ func (i *Interpreter) isEqual(a, b interface{}) bool {
 	return a == b
 }
 
-func (i *Interpreter) stringify(object interface{}) string {
+func stringify(object interface{}) string {
 	if object == nil {
 		return "nil"
 	}
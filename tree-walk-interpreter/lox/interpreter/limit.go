@@ -0,0 +1,77 @@
+package interpreter
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+// defaultMaxCallDepth keeps deep recursion well clear of overflowing the
+// Go stack.
+const defaultMaxCallDepth = 10000
+
+// Errors that runtime errors raised for exceeding an execution limit
+// unwrap to. Unlike other runtime errors, these can't be caught by Lox code.
+var (
+	ErrStackOverflow = errors.New("stack overflow")
+	ErrStepLimit     = errors.New("step limit exceeded")
+	ErrCancelled     = errors.New("cancelled")
+)
+
+// WithMaxSteps limits the number of statements a single Execute may run.
+// A limit of zero or less means no limit, which is the default.
+func WithMaxSteps(n int) InterpreterOption {
+	return func(i *Interpreter) {
+		i.maxSteps = n
+	}
+}
+
+// WithMaxCallDepth limits how deeply calls may nest. A limit of zero or
+// less means no limit.
+func WithMaxCallDepth(n int) InterpreterOption {
+	return func(i *Interpreter) {
+		i.maxCallDepth = n
+	}
+}
+
+// WithTimeout limits the wall-clock time a single Execute may take.
+func WithTimeout(d time.Duration) InterpreterOption {
+	return func(i *Interpreter) {
+		i.timeout = d
+	}
+}
+
+// step counts the statement at span against the step limit and checks
+// whether execution has been cancelled.
+func (i *Interpreter) step(span scanner.Span) error {
+	i.steps++
+	if i.maxSteps > 0 && i.steps > i.maxSteps {
+		return RuntimeError{token: at(span), msg: "Step limit exceeded.", limit: ErrStepLimit}
+	}
+
+	return i.checkCancelled(span)
+}
+
+func (i *Interpreter) checkCancelled(span scanner.Span) error {
+	select {
+	case <-i.done:
+		return RuntimeError{token: at(span), msg: "Execution cancelled.", limit: ErrCancelled}
+	default:
+		return nil
+	}
+}
+
+// at returns a token to report errors at span by, for errors that aren't
+// caused by any one token.
+func at(span scanner.Span) scanner.Token {
+	return scanner.Token{Line: span.Start.Line, Span: span}
+}
+
+func (i *Interpreter) enterCall(paren scanner.Token) error {
+	if i.maxCallDepth > 0 && i.callDepth >= i.maxCallDepth {
+		return RuntimeError{token: paren, msg: "Stack overflow.", limit: ErrStackOverflow}
+	}
+	i.callDepth++
+	return nil
+}
@@ -0,0 +1,72 @@
+package interpreter
+
+import (
+	"math"
+	"strings"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+type List struct {
+	elements []interface{}
+}
+
+func NewList(elements []interface{}) *List {
+	return &List{elements: elements}
+}
+
+func (l *List) Get(bracket scanner.Token, index interface{}) (interface{}, error) {
+	j, err := l.index(bracket, index)
+	if err != nil {
+		return nil, err
+	}
+	return l.elements[j], nil
+}
+
+func (l *List) Set(bracket scanner.Token, index interface{}, value interface{}) error {
+	j, err := l.index(bracket, index)
+	if err != nil {
+		return err
+	}
+	l.elements[j] = value
+	return nil
+}
+
+func (l *List) Len() int {
+	return len(l.elements)
+}
+
+func (l *List) String() string {
+	elements := make([]string, 0, len(l.elements))
+	for _, e := range l.elements {
+		elements = append(elements, stringify(e))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (l *List) push(value interface{}) {
+	l.elements = append(l.elements, value)
+}
+
+func (l *List) pop() (interface{}, bool) {
+	if len(l.elements) == 0 {
+		return nil, false
+	}
+
+	last := l.elements[len(l.elements)-1]
+	l.elements = l.elements[:len(l.elements)-1]
+	return last, true
+}
+
+func (l *List) index(bracket scanner.Token, index interface{}) (int, error) {
+	n, ok := index.(float64)
+	if !ok || n != math.Trunc(n) {
+		return 0, RuntimeError{token: bracket, msg: "List index must be an integer."}
+	}
+
+	if n < 0 || n >= float64(len(l.elements)) {
+		return 0, RuntimeError{token: bracket, msg: "List index out of range."}
+	}
+
+	return int(n), nil
+}
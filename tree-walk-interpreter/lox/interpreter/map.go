@@ -0,0 +1,69 @@
+package interpreter
+
+import (
+	"strings"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Map is a hash map keyed by numbers, strings, booleans or nil. Keys
+// compare the same way Interpreter.isEqual compares them, and are kept in
+// insertion order so that printing and iteration are deterministic.
+type Map struct {
+	entries map[interface{}]interface{}
+	keys    []interface{}
+}
+
+func NewMap() *Map {
+	entries := make(map[interface{}]interface{})
+	return &Map{entries: entries, keys: make([]interface{}, 0)}
+}
+
+func (m *Map) Get(token scanner.Token, key interface{}) (interface{}, error) {
+	if err := checkHashable(token, key); err != nil {
+		return nil, err
+	}
+	return m.entries[key], nil
+}
+
+func (m *Map) Set(token scanner.Token, key interface{}, value interface{}) error {
+	if err := checkHashable(token, key); err != nil {
+		return err
+	}
+	m.set(key, value)
+	return nil
+}
+
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+func (m *Map) Keys() []interface{} {
+	keys := make([]interface{}, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+func (m *Map) String() string {
+	entries := make([]string, 0, len(m.keys))
+	for _, k := range m.keys {
+		entries = append(entries, stringify(k)+": "+stringify(m.entries[k]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func (m *Map) set(key interface{}, value interface{}) {
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+}
+
+func checkHashable(token scanner.Token, key interface{}) error {
+	switch key.(type) {
+	case nil, bool, float64, string:
+		return nil
+	}
+
+	return RuntimeError{token: token, msg: "Map key must be a number, string, boolean or nil."}
+}
@@ -0,0 +1,44 @@
+package interpreter
+
+import (
+	"path/filepath"
+
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Loader locates and compiles the files named by import statements.
+type Loader interface {
+	// Find returns the absolute path of the module imported as path from a
+	// module in directory dir, which is empty for the main script.
+	Find(path, dir string) (string, error)
+	// Load scans, parses and resolves the module at the absolute path.
+	Load(path string) ([]parser.Stmt, error)
+}
+
+type Module struct {
+	name    string
+	path    string
+	globals *Environment
+	loaded  bool
+}
+
+func NewModule(name, path string, globals *Environment) *Module {
+	return &Module{name: name, path: path, globals: globals}
+}
+
+func (m *Module) Get(name scanner.Token) (interface{}, error) {
+	if v, ok := m.globals.vars[name.Lexeme]; ok {
+		return v, nil
+	}
+
+	return nil, RuntimeError{token: name, msg: "Module '" + m.name + "' has no export '" + name.Lexeme + "'."}
+}
+
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
+
+func (m *Module) dir() string {
+	return filepath.Dir(m.path)
+}
@@ -0,0 +1,257 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Native is a Go function exposed to Lox. Arguments are converted from Lox
+// values to the function's parameter types, and results back to Lox values.
+// A non-nil error result is raised as a runtime error.
+type Native struct {
+	name string
+	fn   reflect.Value
+}
+
+// NewNative wraps fn, which must be a function returning nothing, a value,
+// an error, or a value and an error. Variadic functions become natives that
+// take any number of trailing arguments.
+func NewNative(name string, fn interface{}) (*Native, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("native %s: %T is not a function", name, fn)
+	}
+
+	t := v.Type()
+	switch {
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("native %s: too many results", name)
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("native %s: second result must be an error", name)
+	}
+
+	return &Native{name: name, fn: v}, nil
+}
+
+func (n *Native) Arity() int {
+	t := n.fn.Type()
+	if t.IsVariadic() {
+		return t.NumIn() - 1
+	}
+	return t.NumIn()
+}
+
+// Variadic reports whether the native accepts more than Arity arguments.
+func (n *Native) Variadic() bool {
+	return n.fn.Type().IsVariadic()
+}
+
+func (n *Native) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	t := n.fn.Type()
+
+	in := make([]reflect.Value, len(arguments))
+	for i, arg := range arguments {
+		var paramType reflect.Type
+		if t.IsVariadic() && i >= t.NumIn()-1 {
+			paramType = t.In(t.NumIn() - 1).Elem()
+		} else {
+			paramType = t.In(i)
+		}
+
+		v, err := toGo(arg, paramType)
+		if err != nil {
+			return nil, RuntimeError{msg: fmt.Sprintf("Argument %d to '%s' %s.", i+1, n.name, err)}
+		}
+		in[i] = v
+	}
+
+	out := n.fn.Call(in)
+
+	if len(out) > 0 && t.Out(len(out)-1) == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, RuntimeError{msg: err.Error()}
+		}
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	value, err := fromGo(out[0])
+	if err != nil {
+		return nil, RuntimeError{msg: fmt.Sprintf("Result of '%s' %s.", n.name, err)}
+	}
+	return value, nil
+}
+
+func (n *Native) String() string {
+	return "<native fn>"
+}
+
+// toGo converts a Lox value to a Go value of type t.
+func toGo(value interface{}, t reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("must be %s", typeName(t))
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if n, ok := value.(float64); ok {
+			return reflect.ValueOf(n).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := value.(float64); ok {
+			return toInteger(n, t)
+		}
+	case reflect.Slice:
+		if list, ok := value.(*List); ok {
+			s := reflect.MakeSlice(t, 0, list.Len())
+			for j, e := range list.elements {
+				ev, err := toGo(e, t.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("has an element at index %d that %s", j, err)
+				}
+				s = reflect.Append(s, ev)
+			}
+			return s, nil
+		}
+	case reflect.Map:
+		if m, ok := value.(*Map); ok {
+			mv := reflect.MakeMapWithSize(t, m.Len())
+			for _, k := range m.keys {
+				kv, err := toGo(k, t.Key())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("has a key %s that %s", stringify(k), err)
+				}
+				vv, err := toGo(m.entries[k], t.Elem())
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("has a value at key %s that %s", stringify(k), err)
+				}
+				mv.SetMapIndex(kv, vv)
+			}
+			return mv, nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("must be %s", typeName(t))
+}
+
+// toInteger converts n to a Go value of the integer type t, failing if n
+// isn't a whole number that t can hold.
+func toInteger(n float64, t reflect.Type) (reflect.Value, error) {
+	if math.IsInf(n, 0) || math.IsNaN(n) || n != math.Trunc(n) {
+		return reflect.Value{}, fmt.Errorf("must be an integer (%s), not %s", t, stringify(n))
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n < 0 {
+			return reflect.Value{}, fmt.Errorf("must be a non-negative integer (%s), not %s", t, stringify(n))
+		}
+		// Past 2^64 the conversion to uint64 is undefined, so check that
+		// first.
+		if n >= 1<<64 || v.OverflowUint(uint64(n)) {
+			return reflect.Value{}, fmt.Errorf("is out of range for %s: %s", t, stringify(n))
+		}
+		v.SetUint(uint64(n))
+	default:
+		if n < -(1<<63) || n >= 1<<63 || v.OverflowInt(int64(n)) {
+			return reflect.Value{}, fmt.Errorf("is out of range for %s: %s", t, stringify(n))
+		}
+		v.SetInt(int64(n))
+	}
+	return v, nil
+}
+
+// fromGo converts a Go value to a Lox value. Values with no Lox counterpart,
+// such as the interpreter's own types, are passed through as they are.
+func fromGo(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Interface {
+			return fromGo(v.Elem())
+		}
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := fromGo(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, e)
+		}
+		return NewList(elements), nil
+	case reflect.Map:
+		m := NewMap()
+		iter := v.MapRange()
+		for iter.Next() {
+			k, err := fromGo(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case nil, bool, float64, string:
+			default:
+				return nil, errors.New("has a map key that isn't a number, string, boolean or nil")
+			}
+			e, err := fromGo(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m.set(k, e)
+		}
+		return m, nil
+	}
+
+	return v.Interface(), nil
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map:
+		return "a map"
+	}
+	return "a " + t.String()
+}
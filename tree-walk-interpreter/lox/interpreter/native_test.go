@@ -0,0 +1,45 @@
+package interpreter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNativeArguments(t *testing.T) {
+	m := NewMap()
+	m.set("a", 1.0)
+	m.set("b", -1.0)
+
+	for _, tt := range []struct {
+		fn   interface{}
+		arg  interface{}
+		want string
+	}{
+		{func(int8) {}, 127.0, ""},
+		{func(int8) {}, 128.0, "Argument 1 to 'f' is out of range for int8: 128."},
+		{func(int64) {}, -9223372036854775808.0, ""},
+		{func(int64) {}, 9223372036854775808.0, "Argument 1 to 'f' is out of range for int64: 9223372036854775808."},
+		{func(uint8) {}, 255.0, ""},
+		{func(uint8) {}, 256.0, "Argument 1 to 'f' is out of range for uint8: 256."},
+		{func(uint) {}, -1.0, "Argument 1 to 'f' must be a non-negative integer (uint), not -1."},
+		{func(uint64) {}, 1e20, "Argument 1 to 'f' is out of range for uint64: 100000000000000000000."},
+		{func(int) {}, 1.5, "Argument 1 to 'f' must be an integer (int), not 1.500000."},
+		{func(int) {}, math.Inf(1), "Argument 1 to 'f' must be an integer (int), not +Inf."},
+		{func(uint) {}, math.NaN(), "Argument 1 to 'f' must be an integer (uint), not NaN."},
+		{func([]uint8) {}, NewList([]interface{}{1.0, 300.0}), "Argument 1 to 'f' has an element at index 1 that is out of range for uint8: 300."},
+		{func(map[string]uint) {}, m, `Argument 1 to 'f' has a value at key "b" that must be a non-negative integer (uint), not -1.`},
+		{func(map[int]int) {}, m, `Argument 1 to 'f' has a key "a" that must be an integer.`},
+	} {
+		native, err := NewNative("f", tt.fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if _, err := native.Call(nil, []interface{}{tt.arg}); err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("%T(%v): got error %q, want %q", tt.fn, stringify(tt.arg), got, tt.want)
+		}
+	}
+}
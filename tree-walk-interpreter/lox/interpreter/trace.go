@@ -0,0 +1,93 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Frame is one call in the traceback of a runtime error.
+type Frame struct {
+	// Function is the name of the function, or empty for the top level of
+	// the script and for anonymous functions.
+	Function string
+	// Class is the name of the class for methods, and empty otherwise.
+	Class string
+	// Line is the line the frame was executing: the line of the call to
+	// the next frame, or of the error in the innermost frame.
+	Line int
+	// Script is set for the frame of the top level of the script.
+	Script bool
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("[line %d] in %s", f.Line, f.Name())
+}
+
+// Name names the frame's function as tracebacks do.
+func (f Frame) Name() string {
+	switch {
+	case f.Script:
+		return "script"
+	case f.Function == "":
+		return "<anonymous>()"
+	case f.Class != "":
+		return f.Class + "." + f.Function + "()"
+	}
+	return f.Function + "()"
+}
+
+// frame is a call in progress. site is the call in the calling frame, and
+// env the calling frame's environment, or both are nil if the host made the
+// call.
+type frame struct {
+	function *Function
+	site     *scanner.Token
+	env      *Environment
+}
+
+func (f frame) line() int {
+	if f.site == nil {
+		return 0
+	}
+	return f.site.Line
+}
+
+func (i *Interpreter) pushFrame(function *Function) {
+	var env *Environment
+	if i.callSite != nil {
+		env = i.env
+	}
+	i.frames = append(i.frames, frame{function: function, site: i.callSite, env: env})
+}
+
+func (i *Interpreter) popFrame() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
+// traceback returns the call stack, innermost frame last, for an error
+// raised on line. Calls made by the host have no script frame beneath them.
+func (i *Interpreter) traceback(line int) []Frame {
+	trace := make([]Frame, 0, len(i.frames)+1)
+	if len(i.frames) == 0 || i.frames[0].site != nil {
+		trace = append(trace, Frame{Script: true})
+	}
+
+	for _, f := range i.frames {
+		if len(trace) > 0 {
+			trace[len(trace)-1].Line = f.line()
+		}
+
+		frame := Frame{}
+		if name := f.function.declaration.Name; name.Type == scanner.IDENTIFIER {
+			frame.Function = name.Lexeme
+		}
+		if f.function.class != nil {
+			frame.Class = f.function.class.name
+		}
+		trace = append(trace, frame)
+	}
+
+	trace[len(trace)-1].Line = line
+	return trace
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/lint"
+)
+
+// lintFiles runs `lox lint` with args, the arguments after the command. It
+// checks the named files and the .lox files in the named directories, or
+// standard input if none are named. It exits with 65 if there are errors
+// and 1 if there are only warnings.
+func lintFiles(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		rules := make([]string, 0, len(lint.Rules))
+		for rule := range lint.Rules {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		fmt.Fprintln(flags.Output(), "Usage: lox lint [path]...")
+		fmt.Fprintln(flags.Output(), "Rules:", strings.Join(rules, ", "))
+	}
+	flags.Parse(args)
+
+	var all diag.List
+	check := func(path string, src []byte) {
+		diags := lint.Source(path, string(src))
+		for _, d := range diags {
+			d.Render(os.Stderr)
+		}
+		all = append(all, diags...)
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		check("<stdin>", src)
+	}
+	forEachFile(flags.Args(), func(path string, src []byte, _ fs.FileMode) {
+		check(path, src)
+	})
+
+	if all.HasErrors() {
+		os.Exit(65)
+	}
+	if len(all) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,168 @@
+// Package lint warns about Lox code that is legal but likely a mistake,
+// using the checks of the resolver.
+//
+// Comments turn rules off. A comment
+//
+//	// lox:ignore unused shadow
+//
+// turns the named rules off for the line it ends, or for the next line if
+// it is on a line of its own, and the comments
+//
+//	// lox:disable unreachable
+//	// lox:enable unreachable
+//
+// turn them off and back on from their lines onwards. Without names, they
+// apply to every rule.
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/resolver"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Rules maps the names that comments give the rules to the codes of the
+// warnings they give.
+var Rules = map[string]diag.Code{
+	"unused":           diag.UnusedVariable,
+	"unused-param":     diag.UnusedParameter,
+	"shadow":           diag.ShadowedVariable,
+	"unreachable":      diag.UnreachableCode,
+	"undefined-global": diag.UndefinedGlobal,
+	"this-in-function": diag.ThisInFunction,
+}
+
+// Source checks src, read from the file name, and returns its errors and
+// warnings in source order. Programs with syntax errors aren't warned
+// about, since the declarations that fail to parse would be missed.
+func Source(name, src string) diag.List {
+	tokens := scanner.New(src, scanner.WithFileName(name)).ScanTokens()
+	stmts, diags := parser.NewParser(tokens).Parse()
+
+	if !diags.HasErrors() {
+		r := resolver.NewResolver(nil, resolver.WithWarnings(Builtins()...))
+		diags = Suppress(append(diags, r.Resolve(stmts)...), tokens)
+	}
+	diags.Sort()
+	return diags
+}
+
+// builtins is found once, as it takes a whole interpreter to list.
+var builtins = interpreter.NewInterpreter(nil).Builtins()
+
+// Builtins returns the names of the globals that every program starts
+// with, in order. The caller must not modify them.
+func Builtins() []string {
+	return builtins
+}
+
+// directive is a comment that turns rules off or on.
+type directive struct {
+	verb string
+	// line is the first line the directive applies to.
+	line  int
+	all   bool
+	codes []diag.Code
+}
+
+func (d *directive) applies(code diag.Code) bool {
+	if d.all {
+		return true
+	}
+	for _, c := range d.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Suppress returns diags less the warnings that the comments among tokens
+// turn off, plus warnings about the comments that name unknown rules.
+func Suppress(diags diag.List, tokens []scanner.Token) diag.List {
+	var directives []directive
+	for _, token := range tokens {
+		for _, c := range token.Comments {
+			if d, ok := parseDirective(c, &diags); ok {
+				directives = append(directives, d)
+			}
+		}
+	}
+	if len(directives) == 0 {
+		return diags
+	}
+
+	kept := diags[:0]
+	for _, d := range diags {
+		if d.Severity != diag.Warning || !suppressed(d, directives) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+func parseDirective(c scanner.Comment, diags *diag.List) (directive, bool) {
+	text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+	if !strings.HasPrefix(text, "lox:") {
+		return directive{}, false
+	}
+	fields := strings.Fields(strings.ReplaceAll(strings.TrimPrefix(text, "lox:"), ",", " "))
+	if len(fields) == 0 {
+		return directive{}, false
+	}
+
+	d := directive{verb: fields[0], line: c.Span.Start.Line, all: len(fields) == 1}
+	switch d.verb {
+	case "ignore":
+		line := c.Span.File.Line(c.Span.Start.Line)
+		if strings.TrimSpace(line[:c.Span.Start.Column-1]) == "" {
+			d.line++
+		}
+	case "disable", "enable":
+	default:
+		diags.Add(diag.UnknownRule, diag.Warning, c.Span, fmt.Sprintf("Unknown lint directive 'lox:%s'.", d.verb))
+		return directive{}, false
+	}
+
+	for _, rule := range fields[1:] {
+		code, ok := Rules[rule]
+		if !ok {
+			diags.Add(diag.UnknownRule, diag.Warning, c.Span, fmt.Sprintf("Unknown lint rule '%s'.", rule))
+			continue
+		}
+		d.codes = append(d.codes, code)
+	}
+	return d, true
+}
+
+// suppressed reports whether directives, in source order, turn off the
+// warning d.
+func suppressed(d diag.Diagnostic, directives []directive) bool {
+	line := d.Span.Start.Line
+	off := false
+	for _, dir := range directives {
+		if !dir.applies(d.Code) {
+			continue
+		}
+		switch dir.verb {
+		case "ignore":
+			if dir.line == line {
+				return true
+			}
+		case "disable":
+			if dir.line <= line {
+				off = true
+			}
+		case "enable":
+			if dir.line <= line {
+				off = false
+			}
+		}
+	}
+	return off
+}
@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fosmjo/lox/diag"
+)
+
+func TestSource(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+		want []diag.Code
+	}{
+		{
+			"clean",
+			"fun f(a) { var b = a; return b; }\nprint f(1);",
+			nil,
+		},
+		{
+			"unused variable",
+			"fun f() { var a = 1; }",
+			[]diag.Code{diag.UnusedVariable},
+		},
+		{
+			"unused parameter",
+			"fun f(a) {}",
+			[]diag.Code{diag.UnusedParameter},
+		},
+		{
+			"global never warned unused",
+			"var a = 1;",
+			nil,
+		},
+		{
+			"shadowed local",
+			"fun f() {\n  var a = 1;\n  { var a = 2; print a; }\n  print a;\n}",
+			[]diag.Code{diag.ShadowedVariable},
+		},
+		{
+			"shadowed parameter",
+			"fun f(a) {\n  { var a = 2; print a; }\n  print a;\n}",
+			[]diag.Code{diag.ShadowedVariable},
+		},
+		{
+			"unreachable",
+			"fun f() {\n  return;\n  print 1;\n}",
+			[]diag.Code{diag.UnreachableCode},
+		},
+		{
+			"ignore on the same line",
+			"fun f() {\n  var a = 1; // lox:ignore unused\n}",
+			nil,
+		},
+		{
+			"ignore on the line before",
+			"fun f() {\n  // lox:ignore unused\n  var a = 1;\n  var b = 2;\n}",
+			[]diag.Code{diag.UnusedVariable},
+		},
+		{
+			"ignore of another rule",
+			"fun f() {\n  var a = 1; // lox:ignore shadow\n}",
+			[]diag.Code{diag.UnusedVariable},
+		},
+		{
+			"ignore of every rule",
+			"fun f(a) { // lox:ignore\n  var b = 1; return; print 2;\n}",
+			[]diag.Code{diag.UnusedVariable, diag.UnreachableCode},
+		},
+		{
+			"disable and enable",
+			"fun f() {\n  // lox:disable unused\n  var a = 1;\n  var b = 2;\n  // lox:enable unused\n  var c = 3;\n}",
+			[]diag.Code{diag.UnusedVariable},
+		},
+		{
+			"unknown rule",
+			"fun f() {\n  var a = 1; // lox:ignore unused, nope\n}",
+			[]diag.Code{diag.UnknownRule},
+		},
+		{
+			"unknown directive",
+			"// lox:bogus\nprint 1;",
+			[]diag.Code{diag.UnknownRule},
+		},
+		{
+			"error",
+			"fun f() { var a = 1; }\nprint this;",
+			[]diag.Code{diag.UnusedVariable, diag.InvalidThis},
+		},
+		{
+			"syntax error without warnings",
+			"fun f() { var a = 1; }\nprint ;",
+			[]diag.Code{diag.ExpectExpression},
+		},
+	} {
+		var got []diag.Code
+		for _, d := range Source("test.lox", tt.src) {
+			got = append(got, d.Code)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestLintExitCodes runs `lox lint` in a copy of the test binary, since it
+// exits, and checks the code it exits with.
+func TestLintExitCodes(t *testing.T) {
+	if file := os.Getenv("LOX_LINT_FILE"); file != "" {
+		lintFiles([]string{file})
+		os.Exit(0)
+	}
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		src  string
+		code int
+	}{
+		{"clean", "fun f(a) { return a; }\nprint f(1);", 0},
+		{"warning", "fun f() { var a = 1; }", 1},
+		{"suppressed", "fun f() { var a = 1; } // lox:ignore unused", 0},
+		{"error", "fun f() { var a = 1; }\nprint this;", 65},
+	} {
+		file := filepath.Join(dir, tt.name+".lox")
+		if err := os.WriteFile(file, []byte(tt.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestLintExitCodes$")
+		cmd.Env = append(os.Environ(), "LOX_LINT_FILE="+file)
+		err := cmd.Run()
+		code := 0
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			code = exit.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("%s: exited with %d, want %d", tt.name, code, tt.code)
+		}
+	}
+}
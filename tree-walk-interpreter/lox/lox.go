@@ -2,28 +2,55 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 
-	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/bytecode"
+	"github.com/fosmjo/lox/dap"
+	"github.com/fosmjo/lox/debugger"
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/lox"
+	"github.com/fosmjo/lox/lsp"
 	"github.com/fosmjo/lox/parser"
-	"github.com/fosmjo/lox/resolver"
 	"github.com/fosmjo/lox/scanner"
 )
 
 type Lox struct {
 	hadError        bool
 	hadRuntimeError bool
-	interpreter     *interpreter.Interpreter
+	vm              *lox.VM
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
 }
 
-func NewLox() *Lox {
-	return &Lox{
+// NewLox returns a Lox that runs scripts with opts, reading and printing
+// through opts.Stdin and opts.Stdout and reporting errors to stderr.
+func NewLox(opts lox.Options, stderr io.Writer) *Lox {
+	l := &Lox{
 		hadError:        false,
 		hadRuntimeError: false,
+		stdin:           opts.Stdin,
+		stdout:          opts.Stdout,
+		stderr:          stderr,
 	}
+	opts.Errors = l
+	vm, err := lox.New(opts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	l.vm = vm
+	return l
 }
 
 func (lox *Lox) RunFile(file string) {
@@ -31,7 +58,7 @@ func (lox *Lox) RunFile(file string) {
 	if err != nil {
 		log.Fatalln(err)
 	}
-	lox.run(string(data))
+	lox.run(file, string(data))
 	if lox.hadError {
 		os.Exit(65)
 	}
@@ -40,77 +67,272 @@ func (lox *Lox) RunFile(file string) {
 	}
 }
 
+// DumpBytecode prints the bytecode of every function in file instead of
+// running it.
+func (lox *Lox) DumpBytecode(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	script, err := lox.vm.Compile(file, string(data))
+	if err != nil {
+		os.Exit(65)
+	}
+	bytecode.Disassemble(lox.stdout, script)
+}
+
+// DumpTokens prints the tokens of file, one per line, instead of running
+// it. Text that isn't a token is listed as an ERROR token and reported.
+func (lox *Lox) DumpTokens(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var diags diag.List
+	w := tabwriter.NewWriter(lox.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tLEXEME\tLITERAL\tLINE\tCOLUMN")
+	s := scanner.New(string(data), scanner.WithFileName(file))
+	for {
+		token := s.NextToken()
+		if token.Type == scanner.ERROR {
+			diags.Add(diag.InvalidToken, diag.Error, token.Span, token.Literal.(string))
+		}
+
+		var literal string
+		switch value := token.Literal.(type) {
+		case float64:
+			literal = strconv.FormatFloat(value, 'f', -1, 64)
+		case string:
+			literal = strconv.Quote(value)
+		}
+		lexeme := strings.ReplaceAll(token.Lexeme, "\n", `\n`)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", token.Type, lexeme, literal, token.Span.Start.Line, token.Span.Start.Column)
+		if token.Type == scanner.EOF {
+			break
+		}
+	}
+	w.Flush()
+
+	for _, d := range diags {
+		d.Render(lox.stderr)
+	}
+	if len(diags) > 0 {
+		os.Exit(65)
+	}
+}
+
+// DumpAST prints the syntax tree of file, as S-expressions or as JSON,
+// instead of running it.
+func (lox *Lox) DumpAST(file string, format astFormat) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	stmts, err := lox.vm.Parse(file, string(data))
+	if err != nil {
+		os.Exit(65)
+	}
+	if format == astJSON {
+		out, err := parser.EncodeJSON(file, stmts)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Fprintf(lox.stdout, "%s\n", out)
+		return
+	}
+	fmt.Fprint(lox.stdout, parser.AstPrinter{}.Print(stmts))
+}
+
 func (lox *Lox) RunPromt() {
-	fmt.Print("> ")
-	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Fprint(lox.stdout, "> ")
+	scanner := bufio.NewScanner(lox.stdin)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "" {
 			break
 		}
-		lox.run(line)
+		lox.run("", line)
 		lox.hadError = false
 		lox.hadRuntimeError = false
-		fmt.Print("> ")
+		fmt.Fprint(lox.stdout, "> ")
 	}
 	if err := scanner.Err(); err != nil {
-		fmt.Fprintln(os.Stderr, "reading standard input:", err)
+		fmt.Fprintln(lox.stderr, "reading standard input:", err)
 	}
 }
 
-func (lox *Lox) run(source string) {
-	s := scanner.New(source, lox)
-	tokens := s.ScanTokens()
-	parser := parser.NewParser(tokens, lox)
+func (lox *Lox) run(name, source string) {
+	// Errors have already been reported through lox by the time
+	// EvalSource returns them.
+	_, _ = lox.vm.EvalSource(context.Background(), name, source)
+}
 
-	statements, err := parser.Parse()
-	if err != nil {
-		return
+func (lox *Lox) Diagnostic(d diag.Diagnostic) {
+	d.Render(lox.stderr)
+	if d.Severity == diag.Error {
+		lox.hadError = true
 	}
+}
 
-	resolver := resolver.NewResolver(lox.interpreter, lox)
-	resolver.Resolve(statements)
+func (lox *Lox) RuntimeError(err *lox.RuntimeError) {
+	diag.Render(lox.stderr, "error", err.Message, err.Span)
+	printTrace(lox.stderr, err.Trace)
+	lox.hadRuntimeError = true
+}
 
-	// Stop if there was a resolution error.
-	if lox.hadError {
-		return
+// printTrace prints a traceback innermost last, collapsing runs of
+// identical frames such as those of a runaway recursion.
+func printTrace(w io.Writer, trace []lox.Frame) {
+	for j := 0; j < len(trace); {
+		k := j + 1
+		for k < len(trace) && trace[k] == trace[j] {
+			k++
+		}
+
+		fmt.Fprintln(w, trace[j])
+		if n := k - j - 1; n > 0 {
+			fmt.Fprintf(w, "[previous frame repeated %d more times]\n", n)
+		}
+		j = k
 	}
+}
+
+type pathList []string
 
-	lox.interpreter.Interpret(statements)
+func (p *pathList) String() string {
+	return strings.Join(*p, string(os.PathListSeparator))
 }
 
-func (lox *Lox) Error(line int, msg string) {
-	lox.report(line, "", msg)
+func (p *pathList) Set(path string) error {
+	*p = append(*p, path)
+	return nil
 }
 
-func (lox *Lox) ErrorWithToken(token scanner.Token, msg string) {
-	if token.Type == scanner.EOF {
-		lox.report(token.Line, " at end", msg)
-	} else {
-		lox.report(token.Line, " at '"+token.Lexeme+"'", msg)
-	}
+// astFormat is the format that -dump-ast asks for, if any. The flag may
+// be given alone for S-expressions or as -dump-ast=json.
+type astFormat string
+
+const (
+	astNone  astFormat = ""
+	astSexpr astFormat = "sexpr"
+	astJSON  astFormat = "json"
+)
+
+func (f *astFormat) String() string {
+	return string(*f)
 }
 
-func (lox *Lox) RuntimeError(err interpreter.RuntimeError) {
-	fmt.Fprintln(os.Stderr, err.Error()+"\n[line "+strconv.Itoa(err.Token().Line)+"]")
-	lox.hadRuntimeError = true
+func (f *astFormat) Set(s string) error {
+	switch s {
+	case "true", "sexpr":
+		*f = astSexpr
+	case "json":
+		*f = astJSON
+	case "false":
+		*f = astNone
+	default:
+		return errors.New("format must be sexpr or json")
+	}
+	return nil
 }
 
-func (lox *Lox) report(line int, where, msg string) {
-	fmt.Fprintf(os.Stderr, "line [%d], Error %s : %s\n", line, where, msg)
-	lox.hadError = true
+func (f *astFormat) IsBoolFlag() bool {
+	return true
 }
 
 func main() {
-	lox := NewLox()
-	lox.interpreter = interpreter.NewInterpreter(lox)
+	var searchPaths pathList
+	flag.Var(&searchPaths, "I", "add `dir` to the module search path")
+	useBytecode := flag.Bool("bytecode", false, "run on the bytecode VM instead of the tree-walk interpreter")
+	dumpTokens := flag.Bool("tokens", false, "print the tokens of script instead of running it")
+	dumpBytecode := flag.Bool("dump-bytecode", false, "print the bytecode of script instead of running it")
+	var dumpAST astFormat
+	flag.Var(&dumpAST, "dump-ast", "print the syntax tree of script, as S-expressions or `json`, instead of running it")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: lox [-bytecode | -tokens | -dump-ast[=json] | -dump-bytecode] [-I dir]... [script]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lox [-I dir]... debug script")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lox [-I dir]... dap")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lox lsp")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lox fmt [-l] [-d] [path]...")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lox lint [path]...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	dumping := *dumpTokens || *dumpBytecode || dumpAST != astNone
+
+	opts := lox.Options{
+		Stdin:    os.Stdin,
+		Stdout:   os.Stdout,
+		Bytecode: *useBytecode,
+	}
 
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: lox [script]")
+	switch flag.Arg(0) {
+	case "fmt":
+		checkCommandArgs(flag.NArg(), *useBytecode || dumping || len(searchPaths) > 0)
+		formatFiles(flag.Args()[1:])
+	case "lint":
+		checkCommandArgs(flag.NArg(), *useBytecode || dumping || len(searchPaths) > 0)
+		lintFiles(flag.Args()[1:])
+	case "lsp":
+		checkCommandArgs(1, *useBytecode || dumping)
+		serve(lsp.NewServer(os.Stdin, os.Stdout))
+	case "dap":
+		checkCommandArgs(1, *useBytecode || dumping)
+		serve(dap.NewServer(os.Stdin, os.Stdout, searchPaths))
+	case "debug":
+		checkCommandArgs(2, *useBytecode || dumping)
+		script := flag.Arg(1)
+		opts.SearchPaths = append([]string{filepath.Dir(script)}, searchPaths...)
+		opts.Hook = debugger.New(debugger.NewCLI(os.Stdin, os.Stdout))
+		NewLox(opts, os.Stderr).RunFile(script)
+	default:
+		runScript(opts, searchPaths, *dumpTokens, *dumpBytecode, dumpAST)
+	}
+}
+
+// checkCommandArgs exits with the usage message unless the command line has
+// n arguments, counting the command, and no flags the command doesn't take.
+func checkCommandArgs(n int, badFlags bool) {
+	if flag.NArg() != n || badFlags {
+		flag.Usage()
 		os.Exit(65)
-	} else if len(os.Args) == 2 {
-		lox.RunFile(os.Args[1])
+	}
+}
+
+func serve(server interface{ Serve() error }) {
+	if err := server.Serve(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// runScript runs the script named on the command line, or the REPL if
+// there is none.
+func runScript(opts lox.Options, searchPaths []string, dumpTokens, dumpBytecode bool, dumpAST astFormat) {
+	dumps := 0
+	for _, dump := range []bool{dumpTokens, dumpBytecode, dumpAST != astNone} {
+		if dump {
+			dumps++
+		}
+	}
+	if flag.NArg() > 1 || dumps > 1 || (dumps > 0 && flag.NArg() == 0) {
+		flag.Usage()
+		os.Exit(65)
+	} else if flag.NArg() == 1 {
+		script := flag.Arg(0)
+		opts.SearchPaths = append([]string{filepath.Dir(script)}, searchPaths...)
+		switch {
+		case dumpTokens:
+			NewLox(opts, os.Stderr).DumpTokens(script)
+		case dumpBytecode:
+			NewLox(opts, os.Stderr).DumpBytecode(script)
+		case dumpAST != astNone:
+			NewLox(opts, os.Stderr).DumpAST(script, dumpAST)
+		default:
+			NewLox(opts, os.Stderr).RunFile(script)
+		}
 	} else {
-		lox.RunPromt()
+		opts.SearchPaths = append([]string{"."}, searchPaths...)
+		NewLox(opts, os.Stderr).RunPromt()
 	}
 }
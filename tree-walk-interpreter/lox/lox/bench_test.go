@@ -0,0 +1,65 @@
+package lox
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fosmjo/lox/bytecode"
+)
+
+// benchScript runs the script in ../bench on each backend. Compiling is
+// left out of the timing.
+//
+//	go test ./lox -run '^$' -bench .
+func benchScript(b *testing.B, name string) {
+	src, err := os.ReadFile(filepath.Join("..", "bench", name))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, useBytecode := range []bool{false, true} {
+		backend := "interpreter"
+		if useBytecode {
+			backend = "bytecode"
+		}
+		b.Run(backend, func(b *testing.B) {
+			vm, err := New(Options{Stdout: io.Discard, Bytecode: useBytecode})
+			if err != nil {
+				b.Fatal(err)
+			}
+			stmts, err := vm.compile(name, string(src))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var script *bytecode.Function
+			if useBytecode {
+				if script, err = vm.compileBytecode(stmts); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for j := 0; j < b.N; j++ {
+				if useBytecode {
+					_, err = vm.machine.Run(context.Background(), script)
+				} else {
+					_, err = vm.interpreter.Execute(context.Background(), stmts)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchScript(b, "fib.lox")
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchScript(b, "loop.lox")
+}
@@ -0,0 +1,113 @@
+package lox
+
+import (
+	"fmt"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// ErrorSink is told about the diagnostics of each compilation, in source
+// order, and about runtime errors as they escape.
+type ErrorSink interface {
+	Diagnostic(d diag.Diagnostic)
+	RuntimeError(err *RuntimeError)
+}
+
+// CompileError is returned when source fails to scan, parse or resolve.
+// It holds every diagnostic that was reported, in source order.
+type CompileError struct {
+	Diagnostics diag.List
+}
+
+func (ce *CompileError) Error() string {
+	return ce.Diagnostics.Error()
+}
+
+// Errors that a *RuntimeError wraps when a script exceeds one of the
+// limits set in Options or its context is done.
+var (
+	ErrStackOverflow = interpreter.ErrStackOverflow
+	ErrStepLimit     = interpreter.ErrStepLimit
+	ErrCancelled     = interpreter.ErrCancelled
+)
+
+// Frame is a call in a RuntimeError's traceback.
+type Frame = interpreter.Frame
+
+// RuntimeError is returned when a script fails while running.
+type RuntimeError struct {
+	Line    int
+	Column  int
+	Message string
+	Span    scanner.Span
+	// Trace holds the calls in progress when the error was raised,
+	// innermost last.
+	Trace []Frame
+	Err   error
+}
+
+func newRuntimeError(span scanner.Span, msg string, trace []Frame, err error) *RuntimeError {
+	return &RuntimeError{
+		Line:    span.Start.Line,
+		Column:  span.Start.Column,
+		Message: msg,
+		Span:    span,
+		Trace:   trace,
+		Err:     err,
+	}
+}
+
+func (re *RuntimeError) Error() string {
+	return fmt.Sprintf("%s: %s", re.Span, re.Message)
+}
+
+func (re *RuntimeError) Unwrap() error {
+	return re.Err
+}
+
+// collector gathers the diagnostics of a single compilation, and passes
+// runtime errors on to the host's sink, if there is one.
+type collector struct {
+	sink  ErrorSink
+	diags diag.List
+}
+
+// RuntimeError implements the interpreter's error reporting.
+func (c *collector) RuntimeError(err interpreter.RuntimeError) {
+	c.runtimeError(newRuntimeError(err.Token().Span, err.Error(), err.Trace(), err))
+}
+
+func (c *collector) runtimeError(err *RuntimeError) {
+	if c.sink != nil {
+		c.sink.RuntimeError(err)
+	}
+}
+
+func (c *collector) reset() {
+	c.diags = nil
+}
+
+func (c *collector) add(diags diag.List) {
+	c.diags = append(c.diags, diags...)
+}
+
+// err sorts the diagnostics, tells the sink about them, and returns them as
+// a *CompileError if any of them is an error.
+func (c *collector) err() error {
+	c.diags.Sort()
+	if c.sink != nil {
+		for _, d := range c.diags {
+			c.sink.Diagnostic(d)
+		}
+	}
+
+	if !c.diags.HasErrors() {
+		return nil
+	}
+
+	diags := make(diag.List, len(c.diags))
+	copy(diags, c.diags)
+	return &CompileError{Diagnostics: diags}
+}
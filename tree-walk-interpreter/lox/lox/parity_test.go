@@ -0,0 +1,207 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fosmjo/lox/diag"
+)
+
+// run runs src on one backend and returns what it printed and how it
+// failed, with the traceback of a runtime error.
+func run(t *testing.T, opts Options, name, src string) (string, string) {
+	t.Helper()
+	var out bytes.Buffer
+	opts.Stdout = &out
+	vm, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = vm.EvalSource(context.Background(), name, src)
+	failure := ""
+	if err != nil {
+		failure = err.Error()
+	}
+	var rerr *RuntimeError
+	if errors.As(err, &rerr) {
+		failure += fmt.Sprint(rerr.Trace)
+	}
+	return out.String(), failure
+}
+
+// TestBackendsAgree runs each script on both backends, which must print
+// the same and fail the same way.
+func TestBackendsAgree(t *testing.T) {
+	scripts := map[string]string{
+		"arithmetic": `
+print 1 + 2 * 3 - 4 / 8;
+print -(3 - 5);
+print "con" + "cat";
+print 1 == 1.0;
+print nil == false;
+print !nil;
+print 10 > 3 and "yes" or "no";`,
+		"scopes": `
+var a = "global";
+{
+  var a = "outer";
+  {
+    var a = "inner";
+    print a;
+  }
+  print a;
+}
+print a;`,
+		"closures": `
+fun counter() {
+  var n = 0;
+  return fun () { n = n + 1; return n; };
+}
+var c = counter();
+c();
+print c();
+var fs = [];
+for (var j = 0; j < 3; j = j + 1) push(fs, fun () { return j; });
+print fs[0]() + fs[2]();
+fun outer() {
+  var x = "x";
+  fun middle() {
+    fun inner() { return x; }
+    return inner;
+  }
+  return middle()();
+}
+print outer();`,
+		"classes": `
+class A {
+  init(n) { this.n = n; }
+  hi() { return "A" + this.n; }
+}
+class B < A {
+  init(n) { super.init(n + 1); }
+  hi() { return "B" + super.hi(); }
+}
+var b = B(1);
+print b.hi();
+print b;
+print B;
+var m = b.hi;
+print m();
+print A(5).init(7).n;`,
+		"control flow": `
+var out = "";
+for (var j = 0; j < 10; j = j + 1) {
+  if (j == 2) continue;
+  if (j == 5) break;
+  out = out + "x";
+}
+print out;
+var k = 0;
+while (true) { k = k + 1; if (k > 3) break; }
+print k;`,
+		"try and finally": `
+fun f() {
+  try { return "try"; } finally { print "finally"; }
+}
+print f();
+fun g() {
+  for (var j = 0; j < 3; j = j + 1) {
+    try { if (j == 1) break; } finally { print j; }
+  }
+}
+g();
+try { throw "thrown"; } catch (e) { print e; }
+try { print nil + 1; } catch (e) { print e.message; }
+fun h() { try { throw 1; } finally { return 2; } }
+print h();`,
+		"lists and maps": `
+var l = [1, 2, 3];
+push(l, 4);
+print len(l);
+print pop(l);
+l[0] = "zero";
+print l;
+var m = {"a": 1, "b": [2]};
+m["c"] = 3;
+print m["b"][0] + m["c"];
+print keys(m);
+print len(m);`,
+		"runtime error": `
+fun a() { b(); }
+fun b() { c(); }
+fun c() { return 1 + nil; }
+print "before";
+a();
+print "after";`,
+		"uncaught throw": `
+fun f() { throw "up"; }
+f();`,
+		"bad call": `
+fun f(a, b) {}
+f(1);`,
+		"undefined variable": `
+print undefined;`,
+		"bad property": `
+class C {}
+print C().missing;`,
+		"stack overflow": `
+fun f(n) { return f(n + 1); }
+f(0);`,
+		"compile error": `
+var x = ;
+print "never";`,
+		"resolve error": `
+{ var a = 1; var a = 2; }`,
+	}
+
+	for _, file := range []string{"fib.lox", "loop.lox"} {
+		src, err := os.ReadFile(filepath.Join("..", "bench", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scripts[file] = string(src)
+	}
+
+	for name, src := range scripts {
+		t.Run(name, func(t *testing.T) {
+			out, failure := run(t, Options{}, name+".lox", src)
+			bcOut, bcFailure := run(t, Options{Bytecode: true}, name+".lox", src)
+			if out != bcOut {
+				t.Errorf("interpreter printed\n%s\nbytecode printed\n%s", out, bcOut)
+			}
+			if failure != bcFailure {
+				t.Errorf("interpreter failed with\n%s\nbytecode failed with\n%s", failure, bcFailure)
+			}
+		})
+	}
+}
+
+// TestBytecodeLimits checks the programs that package bytecode documents it
+// can't compile, which the interpreter runs.
+func TestBytecodeLimits(t *testing.T) {
+	locals := make([]string, 256)
+	for j := range locals {
+		locals[j] = fmt.Sprintf("var v%d = %d;", j, j)
+	}
+	src := "fun f() { " + strings.Join(locals, " ") + " print v255; } f();"
+
+	if out, failure := run(t, Options{}, "locals.lox", src); out != "255\n" || failure != "" {
+		t.Errorf("interpreter: got %q and %q, want 255 printed", out, failure)
+	}
+	vm, err := New(Options{Bytecode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = vm.Eval(context.Background(), src)
+	var cerr *CompileError
+	if !errors.As(err, &cerr) || cerr.Diagnostics[0].Code != diag.TooManyLocals {
+		t.Errorf("bytecode: got error %v, want too many locals", err)
+	}
+}
@@ -0,0 +1,368 @@
+// Package lox embeds the Lox interpreter in Go programs.
+//
+//	vm, err := lox.New(lox.Options{})
+//	vm.SetGlobal("limit", 10.0)
+//	_, err = vm.Eval(ctx, "fun double(n) { return n * 2; }")
+//	double, _ := vm.GetGlobal("double")
+//	result, err := vm.Call(double, 21.0)
+package lox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fosmjo/lox/bytecode"
+	"github.com/fosmjo/lox/checker"
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/resolver"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Value is a Lox value: nil, bool, float64, string, or one of the
+// interpreter's list, map, function, class, instance and module types.
+type Value = interface{}
+
+type Options struct {
+	// Stdout receives the output of print statements. It defaults to the
+	// process's standard output.
+	Stdout io.Writer
+	// Stdin is read by natives that read input. It defaults to the
+	// process's standard input.
+	Stdin io.Reader
+	// Errors, if set, is also told about every error as it is reported.
+	Errors ErrorSink
+	// SearchPaths are the directories that imports are looked up in after
+	// the directory of the importing module.
+	SearchPaths []string
+
+	// MaxSteps limits the number of statements a single Eval or Call may
+	// run. Zero means no limit.
+	MaxSteps int
+	// MaxCallDepth limits how deeply calls may nest. Zero keeps the
+	// interpreter's default, and a negative value means no limit.
+	MaxCallDepth int
+	// Timeout limits the wall-clock time of a single Eval or Call. Zero
+	// means no limit.
+	Timeout time.Duration
+
+	// Bytecode runs scripts on the bytecode VM instead of the tree-walk
+	// interpreter. The bytecode VM can't run a Hook or Register Go
+	// functions, and fails to compile functions beyond the limits listed in
+	// package bytecode, such as those with more than 255 locals.
+	Bytecode bool
+
+	// Hook, if set, is told about each statement before it runs. The
+	// debugger package provides one.
+	Hook Hook
+}
+
+// Hook is told about each statement before it runs.
+type Hook = interpreter.Hook
+
+// VM is a Lox interpreter whose globals persist across evaluations. A VM
+// must not be used from several goroutines at once.
+type VM struct {
+	errors      *collector
+	searchPaths []string
+
+	// Exactly one of interpreter and machine is set.
+	interpreter *interpreter.Interpreter
+	machine     *bytecode.VM
+	timeout     time.Duration
+}
+
+// New returns a VM that runs scripts with opts. It fails if opts asks for
+// something the chosen backend can't do.
+func New(opts Options) (*VM, error) {
+	vm := &VM{
+		errors:      &collector{sink: opts.Errors},
+		searchPaths: opts.SearchPaths,
+	}
+
+	if opts.Bytecode {
+		if opts.Hook != nil {
+			return nil, errors.New("lox: the bytecode VM can't run a hook")
+		}
+
+		options := []bytecode.Option{
+			bytecode.WithLoader(vm),
+			bytecode.WithMaxSteps(opts.MaxSteps),
+		}
+		if opts.Stdout != nil {
+			options = append(options, bytecode.WithStdout(opts.Stdout))
+		}
+		if opts.MaxCallDepth != 0 {
+			options = append(options, bytecode.WithMaxCallDepth(opts.MaxCallDepth))
+		}
+
+		vm.machine = bytecode.New(options...)
+		vm.timeout = opts.Timeout
+		return vm, nil
+	}
+
+	options := []interpreter.InterpreterOption{
+		interpreter.WithLoader(vm),
+		interpreter.WithMaxSteps(opts.MaxSteps),
+		interpreter.WithTimeout(opts.Timeout),
+	}
+	if opts.Stdout != nil {
+		options = append(options, interpreter.WithStdout(opts.Stdout))
+	}
+	if opts.Stdin != nil {
+		options = append(options, interpreter.WithStdin(opts.Stdin))
+	}
+	if opts.MaxCallDepth != 0 {
+		options = append(options, interpreter.WithMaxCallDepth(opts.MaxCallDepth))
+	}
+	if opts.Hook != nil {
+		options = append(options, interpreter.WithHook(opts.Hook))
+	}
+
+	vm.interpreter = interpreter.NewInterpreter(vm.errors, options...)
+	return vm, nil
+}
+
+// Eval runs src and returns the value of its final statement if that is an
+// expression statement, and nil otherwise. The error is a *CompileError or
+// a *RuntimeError. Running stops once ctx is done, and the error then
+// wraps ErrCancelled.
+func (vm *VM) Eval(ctx context.Context, src string) (Value, error) {
+	return vm.EvalSource(ctx, "", src)
+}
+
+// EvalSource is like Eval, but names the source so that errors can say
+// which file they are in.
+func (vm *VM) EvalSource(ctx context.Context, name, src string) (Value, error) {
+	stmts, err := vm.compile(name, src)
+	if err != nil {
+		return nil, err
+	}
+
+	// A script read from a file is registered as a module while it runs,
+	// so that an import cycle back to it is caught before it runs twice.
+	path := ""
+	if name != "" {
+		if path, err = filepath.Abs(name); err != nil {
+			path = ""
+		}
+	}
+
+	if vm.machine != nil {
+		return vm.run(ctx, path, stmts)
+	}
+
+	var value Value
+	if path != "" {
+		value, err = vm.interpreter.ExecuteFile(ctx, path, stmts)
+	} else {
+		value, err = vm.interpreter.Execute(ctx, stmts)
+	}
+	if err != nil {
+		return nil, vm.runtimeError(err)
+	}
+	return value, nil
+}
+
+// Compile compiles src to bytecode without running it, so that what the
+// bytecode VM would run can be inspected. It works whether or not the VM
+// runs bytecode.
+func (vm *VM) Compile(name, src string) (*bytecode.Function, error) {
+	stmts, err := vm.compile(name, src)
+	if err != nil {
+		return nil, err
+	}
+	return vm.compileBytecode(stmts)
+}
+
+// Parse parses src without resolving or running it, so that the syntax
+// tree can be inspected.
+func (vm *VM) Parse(name, src string) ([]parser.Stmt, error) {
+	vm.errors.reset()
+	stmts, diags := parse(name, src)
+	vm.errors.add(diags)
+	if err := vm.errors.err(); err != nil {
+		return nil, err
+	}
+	return stmts, nil
+}
+
+func (vm *VM) run(ctx context.Context, path string, stmts []parser.Stmt) (Value, error) {
+	script, err := vm.compileBytecode(stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := vm.withTimeout(ctx)
+	defer cancel()
+
+	var value Value
+	if path != "" {
+		value, err = vm.machine.RunFile(ctx, path, script)
+	} else {
+		value, err = vm.machine.Run(ctx, script)
+	}
+	if err != nil {
+		return nil, vm.runtimeError(err)
+	}
+	return value, nil
+}
+
+func (vm *VM) compileBytecode(stmts []parser.Stmt) (*bytecode.Function, error) {
+	script, diags := bytecode.Compile(stmts)
+	if diags.HasErrors() {
+		vm.errors.reset()
+		vm.errors.add(diags)
+		return nil, vm.errors.err()
+	}
+	return script, nil
+}
+
+func (vm *VM) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if vm.timeout > 0 {
+		return context.WithTimeout(ctx, vm.timeout)
+	}
+	return ctx, func() {}
+}
+
+func (vm *VM) SetGlobal(name string, value Value) {
+	if vm.machine != nil {
+		vm.machine.SetGlobal(name, value)
+		return
+	}
+	vm.interpreter.SetGlobal(name, value)
+}
+
+func (vm *VM) GetGlobal(name string) (Value, bool) {
+	if vm.machine != nil {
+		return vm.machine.GetGlobal(name)
+	}
+	return vm.interpreter.GetGlobal(name)
+}
+
+// Register defines a global Lox function that calls fn. Arguments and
+// results are converted between Lox and Go values: numbers to any Go
+// numeric type, lists to slices and maps to maps. fn may return a value,
+// an error, or both, and a non-nil error is raised as a Lox runtime error.
+//
+//	vm.Register("repeat", func(s string, n int) (string, error) { ... })
+func (vm *VM) Register(name string, fn interface{}) error {
+	if vm.machine != nil {
+		return errors.New("lox: the bytecode VM can't register Go functions")
+	}
+
+	native, err := interpreter.NewNative(name, fn)
+	if err != nil {
+		return err
+	}
+
+	vm.interpreter.SetGlobal(name, native)
+	return nil
+}
+
+// Call calls a Lox function or class, such as one fetched with GetGlobal.
+func (vm *VM) Call(fn Value, args ...Value) (Value, error) {
+	var value Value
+	var err error
+	if vm.machine != nil {
+		ctx, cancel := vm.withTimeout(context.Background())
+		defer cancel()
+		value, err = vm.machine.Call(ctx, fn, args)
+	} else {
+		value, err = vm.interpreter.Call(context.Background(), fn, args)
+	}
+	if err != nil {
+		return nil, vm.runtimeError(err)
+	}
+	return value, nil
+}
+
+// Find implements interpreter.Loader.
+func (vm *VM) Find(path, dir string) (string, error) {
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = candidates[:0]
+		if dir != "" {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+		for _, searchPath := range vm.searchPaths {
+			candidates = append(candidates, filepath.Join(searchPath, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return filepath.Abs(candidate)
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// Load implements interpreter.Loader. The diagnostics of a module aren't
+// reported to the sink, as the importing script may catch the error that
+// holds them.
+func (vm *VM) Load(path string) ([]parser.Stmt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	stmts, diags := vm.analyze(path, string(data))
+	if diags.HasErrors() {
+		diags.Sort()
+		return nil, &CompileError{Diagnostics: diags}
+	}
+	return stmts, nil
+}
+
+func (vm *VM) compile(name, src string) ([]parser.Stmt, error) {
+	vm.errors.reset()
+	stmts, diags := vm.analyze(name, src)
+	vm.errors.add(diags)
+	if err := vm.errors.err(); err != nil {
+		return nil, err
+	}
+	return stmts, nil
+}
+
+// analyze parses, resolves and type checks src.
+func (vm *VM) analyze(name, src string) ([]parser.Stmt, diag.List) {
+	stmts, diags := parse(name, src)
+	// The bytecode compiler resolves variables itself.
+	var binder resolver.Interpreter
+	if vm.interpreter != nil {
+		binder = vm.interpreter
+	}
+	diags = append(diags, resolver.NewResolver(binder).Resolve(stmts)...)
+	// Only programs that resolve are type checked.
+	if !diags.HasErrors() {
+		diags = append(diags, checker.Check(stmts)...)
+	}
+	return stmts, diags
+}
+
+func parse(name, src string) ([]parser.Stmt, diag.List) {
+	tokens := scanner.New(src, scanner.WithFileName(name)).ScanTokens()
+	return parser.NewParser(tokens).Parse()
+}
+
+func (vm *VM) runtimeError(err error) error {
+	var rerr *RuntimeError
+	var re interpreter.RuntimeError
+	var bre *bytecode.RuntimeError
+	switch {
+	case errors.As(err, &re):
+		rerr = newRuntimeError(re.Token().Span, re.Error(), re.Trace(), re)
+	case errors.As(err, &bre):
+		rerr = newRuntimeError(bre.Span, bre.Message, bre.Trace, bre)
+	default:
+		return err
+	}
+
+	vm.errors.runtimeError(rerr)
+	return rerr
+}
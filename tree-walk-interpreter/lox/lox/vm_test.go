@@ -0,0 +1,166 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fosmjo/lox/debugger"
+	"github.com/fosmjo/lox/diag"
+)
+
+// backends runs test with a VM of each backend.
+func backends(t *testing.T, opts Options, test func(t *testing.T, vm *VM, out *bytes.Buffer)) {
+	for _, bytecode := range []bool{false, true} {
+		name := "interpreter"
+		if bytecode {
+			name = "bytecode"
+		}
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := opts
+			opts.Stdout = &out
+			opts.Bytecode = bytecode
+			vm, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, vm, &out)
+		})
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportCycleToMainScript(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.lox": `print "a runs"; import "b.lox" as b;`,
+		"b.lox": `print "b runs"; import "a.lox" as a;`,
+	})
+	backends(t, Options{SearchPaths: []string{dir}}, func(t *testing.T, vm *VM, out *bytes.Buffer) {
+		main := filepath.Join(dir, "a.lox")
+		src, _ := os.ReadFile(main)
+		_, err := vm.EvalSource(context.Background(), main, string(src))
+		if err == nil || !strings.Contains(err.Error(), "Import cycle") {
+			t.Errorf("got error %v, want an import cycle", err)
+		}
+		if got, want := out.String(), `"a runs"`+"\n"+`"b runs"`+"\n"; got != want {
+			t.Errorf("got output %q, want %q", got, want)
+		}
+	})
+}
+
+func TestImportAfterFailure(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bad.lox": `print "bad runs"; fun f(a) { return a + 1; } f(nil);`,
+	})
+	backends(t, Options{SearchPaths: []string{dir}}, func(t *testing.T, vm *VM, out *bytes.Buffer) {
+		src := `
+for (var j = 0; j < 2; j = j + 1) {
+  try { import "bad.lox" as bad; } catch (e) { print e.message; }
+}`
+		if _, err := vm.Eval(context.Background(), src); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 4 || lines[0] != `"bad runs"` || lines[2] != `"bad runs"` {
+			t.Fatalf("got output %q, want bad.lox to run twice", out.String())
+		}
+		if strings.Contains(lines[3], "Import cycle") || lines[1] != lines[3] {
+			t.Errorf("second import failed with %q, want %q", lines[3], lines[1])
+		}
+	})
+}
+
+func TestMaxSteps(t *testing.T) {
+	src := `for (var j = 0; j < 100; j = j + 1) { var k = j; }`
+	backends(t, Options{MaxSteps: 1000}, func(t *testing.T, vm *VM, out *bytes.Buffer) {
+		if _, err := vm.Eval(context.Background(), src); err != nil {
+			t.Errorf("100 iterations: %v", err)
+		}
+		// The limit applies to each Eval separately, and try can't catch it.
+		_, err := vm.Eval(context.Background(), `try { while (true) {} } catch (e) { print e; }`)
+		if !errors.Is(err, ErrStepLimit) {
+			t.Errorf("endless loop: got error %v, want the step limit", err)
+		}
+		if out.Len() > 0 {
+			t.Errorf("got output %q, want none", out.String())
+		}
+	})
+	backends(t, Options{MaxSteps: 50}, func(t *testing.T, vm *VM, out *bytes.Buffer) {
+		if _, err := vm.Eval(context.Background(), src); !errors.Is(err, ErrStepLimit) {
+			t.Errorf("100 iterations: got error %v, want the step limit", err)
+		}
+	})
+}
+
+func TestBytecodeHook(t *testing.T) {
+	if _, err := New(Options{Bytecode: true, Hook: debugger.New(nil)}); err == nil {
+		t.Error("the bytecode VM accepted a hook")
+	}
+}
+
+// recorder is an ErrorSink that keeps what it's told.
+type recorder struct {
+	diags   diag.List
+	runtime []*RuntimeError
+}
+
+func (r *recorder) Diagnostic(d diag.Diagnostic) {
+	r.diags = append(r.diags, d)
+}
+
+func (r *recorder) RuntimeError(err *RuntimeError) {
+	r.runtime = append(r.runtime, err)
+}
+
+func TestDiagnosticsReportedOnce(t *testing.T) {
+	for _, src := range []string{
+		`return 1;`,
+		`var x: number = "one";`,
+	} {
+		backends(t, Options{}, func(t *testing.T, vm *VM, out *bytes.Buffer) {
+			sink := &recorder{}
+			vm.errors.sink = sink
+			_, err := vm.Eval(context.Background(), src)
+			var cerr *CompileError
+			if !errors.As(err, &cerr) || len(cerr.Diagnostics) != 1 {
+				t.Fatalf("%s: got error %v, want one diagnostic", src, err)
+			}
+			if len(sink.diags) != 1 {
+				t.Errorf("%s: the sink was told %v, want one diagnostic", src, sink.diags)
+			}
+		})
+	}
+}
+
+func TestCaughtImportCompileError(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"bad.lox": `var x = ;`})
+	backends(t, Options{SearchPaths: []string{dir}}, func(t *testing.T, vm *VM, out *bytes.Buffer) {
+		sink := &recorder{}
+		vm.errors.sink = sink
+		src := `try { import "bad.lox"; } catch (e) { print e.message; } print "after";`
+		if _, err := vm.Eval(context.Background(), src); err != nil {
+			t.Fatal(err)
+		}
+		if len(sink.diags) > 0 || len(sink.runtime) > 0 {
+			t.Errorf("the sink was told %v and %v, want nothing", sink.diags, sink.runtime)
+		}
+		if got := out.String(); !strings.Contains(got, "Expect expression.") || !strings.HasSuffix(got, `"after"`+"\n") {
+			t.Errorf("got output %q, want the module's error and then \"after\"", got)
+		}
+	})
+}
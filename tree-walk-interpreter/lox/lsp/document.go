@@ -0,0 +1,343 @@
+package lsp
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/fosmjo/lox/checker"
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/lint"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/resolver"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// document is an open file, analyzed as of its latest text.
+type document struct {
+	uri  string
+	text string
+	// lines holds the offset at which each line starts.
+	lines []int
+
+	stmts []parser.Stmt
+	diags diag.List
+	index resolver.Index
+}
+
+// analyze scans, parses, resolves, lints and type checks text. The parser
+// skips declarations that fail to parse, so the rest of the file is still
+// indexed.
+func analyze(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: []int{0}}
+	for j := 0; j < len(text); j++ {
+		if text[j] == '\n' {
+			d.lines = append(d.lines, j+1)
+		}
+	}
+
+	tokens := scanner.New(text, scanner.WithFileName(uriPath(uri))).ScanTokens()
+	stmts, diags := parser.NewParser(tokens).Parse()
+	d.diags = diags
+
+	// Declarations that fail to parse would make for false warnings.
+	options := []resolver.Option{resolver.WithIndex(&d.index)}
+	if !d.diags.HasErrors() {
+		options = append(options, resolver.WithWarnings(lint.Builtins()...))
+	}
+	d.diags = append(d.diags, resolver.NewResolver(nil, options...).Resolve(stmts)...)
+	if !d.diags.HasErrors() {
+		d.diags = append(d.diags, checker.Check(stmts)...)
+	}
+	d.diags = lint.Suppress(d.diags, tokens)
+
+	d.stmts = stmts
+	d.diags.Sort()
+	return d
+}
+
+// uriPath returns the file path of a file: URI, or the URI itself if it
+// isn't one.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+// position converts a byte offset to a position, whose character counts
+// UTF-16 code units as the protocol's do.
+func (d *document) position(offset int) Position {
+	line := sort.Search(len(d.lines), func(j int) bool { return d.lines[j] > offset }) - 1
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	return Position{Line: line, Character: len(utf16.Encode([]rune(d.text[d.lines[line]:offset])))}
+}
+
+// offset converts a position to a byte offset.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	offset := d.lines[pos.Line]
+	for units := 0; units < pos.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) span(span scanner.Span) Range {
+	return Range{Start: d.position(span.Start.Offset), End: d.position(span.End.Offset)}
+}
+
+// contains reports whether offset is within span or just after it, where
+// the cursor is after typing a name.
+func contains(span scanner.Span, offset int) bool {
+	return span.Start.Offset <= offset && offset <= span.End.Offset
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(d.diags))
+	for _, dg := range d.diags {
+		severity := 1
+		switch dg.Severity {
+		case diag.Warning:
+			severity = 2
+		case diag.Note:
+			severity = 3
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.span(dg.Span),
+			Severity: severity,
+			Code:     string(dg.Code),
+			Source:   "lox",
+			Message:  dg.Message,
+		})
+	}
+	return diagnostics
+}
+
+// declarations returns what the name at offset refers to: its own
+// declaration, that of the variable it uses, or every method a property
+// name could be.
+func (d *document) declarations(offset int) (scanner.Token, []*resolver.Declaration) {
+	for _, decl := range d.index.Declarations {
+		if contains(decl.Name.Span, offset) {
+			return decl.Name, []*resolver.Declaration{decl}
+		}
+	}
+	for _, ref := range d.index.References {
+		if contains(ref.Name.Span, offset) {
+			if ref.Declaration == nil {
+				return ref.Name, nil
+			}
+			return ref.Name, []*resolver.Declaration{ref.Declaration}
+		}
+	}
+	for _, prop := range d.index.Properties {
+		if contains(prop.Span, offset) {
+			var methods []*resolver.Declaration
+			for _, decl := range d.index.Declarations {
+				if decl.Kind == resolver.KindMethod && decl.Name.Lexeme == prop.Lexeme {
+					methods = append(methods, decl)
+				}
+			}
+			return prop, methods
+		}
+	}
+	return scanner.Token{}, nil
+}
+
+// describe formats decl the way it was declared.
+func describe(decl *resolver.Declaration) string {
+	name := decl.Name.Lexeme
+	switch decl.Kind {
+	case resolver.KindParameter:
+		fn := decl.Stmt.(*parser.FunctionStmt)
+		for j, param := range fn.Params {
+			if param.Span == decl.Name.Span {
+				return "(parameter) " + name + annotation(fn.ParamTypes[j])
+			}
+		}
+		return "(parameter) " + name
+	case resolver.KindFunction:
+		return "fun " + name + params(decl.Stmt)
+	case resolver.KindMethod:
+		return "(method) " + decl.Class.Name.Lexeme + "." + name + params(decl.Stmt)
+	case resolver.KindClass:
+		if class := decl.Stmt.(*parser.ClassStmt); class.Superclass != nil {
+			return "class " + name + " < " + class.Superclass.Name.Lexeme
+		}
+		return "class " + name
+	case resolver.KindImport:
+		stmt := decl.Stmt.(*parser.ImportStmt)
+		if len(stmt.Names) > 0 {
+			return "import " + stmt.Path.Lexeme + " for " + name
+		}
+		return "import " + stmt.Path.Lexeme + " as " + name
+	}
+	if _, ok := decl.Stmt.(*parser.TryStmt); ok {
+		return "catch (" + name + ")"
+	}
+	if stmt, ok := decl.Stmt.(*parser.VarStmt); ok {
+		return "var " + name + annotation(stmt.Annotation)
+	}
+	return "var " + name
+}
+
+func params(stmt parser.Stmt) string {
+	fn := stmt.(*parser.FunctionStmt)
+	var names []string
+	for j, param := range fn.Params {
+		names = append(names, param.Lexeme+annotation(fn.ParamTypes[j]))
+	}
+	return "(" + strings.Join(names, ", ") + ")" + annotation(fn.ReturnType)
+}
+
+// annotation formats the type annotation a, if there is one.
+func annotation(a *parser.Annotation) string {
+	if a == nil {
+		return ""
+	}
+	return ": " + a.Name.Lexeme
+}
+
+func (d *document) symbols(stmts []parser.Stmt) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	for _, stmt := range stmts {
+		symbols = append(symbols, d.stmtSymbols(stmt)...)
+	}
+	return symbols
+}
+
+func (d *document) stmtSymbols(stmt parser.Stmt) []DocumentSymbol {
+	switch stmt := stmt.(type) {
+	case *parser.ClassStmt:
+		symbol := d.symbol(stmt.Name, SymbolClass, stmt.Span(), nil)
+		for _, method := range stmt.Methods {
+			kind := SymbolMethod
+			if method.Name.Lexeme == "init" {
+				kind = SymbolConstructor
+			}
+			symbol.Children = append(symbol.Children, d.symbol(method.Name, kind, method.Span(), method.Body))
+		}
+		return []DocumentSymbol{symbol}
+	case *parser.FunctionStmt:
+		return []DocumentSymbol{d.symbol(stmt.Name, SymbolFunction, stmt.Span(), stmt.Body)}
+	case *parser.BlockStmt:
+		return d.symbols(stmt.Statements)
+	case *parser.IfStmt:
+		symbols := d.stmtSymbols(stmt.ThenBranch)
+		if stmt.ElseBranch != nil {
+			symbols = append(symbols, d.stmtSymbols(stmt.ElseBranch)...)
+		}
+		return symbols
+	case *parser.WhileStmt:
+		return d.stmtSymbols(stmt.Body)
+	case *parser.TryStmt:
+		symbols := d.symbols(stmt.Body)
+		symbols = append(symbols, d.symbols(stmt.CatchBody)...)
+		return append(symbols, d.symbols(stmt.FinallyBody)...)
+	}
+	return nil
+}
+
+// symbol returns the symbol for name, listing the functions declared in
+// body as its children.
+func (d *document) symbol(name scanner.Token, kind SymbolKind, span scanner.Span, body []parser.Stmt) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           name.Lexeme,
+		Kind:           kind,
+		Range:          d.span(span.Join(name.Span)),
+		SelectionRange: d.span(name.Span),
+		Children:       d.symbols(body),
+	}
+}
+
+// completions returns the names that could be typed at offset: after a
+// dot, the methods and properties used in the file, and otherwise the
+// variables in scope there, the builtins and the keywords.
+func (d *document) completions(offset int) []CompletionItem {
+	start := offset
+	for start > 0 && isIdentifierByte(d.text[start-1]) {
+		start--
+	}
+
+	items := make([]CompletionItem, 0)
+	seen := make(map[string]bool)
+	add := func(label string, kind CompletionItemKind, detail string) {
+		if !seen[label] {
+			seen[label] = true
+			items = append(items, CompletionItem{Label: label, Kind: kind, Detail: detail})
+		}
+	}
+
+	if start > 0 && d.text[start-1] == '.' {
+		for _, decl := range d.index.Declarations {
+			if decl.Kind == resolver.KindMethod {
+				add(decl.Name.Lexeme, CompletionMethod, describe(decl))
+			}
+		}
+		for _, prop := range d.index.Properties {
+			if prop.Span.Start.Offset != start {
+				add(prop.Lexeme, CompletionField, "")
+			}
+		}
+		return items
+	}
+
+	// Innermost declarations first, so that they win over those they
+	// shadow.
+	var visible []*resolver.Declaration
+	for _, decl := range d.index.Declarations {
+		if decl.Kind == resolver.KindMethod || decl.Name.Span.Start.Offset == start {
+			continue
+		}
+		if decl.Global || decl.Name.Span.End.Offset <= start && contains(decl.Scope, offset) {
+			visible = append(visible, decl)
+		}
+	}
+	sort.SliceStable(visible, func(a, b int) bool {
+		return !visible[a].Global && visible[b].Global ||
+			visible[a].Global == visible[b].Global && visible[a].Scope.Start.Offset > visible[b].Scope.Start.Offset
+	})
+	for _, decl := range visible {
+		add(decl.Name.Lexeme, completionKind(decl.Kind), describe(decl))
+	}
+
+	// A declaration of the same name shadows a builtin.
+	for _, name := range lint.Builtins() {
+		add(name, CompletionFunction, "builtin")
+	}
+
+	for _, keyword := range scanner.Keywords() {
+		add(keyword, CompletionKeyword, "")
+	}
+	return items
+}
+
+func completionKind(kind resolver.Kind) CompletionItemKind {
+	switch kind {
+	case resolver.KindFunction:
+		return CompletionFunction
+	case resolver.KindClass:
+		return CompletionClass
+	case resolver.KindImport:
+		return CompletionModule
+	}
+	return CompletionVariable
+}
+
+func isIdentifierByte(ch byte) bool {
+	return ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9'
+}
@@ -0,0 +1,36 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletions(t *testing.T) {
+	text := "fun len(s) {}\nvar total = 1;\nprint "
+	d := analyze("file:///test.lox", text)
+
+	kinds := make(map[string]CompletionItemKind)
+	var labels []string
+	for _, item := range d.completions(len(text)) {
+		kinds[item.Label] = item.Kind
+		labels = append(labels, item.Label)
+	}
+
+	for label, kind := range map[string]CompletionItemKind{
+		"total": CompletionVariable,
+		"len":   CompletionFunction,
+		"clock": CompletionFunction,
+		"push":  CompletionFunction,
+		"while": CompletionKeyword,
+	} {
+		if kinds[label] != kind {
+			t.Errorf("%s: got kind %d, want %d", label, kinds[label], kind)
+		}
+	}
+
+	// Declarations come first, then builtins, then keywords.
+	order := strings.Join(labels, " ")
+	if !strings.HasPrefix(order, "len total ") || strings.Index(order, "clock") > strings.Index(order, "and") {
+		t.Errorf("got completions in the order %s", order)
+	}
+}
@@ -0,0 +1,173 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fosmjo/lox/internal/framing"
+)
+
+// message is a JSON-RPC 2.0 request, notification or response. Requests
+// have an ID and a method, notifications only a method, and responses only
+// an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes.
+const (
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// conn reads and writes messages framed by Content-Length headers.
+type conn struct {
+	r *framing.Reader
+	w io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: framing.NewReader(r), w: w}
+}
+
+func (c *conn) read() (*message, error) {
+	body, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("lsp: %v", err)
+	}
+	return &msg, nil
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return framing.Write(c.w, body)
+}
+
+// The types that follow are those of the protocol's specification, less the
+// fields this server doesn't use.
+
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   TextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type SymbolKind int
+
+const (
+	SymbolClass       SymbolKind = 5
+	SymbolMethod      SymbolKind = 6
+	SymbolConstructor SymbolKind = 9
+	SymbolFunction    SymbolKind = 12
+)
+
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+type CompletionItemKind int
+
+const (
+	CompletionMethod   CompletionItemKind = 2
+	CompletionFunction CompletionItemKind = 3
+	CompletionField    CompletionItemKind = 5
+	CompletionVariable CompletionItemKind = 6
+	CompletionClass    CompletionItemKind = 7
+	CompletionModule   CompletionItemKind = 9
+	CompletionKeyword  CompletionItemKind = 14
+)
+
+type CompletionItem struct {
+	Label  string             `json:"label"`
+	Kind   CompletionItemKind `json:"kind"`
+	Detail string             `json:"detail,omitempty"`
+}
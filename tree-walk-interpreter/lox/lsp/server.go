@@ -0,0 +1,189 @@
+// Package lsp serves the Language Server Protocol over a pair of streams,
+// giving editors diagnostics, go-to-definition, hover, document symbols and
+// completion for Lox.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Server serves one client.
+type Server struct {
+	conn *conn
+	docs map[string]*document
+}
+
+// NewServer returns a server that reads messages from r and writes them to
+// w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{conn: newConn(r, w), docs: make(map[string]*document)}
+}
+
+// Serve handles messages until the client sends exit or r runs out.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			continue
+		}
+
+		resp := &message{ID: msg.ID, Error: rerr}
+		if rerr == nil {
+			if resp.Result, err = json.Marshal(result); err != nil {
+				return err
+			}
+		}
+		if err := s.conn.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (interface{}, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // The client sends the whole text.
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string][]string{"triggerCharacters": {"."}},
+			},
+			"serverInfo": map[string]string{"name": "lox"},
+		}, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/definition":
+		return s.atPosition(msg, definition)
+	case "textDocument/hover":
+		return s.atPosition(msg, hover)
+	case "textDocument/completion":
+		return s.atPosition(msg, func(d *document, offset int) interface{} {
+			return d.completions(offset)
+		})
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if d, ok := s.docs[params.TextDocument.URI]; ok {
+			symbols := d.symbols(d.stmts)
+			if symbols == nil {
+				symbols = []DocumentSymbol{}
+			}
+			return symbols, nil
+		}
+		return nil, nil
+	default:
+		if msg.ID != nil {
+			return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("Unsupported method '%s'.", msg.Method)}
+		}
+	}
+	return nil, nil
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// update analyzes the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri, text string) {
+	d := analyze(uri, text)
+	s.docs[uri] = d
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: d.diagnostics()})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	s.conn.write(&message{Method: method, Params: raw})
+}
+
+// atPosition answers a request about a position in an open document with
+// f. The answer is null for documents that aren't open.
+func (s *Server) atPosition(msg *message, f func(d *document, offset int) interface{}) (interface{}, *responseError) {
+	var params TextDocumentPositionParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, invalidParams(err)
+	}
+	d, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return f(d, d.offset(params.Position)), nil
+}
+
+func definition(d *document, offset int) interface{} {
+	_, decls := d.declarations(offset)
+	if len(decls) == 0 {
+		return nil
+	}
+
+	locations := make([]Location, 0, len(decls))
+	for _, decl := range decls {
+		locations = append(locations, Location{URI: d.uri, Range: d.span(decl.Name.Span)})
+	}
+	return locations
+}
+
+func hover(d *document, offset int) interface{} {
+	name, decls := d.declarations(offset)
+	if len(decls) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("```lox\n")
+	for _, decl := range decls {
+		b.WriteString(describe(decl) + "\n")
+	}
+	b.WriteString("```")
+	if len(decls) == 1 {
+		fmt.Fprintf(&b, "\n\nDeclared on line %d.", decls[0].Name.Line)
+	}
+
+	r := d.span(name.Span)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: b.String()}, Range: &r}
+}
@@ -0,0 +1,17 @@
+package parser
+
+import "github.com/fosmjo/lox/scanner"
+
+// Annotation is a type annotation, such as the 'number' of
+// `var x: number;`. The interpreter ignores annotations; the checker
+// package checks them.
+type Annotation struct {
+	Name scanner.Token
+}
+
+// Field is a field that a class declares with its type, as in
+// `class Point { x: number; }`.
+type Field struct {
+	Name       scanner.Token
+	Annotation *Annotation
+}
@@ -5,27 +5,36 @@ package parser
 import "github.com/fosmjo/lox/scanner"
 
 type Expr interface {
-	Accept(ExprVisitor) interface{}
+	Accept(ExprVisitor) (interface{}, error)
+	Span() scanner.Span
+	setSpan(scanner.Span)
 }
 
 type ExprVisitor interface {
-	VisitAssignExpr(*AssignExpr) interface{}
-	VisitBinaryExpr(*BinaryExpr) interface{}
-	VisitCallExpr(*CallExpr) interface{}
-	VisitGetExpr(*GetExpr) interface{}
-	VisitGroupingExpr(*GroupingExpr) interface{}
-	VisitLiteralExpr(*LiteralExpr) interface{}
-	VisitLogicalExpr(*LogicalExpr) interface{}
-	VisitSetExpr(*SetExpr) interface{}
-	VisitSuperExpr(*SuperExpr) interface{}
-	VisitThisExpr(*ThisExpr) interface{}
-	VisitUnaryExpr(*UnaryExpr) interface{}
-	VisitVariableExpr(*VariableExpr) interface{}
+	VisitAssignExpr(*AssignExpr) (interface{}, error)
+	VisitBinaryExpr(*BinaryExpr) (interface{}, error)
+	VisitCallExpr(*CallExpr) (interface{}, error)
+	VisitGetExpr(*GetExpr) (interface{}, error)
+	VisitGroupingExpr(*GroupingExpr) (interface{}, error)
+	VisitIndexExpr(*IndexExpr) (interface{}, error)
+	VisitIndexSetExpr(*IndexSetExpr) (interface{}, error)
+	VisitLambdaExpr(*LambdaExpr) (interface{}, error)
+	VisitListExpr(*ListExpr) (interface{}, error)
+	VisitLiteralExpr(*LiteralExpr) (interface{}, error)
+	VisitLogicalExpr(*LogicalExpr) (interface{}, error)
+	VisitMapExpr(*MapExpr) (interface{}, error)
+	VisitSetExpr(*SetExpr) (interface{}, error)
+	VisitSuperExpr(*SuperExpr) (interface{}, error)
+	VisitThisExpr(*ThisExpr) (interface{}, error)
+	VisitUnaryExpr(*UnaryExpr) (interface{}, error)
+	VisitVariableExpr(*VariableExpr) (interface{}, error)
 }
 
 type AssignExpr struct {
 	Name  scanner.Token
 	Value Expr
+
+	span scanner.Span
 }
 
 func NewAssignExpr(name scanner.Token, value Expr) *AssignExpr {
@@ -35,14 +44,24 @@ func NewAssignExpr(name scanner.Token, value Expr) *AssignExpr {
 	}
 }
 
-func (expr *AssignExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *AssignExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitAssignExpr(expr)
 }
 
+func (expr *AssignExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *AssignExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type BinaryExpr struct {
 	Left     Expr
 	Operator scanner.Token
 	Right    Expr
+
+	span scanner.Span
 }
 
 func NewBinaryExpr(left Expr, operator scanner.Token, right Expr) *BinaryExpr {
@@ -53,14 +72,24 @@ func NewBinaryExpr(left Expr, operator scanner.Token, right Expr) *BinaryExpr {
 	}
 }
 
-func (expr *BinaryExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *BinaryExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitBinaryExpr(expr)
 }
 
+func (expr *BinaryExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *BinaryExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type CallExpr struct {
 	Callee    Expr
 	Paren     scanner.Token
 	Arguments []Expr
+
+	span scanner.Span
 }
 
 func NewCallExpr(callee Expr, paren scanner.Token, arguments []Expr) *CallExpr {
@@ -71,13 +100,23 @@ func NewCallExpr(callee Expr, paren scanner.Token, arguments []Expr) *CallExpr {
 	}
 }
 
-func (expr *CallExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *CallExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitCallExpr(expr)
 }
 
+func (expr *CallExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *CallExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type GetExpr struct {
 	Object Expr
 	Name   scanner.Token
+
+	span scanner.Span
 }
 
 func NewGetExpr(object Expr, name scanner.Token) *GetExpr {
@@ -87,12 +126,22 @@ func NewGetExpr(object Expr, name scanner.Token) *GetExpr {
 	}
 }
 
-func (expr *GetExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *GetExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitGetExpr(expr)
 }
 
+func (expr *GetExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *GetExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type GroupingExpr struct {
 	Expression Expr
+
+	span scanner.Span
 }
 
 func NewGroupingExpr(expression Expr) *GroupingExpr {
@@ -101,12 +150,128 @@ func NewGroupingExpr(expression Expr) *GroupingExpr {
 	}
 }
 
-func (expr *GroupingExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *GroupingExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitGroupingExpr(expr)
 }
 
+func (expr *GroupingExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *GroupingExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
+type IndexExpr struct {
+	Object  Expr
+	Bracket scanner.Token
+	Index   Expr
+
+	span scanner.Span
+}
+
+func NewIndexExpr(object Expr, bracket scanner.Token, index Expr) *IndexExpr {
+	return &IndexExpr{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+	}
+}
+
+func (expr *IndexExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitIndexExpr(expr)
+}
+
+func (expr *IndexExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *IndexExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
+type IndexSetExpr struct {
+	Object  Expr
+	Bracket scanner.Token
+	Index   Expr
+	Value   Expr
+
+	span scanner.Span
+}
+
+func NewIndexSetExpr(object Expr, bracket scanner.Token, index Expr, value Expr) *IndexSetExpr {
+	return &IndexSetExpr{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+		Value:   value,
+	}
+}
+
+func (expr *IndexSetExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitIndexSetExpr(expr)
+}
+
+func (expr *IndexSetExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *IndexSetExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
+type LambdaExpr struct {
+	Declaration *FunctionStmt
+
+	span scanner.Span
+}
+
+func NewLambdaExpr(declaration *FunctionStmt) *LambdaExpr {
+	return &LambdaExpr{
+		Declaration: declaration,
+	}
+}
+
+func (expr *LambdaExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitLambdaExpr(expr)
+}
+
+func (expr *LambdaExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *LambdaExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
+type ListExpr struct {
+	Elements []Expr
+
+	span scanner.Span
+}
+
+func NewListExpr(elements []Expr) *ListExpr {
+	return &ListExpr{
+		Elements: elements,
+	}
+}
+
+func (expr *ListExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitListExpr(expr)
+}
+
+func (expr *ListExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *ListExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type LiteralExpr struct {
 	Value interface{}
+
+	span scanner.Span
 }
 
 func NewLiteralExpr(value interface{}) *LiteralExpr {
@@ -115,14 +280,24 @@ func NewLiteralExpr(value interface{}) *LiteralExpr {
 	}
 }
 
-func (expr *LiteralExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *LiteralExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitLiteralExpr(expr)
 }
 
+func (expr *LiteralExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *LiteralExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type LogicalExpr struct {
 	Left     Expr
 	Operator scanner.Token
 	Right    Expr
+
+	span scanner.Span
 }
 
 func NewLogicalExpr(left Expr, operator scanner.Token, right Expr) *LogicalExpr {
@@ -133,14 +308,52 @@ func NewLogicalExpr(left Expr, operator scanner.Token, right Expr) *LogicalExpr
 	}
 }
 
-func (expr *LogicalExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *LogicalExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitLogicalExpr(expr)
 }
 
+func (expr *LogicalExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *LogicalExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
+type MapExpr struct {
+	Brace  scanner.Token
+	Keys   []Expr
+	Values []Expr
+
+	span scanner.Span
+}
+
+func NewMapExpr(brace scanner.Token, keys []Expr, values []Expr) *MapExpr {
+	return &MapExpr{
+		Brace:  brace,
+		Keys:   keys,
+		Values: values,
+	}
+}
+
+func (expr *MapExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitMapExpr(expr)
+}
+
+func (expr *MapExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *MapExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type SetExpr struct {
 	Object Expr
 	Name   scanner.Token
 	Value  Expr
+
+	span scanner.Span
 }
 
 func NewSetExpr(object Expr, name scanner.Token, value Expr) *SetExpr {
@@ -151,13 +364,23 @@ func NewSetExpr(object Expr, name scanner.Token, value Expr) *SetExpr {
 	}
 }
 
-func (expr *SetExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *SetExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitSetExpr(expr)
 }
 
+func (expr *SetExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *SetExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type SuperExpr struct {
 	Keyword scanner.Token
 	Method  scanner.Token
+
+	span scanner.Span
 }
 
 func NewSuperExpr(keyword scanner.Token, method scanner.Token) *SuperExpr {
@@ -167,12 +390,22 @@ func NewSuperExpr(keyword scanner.Token, method scanner.Token) *SuperExpr {
 	}
 }
 
-func (expr *SuperExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *SuperExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitSuperExpr(expr)
 }
 
+func (expr *SuperExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *SuperExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type ThisExpr struct {
 	Keyword scanner.Token
+
+	span scanner.Span
 }
 
 func NewThisExpr(keyword scanner.Token) *ThisExpr {
@@ -181,13 +414,23 @@ func NewThisExpr(keyword scanner.Token) *ThisExpr {
 	}
 }
 
-func (expr *ThisExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *ThisExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitThisExpr(expr)
 }
 
+func (expr *ThisExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *ThisExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type UnaryExpr struct {
 	Operator scanner.Token
 	Right    Expr
+
+	span scanner.Span
 }
 
 func NewUnaryExpr(operator scanner.Token, right Expr) *UnaryExpr {
@@ -197,12 +440,22 @@ func NewUnaryExpr(operator scanner.Token, right Expr) *UnaryExpr {
 	}
 }
 
-func (expr *UnaryExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *UnaryExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitUnaryExpr(expr)
 }
 
+func (expr *UnaryExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *UnaryExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
+
 type VariableExpr struct {
 	Name scanner.Token
+
+	span scanner.Span
 }
 
 func NewVariableExpr(name scanner.Token) *VariableExpr {
@@ -211,6 +464,14 @@ func NewVariableExpr(name scanner.Token) *VariableExpr {
 	}
 }
 
-func (expr *VariableExpr) Accept(visitor ExprVisitor) interface{} {
+func (expr *VariableExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitVariableExpr(expr)
 }
+
+func (expr *VariableExpr) Span() scanner.Span {
+	return expr.span
+}
+
+func (expr *VariableExpr) setSpan(span scanner.Span) {
+	expr.span = span
+}
@@ -0,0 +1,262 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+// The JSON form of a program is an object
+//
+//	{"file": "main.lox", "statements": [...]}
+//
+// in which each node is an object naming its type, such as "BinaryExpr",
+// and holding its span and fields:
+//
+//	{"node": "BinaryExpr", "span": {...}, "left": {...}, "operator": {...}, "right": {...}}
+//
+// A token is an object holding its type, lexeme, literal and span, and a
+// span is an object holding the offset, line and column of its start and
+// end. The JSON form follows the node types that tool/genast.go generates,
+// with each field named as in Go but starting in lower case.
+
+type jsonProgram struct {
+	File       string            `json:"file"`
+	Statements []json.RawMessage `json:"statements"`
+}
+
+type jsonToken struct {
+	Type    scanner.TokenType `json:"type"`
+	Lexeme  string            `json:"lexeme"`
+	Literal interface{}       `json:"literal"`
+	Span    jsonSpan          `json:"span"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func toJSONSpan(span scanner.Span) jsonSpan {
+	return jsonSpan{
+		Start: jsonPosition(span.Start),
+		End:   jsonPosition(span.End),
+	}
+}
+
+var (
+	exprType  = reflect.TypeOf((*Expr)(nil)).Elem()
+	stmtType  = reflect.TypeOf((*Stmt)(nil)).Elem()
+	tokenType = reflect.TypeOf(scanner.Token{})
+
+	// nodeTypes maps the name of each node type to the type, as found from
+	// the visitors' methods.
+	nodeTypes = make(map[string]reflect.Type)
+)
+
+func init() {
+	for _, visitor := range []reflect.Type{
+		reflect.TypeOf((*ExprVisitor)(nil)).Elem(),
+		reflect.TypeOf((*StmtVisitor)(nil)).Elem(),
+	} {
+		for j := 0; j < visitor.NumMethod(); j++ {
+			node := visitor.Method(j).Type.In(0)
+			nodeTypes[node.Elem().Name()] = node
+		}
+	}
+}
+
+// jsonName returns the JSON name of the Go field name.
+func jsonName(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[n:]
+}
+
+func isNode(t reflect.Type) bool {
+	return t.Implements(exprType) || t.Implements(stmtType)
+}
+
+// EncodeJSON returns the JSON form of stmts, parsed from the file name.
+func EncodeJSON(name string, stmts []Stmt) ([]byte, error) {
+	program := map[string]interface{}{
+		"file":       name,
+		"statements": encode(reflect.ValueOf(stmts)),
+	}
+	return json.MarshalIndent(program, "", "  ")
+}
+
+// encode returns v in a form that encoding/json marshals as v's JSON form.
+func encode(v reflect.Value) interface{} {
+	t := v.Type()
+	switch {
+	case t == tokenType:
+		token := v.Interface().(scanner.Token)
+		return jsonToken{
+			Type:    token.Type,
+			Lexeme:  token.Lexeme,
+			Literal: token.Literal,
+			Span:    toJSONSpan(token.Span),
+		}
+	case t.Kind() == reflect.Interface && t != exprType && t != stmtType:
+		// The value of a literal.
+		return v.Interface()
+	case t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		fields := v.Elem()
+		object := make(map[string]interface{})
+		if isNode(v.Type()) {
+			object["node"] = fields.Type().Name()
+			object["span"] = toJSONSpan(v.MethodByName("Span").Call(nil)[0].Interface().(scanner.Span))
+		}
+		for j := 0; j < fields.NumField(); j++ {
+			if f := fields.Type().Field(j); f.PkgPath == "" {
+				object[jsonName(f.Name)] = encode(fields.Field(j))
+			}
+		}
+		return object
+	case t.Kind() == reflect.Slice:
+		if v.IsNil() {
+			// A try statement without a catch clause, say.
+			return nil
+		}
+		elems := make([]interface{}, v.Len())
+		for j := range elems {
+			elems[j] = encode(v.Index(j))
+		}
+		return elems
+	}
+	panic(fmt.Sprintf("parser: can't encode %s as JSON", t))
+}
+
+// DecodeJSON returns the statements whose JSON form is data. The spans
+// in them point into a file of the program's name but without its source.
+func DecodeJSON(data []byte) ([]Stmt, error) {
+	var program jsonProgram
+	if err := json.Unmarshal(data, &program); err != nil {
+		return nil, err
+	}
+
+	d := decoder{file: &scanner.File{Name: program.File}}
+	stmts := make([]Stmt, len(program.Statements))
+	for j, raw := range program.Statements {
+		v, err := d.decode(raw, stmtType)
+		if err != nil {
+			return nil, err
+		}
+		stmts[j] = v.Interface().(Stmt)
+	}
+	return stmts, nil
+}
+
+type decoder struct {
+	file *scanner.File
+}
+
+func (d *decoder) span(s jsonSpan) scanner.Span {
+	return scanner.Span{
+		File:  d.file,
+		Start: scanner.Position(s.Start),
+		End:   scanner.Position(s.End),
+	}
+}
+
+// decode returns the value of type t whose JSON form is data.
+func (d *decoder) decode(data json.RawMessage, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if string(data) == "null" {
+		return v, nil
+	}
+
+	switch {
+	case t == tokenType:
+		var token jsonToken
+		if err := json.Unmarshal(data, &token); err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(scanner.NewToken(token.Type, token.Lexeme, token.Literal, d.span(token.Span))))
+	case t.Kind() == reflect.Interface && t != exprType && t != stmtType:
+		var literal interface{}
+		if err := json.Unmarshal(data, &literal); err != nil {
+			return v, err
+		}
+		if literal != nil {
+			v.Set(reflect.ValueOf(literal))
+		}
+	case t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr:
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return v, err
+		}
+
+		node := t
+		if isNode(t) {
+			var name string
+			if err := json.Unmarshal(object["node"], &name); err != nil {
+				return v, fmt.Errorf("parser: node without a type: %w", err)
+			}
+			var ok bool
+			if node, ok = nodeTypes[name]; !ok || !node.AssignableTo(t) {
+				return v, fmt.Errorf("parser: %s node where %s is expected", name, strings.TrimPrefix(t.String(), "parser."))
+			}
+		}
+
+		ptr := reflect.New(node.Elem())
+		fields := ptr.Elem()
+		for j := 0; j < fields.NumField(); j++ {
+			f := fields.Type().Field(j)
+			if f.PkgPath != "" {
+				continue
+			}
+			raw, ok := object[jsonName(f.Name)]
+			if !ok {
+				return v, fmt.Errorf("parser: %s without %s", node.Elem().Name(), jsonName(f.Name))
+			}
+			field, err := d.decode(raw, f.Type)
+			if err != nil {
+				return v, err
+			}
+			fields.Field(j).Set(field)
+		}
+
+		if isNode(node) {
+			var span jsonSpan
+			if err := json.Unmarshal(object["span"], &span); err != nil {
+				return v, fmt.Errorf("parser: %s without a span: %w", node.Elem().Name(), err)
+			}
+			ptr.Interface().(interface{ setSpan(scanner.Span) }).setSpan(d.span(span))
+		}
+		v.Set(ptr)
+	case t.Kind() == reflect.Slice:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return v, err
+		}
+		v.Set(reflect.MakeSlice(t, len(elems), len(elems)))
+		for j, raw := range elems {
+			elem, err := d.decode(raw, t.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.Index(j).Set(elem)
+		}
+	default:
+		return v, fmt.Errorf("parser: can't decode %s from JSON", t)
+	}
+	return v, nil
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// walkJSON calls f with each node object in v, along with the node that
+// holds it, which is nil at the top.
+func walkJSON(v interface{}, parent map[string]interface{}, f func(node, parent map[string]interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if _, ok := v["node"]; ok {
+			f(v, parent)
+			parent = v
+		}
+		for _, field := range v {
+			walkJSON(field, parent, f)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			walkJSON(elem, parent, f)
+		}
+	}
+}
+
+// offsets returns the start and end offsets of a node's span.
+func offsets(node map[string]interface{}) (float64, float64) {
+	span := node["span"].(map[string]interface{})
+	start := span["start"].(map[string]interface{})
+	end := span["end"].(map[string]interface{})
+	return start["offset"].(float64), end["offset"].(float64)
+}
+
+func TestJSON(t *testing.T) {
+	stmts := parseFile(t, "nodes.lox")
+	data, err := EncodeJSON("nodes.lox", stmts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var program map[string]interface{}
+	if err := json.Unmarshal(data, &program); err != nil {
+		t.Fatal(err)
+	}
+	if program["file"] != "nodes.lox" {
+		t.Errorf("got file %v, want nodes.lox", program["file"])
+	}
+
+	// Each node lies within the node that holds it.
+	seen := make(map[string]bool)
+	walkJSON(program["statements"], nil, func(node, parent map[string]interface{}) {
+		kind := node["node"].(string)
+		seen[kind] = true
+		start, end := offsets(node)
+		if start > end {
+			t.Errorf("%s: span starts at %v after it ends at %v", kind, start, end)
+		}
+		if parent != nil {
+			parentStart, parentEnd := offsets(parent)
+			if start < parentStart || end > parentEnd {
+				t.Errorf("%s from %v to %v is outside its %s from %v to %v",
+					kind, start, end, parent["node"], parentStart, parentEnd)
+			}
+		}
+	})
+	for kind := range nodeTypes {
+		if !seen[kind] {
+			t.Errorf("no %s node in the JSON", kind)
+		}
+	}
+
+	// The first statement is the import on line 2.
+	first := program["statements"].([]interface{})[0].(map[string]interface{})
+	start := first["span"].(map[string]interface{})["start"].(map[string]interface{})
+	if first["node"] != "ImportStmt" || start["line"] != 2.0 || start["column"] != 1.0 {
+		t.Errorf("got a first %v starting at %v, want an ImportStmt at 2:1", first["node"], start)
+	}
+
+	// Decoding gives back the same tree, which encodes the same again.
+	decoded, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := (AstPrinter{}).Print(decoded), (AstPrinter{}).Print(stmts); got != want {
+		t.Errorf("decoded\n%s\nwant\n%s", got, want)
+	}
+	again, err := EncodeJSON("nodes.lox", decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("encoding the decoded tree gives\n%s\nwant\n%s", again, data)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"file": "x.lox", "statements": [{"node": "Nope"}]}`,
+		`{"file": "x.lox", "statements": [{"node": "LiteralExpr", "span": {}, "value": 1}]}`,
+		`{"file": "x.lox", "statements": [{"node": "PrintStmt", "span": {}}]}`,
+	} {
+		if _, err := DecodeJSON([]byte(data)); err == nil {
+			t.Errorf("%s: decoded without an error", data)
+		}
+	}
+}
@@ -4,7 +4,10 @@ package parser
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/fosmjo/lox/diag"
 	"github.com/fosmjo/lox/scanner"
 )
 
@@ -13,37 +16,66 @@ const maxArgumentCount = 255
 type Parser struct {
 	tokens  []scanner.Token
 	current int
-	lox     loxer
+	diags   diag.List
 }
 
-type loxer interface {
-	ErrorWithToken(token scanner.Token, msg string)
+// NewParser returns a parser of tokens, as scanned. The scanner's ERROR
+// tokens are reported among the parser's diagnostics and otherwise
+// skipped, their comments going to the tokens that follow them.
+func NewParser(tokens []scanner.Token) *Parser {
+	p := &Parser{
+		tokens:  make([]scanner.Token, 0, len(tokens)),
+		current: 0,
+	}
+
+	var comments []scanner.Comment
+	for _, token := range tokens {
+		if token.Type == scanner.ERROR {
+			p.diags.Add(diag.InvalidToken, diag.Error, token.Span, token.Literal.(string))
+			comments = append(comments, token.Comments...)
+			continue
+		}
+		if comments != nil {
+			token.Comments = append(comments, token.Comments...)
+			comments = nil
+		}
+		p.tokens = append(p.tokens, token)
+	}
+	return p
 }
 
-func NewParser(tokens []scanner.Token, lox loxer) *Parser {
-	return &Parser{
-		tokens:  tokens,
-		lox:     lox,
-		current: 0,
+// Parse parses the tokens into statements. After an error, it skips to the
+// start of the next statement and carries on, so that every error in the
+// source is returned, in order. A declaration that fails to parse is left
+// out of stmts.
+func (p *Parser) Parse() (stmts []Stmt, diags diag.List) {
+	stmts = make([]Stmt, 0)
+	for !p.isAtEnd() {
+		if stmt := p.declaration(); stmt != nil {
+			stmts = append(stmts, stmt)
+		}
 	}
+	return stmts, p.diags
 }
 
-func (p *Parser) Parse() (stmts []Stmt, err error) {
+// ParseExpression parses the tokens as a single expression, such as one a
+// debugger is asked to evaluate. expr is nil if it fails to parse.
+func (p *Parser) ParseExpression() (expr Expr, diags diag.List) {
 	defer func() {
 		if r := recover(); r != nil {
-			if e, ok := r.(ParseError); ok {
-				err = e
-			} else {
+			if _, ok := r.(ParseError); !ok {
 				panic(r)
 			}
+			expr = nil
 		}
+		diags = p.diags
 	}()
 
-	stmts = make([]Stmt, 0)
-	for !p.isAtEnd() {
-		stmts = append(stmts, p.declaration())
+	expr = p.expression()
+	if !p.isAtEnd() {
+		p.error(p.peek(), diag.UnexpectedToken, "Expect end of expression.")
 	}
-	return
+	return expr, p.diags
 }
 
 func (p *Parser) expression() Expr {
@@ -61,13 +93,17 @@ func (p *Parser) declaration() Stmt {
 		}
 	}()
 
+	start := p.peek().Span
 	switch {
 	case p.match(scanner.CLASS):
-		return p.classDeclaration()
-	case p.match(scanner.FUN):
-		return p.function("function")
+		return p.finishStmt(p.classDeclaration(), start)
+	case p.check(scanner.FUN) && p.checkNext(scanner.IDENTIFIER):
+		p.advance()
+		return p.finishStmt(p.function("function"), start)
 	case p.match(scanner.VAR):
-		return p.varDeclaration()
+		return p.finishStmt(p.varDeclaration(), start)
+	case p.match(scanner.IMPORT):
+		return p.finishStmt(p.importDeclaration(), start)
 	}
 
 	return p.statement()
@@ -80,49 +116,125 @@ func (p *Parser) classDeclaration() Stmt {
 	if p.match(scanner.LESS) {
 		p.consume(scanner.IDENTIFIER, "Expect superclass name.")
 		superclass = NewVariableExpr(p.previous())
+		superclass.setSpan(p.previous().Span)
 	}
 
 	p.consume(scanner.LEFT_BRACE, "Expect '{' before class body.")
 
+	fields := make([]*Field, 0)
 	methods := make([]*FunctionStmt, 0)
 	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
-		methods = append(methods, p.function("method"))
+		if p.check(scanner.IDENTIFIER) && p.checkNext(scanner.COLON) {
+			fields = append(fields, p.field())
+		} else {
+			methods = append(methods, p.function("method"))
+		}
 	}
 
 	p.consume(scanner.RIGHT_BRACE, "Expect '}' after class body.")
-	return NewClassStmt(name, superclass, methods)
+	return NewClassStmt(name, superclass, fields, methods)
+}
+
+func (p *Parser) field() *Field {
+	name := p.advance()
+	annotation := p.annotation()
+	p.consume(scanner.SEMICOLON, "Expect ';' after field type.")
+	return &Field{Name: name, Annotation: annotation}
+}
+
+func (p *Parser) importDeclaration() Stmt {
+	keyword := p.previous()
+	path := p.consume(scanner.STRING, "Expect module path after 'import'.")
+
+	var alias scanner.Token
+	names := make([]scanner.Token, 0)
+	switch {
+	case p.match(scanner.FOR):
+		names = append(names, p.consume(scanner.IDENTIFIER, "Expect name to import."))
+		for p.match(scanner.COMMA) {
+			names = append(names, p.consume(scanner.IDENTIFIER, "Expect name to import."))
+		}
+	case p.match(scanner.AS):
+		alias = p.consume(scanner.IDENTIFIER, "Expect module name after 'as'.")
+	default:
+		alias = p.moduleName(path)
+	}
+
+	p.consume(scanner.SEMICOLON, "Expect ';' after import.")
+	return NewImportStmt(keyword, path, alias, names)
+}
+
+// moduleName derives the name a module is bound to from its file name, so
+// that `import "lib/math.lox";` binds `math`.
+func (p *Parser) moduleName(path scanner.Token) scanner.Token {
+	base := filepath.Base(path.Literal.(string))
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		isAlpha := ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		if !isAlpha && (i == 0 || ch < '0' || ch > '9') {
+			err := p.error(path, diag.InvalidModuleName, "Can't derive a module name from '"+base+"', use 'as' to name it.")
+			panic(err)
+		}
+	}
+
+	return scanner.NewToken(scanner.IDENTIFIER, name, nil, path.Span)
 }
 
 func (p *Parser) statement() Stmt {
+	start := p.peek().Span
 	switch {
+	case p.match(scanner.BREAK):
+		return p.finishStmt(p.breakStatement(), start)
+	case p.match(scanner.CONTINUE):
+		return p.finishStmt(p.continueStatement(), start)
 	case p.match(scanner.FOR):
-		return p.forStatement()
+		return p.finishStmt(p.forStatement(), start)
 	case p.match(scanner.IF):
-		return p.ifStatement()
+		return p.finishStmt(p.ifStatement(), start)
 	case p.match(scanner.PRINT):
-		return p.printStatement()
+		return p.finishStmt(p.printStatement(), start)
 	case p.match(scanner.RETURN):
-		return p.returnStatement()
+		return p.finishStmt(p.returnStatement(), start)
+	case p.match(scanner.THROW):
+		return p.finishStmt(p.throwStatement(), start)
+	case p.match(scanner.TRY):
+		return p.finishStmt(p.tryStatement(), start)
 	case p.match(scanner.WHILE):
-		return p.whileStatement()
+		return p.finishStmt(p.whileStatement(), start)
 	case p.match(scanner.LEFT_BRACE):
-		return NewBlockStmt(p.block())
+		return p.finishStmt(NewBlockStmt(p.block()), start)
 	default:
-		return p.expressionStatement()
+		return p.finishStmt(p.expressionStatement(), start)
 	}
 }
 
+func (p *Parser) breakStatement() Stmt {
+	keyword := p.previous()
+	p.consume(scanner.SEMICOLON, "Expect ';' after 'break'.")
+	return NewBreakStmt(keyword)
+}
+
+func (p *Parser) continueStatement() Stmt {
+	keyword := p.previous()
+	p.consume(scanner.SEMICOLON, "Expect ';' after 'continue'.")
+	return NewContinueStmt(keyword)
+}
+
 func (p *Parser) forStatement() Stmt {
+	start := p.previous().Span
 	p.consume(scanner.LEFT_PAREN, "Expect '(' after 'for'.")
 
 	var initializer Stmt
+	initStart := p.peek().Span
 	switch {
 	case p.match(scanner.SEMICOLON):
 		initializer = nil
 	case p.match(scanner.VAR):
-		initializer = p.varDeclaration()
+		initializer = p.finishStmt(p.varDeclaration(), initStart)
 	default:
-		initializer = p.expressionStatement()
+		initializer = p.finishStmt(p.expressionStatement(), initStart)
 	}
 
 	var condition Expr
@@ -130,6 +242,9 @@ func (p *Parser) forStatement() Stmt {
 		condition = p.expression()
 	}
 	p.consume(scanner.SEMICOLON, "Expect ';' after loop condition.")
+	if condition == nil {
+		condition = p.finishExpr(NewLiteralExpr(true), p.previous().Span)
+	}
 
 	var increment Expr
 	if !p.check(scanner.RIGHT_PAREN) {
@@ -139,17 +254,12 @@ func (p *Parser) forStatement() Stmt {
 
 	body := p.statement()
 
-	if increment != nil {
-		body = NewBlockStmt([]Stmt{body, NewExpressionStmt(increment)})
-	}
-
-	if condition == nil {
-		condition = NewLiteralExpr(true)
-	}
-	body = NewWhileStmt(condition, body)
+	// The increment is kept apart from the body so that 'continue' still
+	// runs it before the next iteration.
+	body = p.finishStmt(NewWhileStmt(condition, body, increment), start)
 
 	if initializer != nil {
-		body = NewBlockStmt([]Stmt{initializer, body})
+		body = p.finishStmt(NewBlockStmt([]Stmt{initializer, body}), start)
 	}
 
 	return body
@@ -186,8 +296,47 @@ func (p *Parser) returnStatement() Stmt {
 	return NewReturnStmt(keyword, value)
 }
 
+func (p *Parser) throwStatement() Stmt {
+	keyword := p.previous()
+	value := p.expression()
+	p.consume(scanner.SEMICOLON, "Expect ';' after thrown value.")
+
+	return NewThrowStmt(keyword, value)
+}
+
+func (p *Parser) tryStatement() Stmt {
+	p.consume(scanner.LEFT_BRACE, "Expect '{' after 'try'.")
+	body := p.block()
+
+	var catchName scanner.Token
+	var catchBody []Stmt
+	if p.match(scanner.CATCH) {
+		p.consume(scanner.LEFT_PAREN, "Expect '(' after 'catch'.")
+		catchName = p.consume(scanner.IDENTIFIER, "Expect exception variable name.")
+		p.consume(scanner.RIGHT_PAREN, "Expect ')' after exception variable name.")
+		p.consume(scanner.LEFT_BRACE, "Expect '{' before catch body.")
+		catchBody = p.block()
+	}
+
+	var finallyBody []Stmt
+	if p.match(scanner.FINALLY) {
+		p.consume(scanner.LEFT_BRACE, "Expect '{' after 'finally'.")
+		finallyBody = p.block()
+	}
+
+	if catchBody == nil && finallyBody == nil {
+		_ = p.error(p.peek(), diag.IncompleteTry, "Expect 'catch' or 'finally' after try block.")
+	}
+
+	return NewTryStmt(body, catchName, catchBody, finallyBody)
+}
+
 func (p *Parser) varDeclaration() Stmt {
 	name := p.consume(scanner.IDENTIFIER, "Expect variable name.")
+	var annotation *Annotation
+	if p.check(scanner.COLON) {
+		annotation = p.annotation()
+	}
 
 	var initializer Expr
 	if p.match(scanner.EQUAL) {
@@ -195,7 +344,7 @@ func (p *Parser) varDeclaration() Stmt {
 	}
 
 	p.consume(scanner.SEMICOLON, "Expect ';' after variable declaration.")
-	return NewVarStmt(name, initializer)
+	return NewVarStmt(name, annotation, initializer)
 }
 
 func (p *Parser) whileStatement() Stmt {
@@ -204,7 +353,7 @@ func (p *Parser) whileStatement() Stmt {
 	p.consume(scanner.RIGHT_PAREN, "Expect '(' after 'while'.")
 	body := p.statement()
 
-	return NewWhileStmt(condition, body)
+	return NewWhileStmt(condition, body, nil)
 }
 
 func (p *Parser) expressionStatement() Stmt {
@@ -215,37 +364,74 @@ func (p *Parser) expressionStatement() Stmt {
 
 func (p *Parser) function(kind string) *FunctionStmt {
 	name := p.consume(scanner.IDENTIFIER, "Expect "+kind+" name.")
-
 	p.consume(scanner.LEFT_PAREN, "Expect '(' after "+kind+" name.")
+	function := p.functionBody(name, kind)
+	p.finishStmt(function, name.Span)
+	return function
+}
+
+func (p *Parser) lambda() Expr {
+	keyword := p.previous()
+	p.consume(scanner.LEFT_PAREN, "Expect '(' after 'fun'.")
+	// An anonymous function is named by its 'fun' keyword.
+	function := p.functionBody(keyword, "function")
+	p.finishStmt(function, keyword.Span)
+	return p.finishExpr(NewLambdaExpr(function), keyword.Span)
+}
+
+func (p *Parser) functionBody(name scanner.Token, kind string) *FunctionStmt {
 	parameters := make([]scanner.Token, 0)
+	// paramTypes holds nil for the parameters that aren't annotated.
+	paramTypes := make([]*Annotation, 0)
 	if !p.check(scanner.RIGHT_PAREN) {
-		parameters = p.addFunctionParameter(parameters)
+		parameters, paramTypes = p.addFunctionParameter(parameters, paramTypes)
 
 		for p.match(scanner.COMMA) {
-			parameters = p.addFunctionParameter(parameters)
+			parameters, paramTypes = p.addFunctionParameter(parameters, paramTypes)
 		}
 	}
 	p.consume(scanner.RIGHT_PAREN, "Expect ')' after parameters.")
 
+	var returnType *Annotation
+	if p.check(scanner.COLON) {
+		returnType = p.annotation()
+	}
+
 	p.consume(scanner.LEFT_BRACE, "Expect '{' before "+kind+" body.")
 	body := p.block()
-	return NewFunctionStmt(name, parameters, body)
+	return NewFunctionStmt(name, parameters, paramTypes, returnType, body)
 }
 
-func (p *Parser) addFunctionParameter(parameters []scanner.Token) []scanner.Token {
+func (p *Parser) addFunctionParameter(parameters []scanner.Token, paramTypes []*Annotation) ([]scanner.Token, []*Annotation) {
 	if len(parameters) >= maxArgumentCount {
-		_ = p.error(p.peek(), fmt.Sprintf("Can't have more than %d parameters.", maxArgumentCount))
+		_ = p.error(p.peek(), diag.TooManyArguments, fmt.Sprintf("Can't have more than %d parameters.", maxArgumentCount))
 	}
 
 	param := p.consume(scanner.IDENTIFIER, "Expect parameter name.")
-	return append(parameters, param)
+	var annotation *Annotation
+	if p.check(scanner.COLON) {
+		annotation = p.annotation()
+	}
+	return append(parameters, param), append(paramTypes, annotation)
+}
+
+// annotation parses a colon and the type name after it.
+func (p *Parser) annotation() *Annotation {
+	p.consume(scanner.COLON, "Expect ':' before type.")
+	if p.match(scanner.IDENTIFIER, scanner.NIL) {
+		return &Annotation{Name: p.previous()}
+	}
+	err := p.error(p.peek(), diag.UnexpectedToken, "Expect type name.")
+	panic(err)
 }
 
 func (p *Parser) block() []Stmt {
 	stmts := make([]Stmt, 0)
 
 	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
-		stmts = append(stmts, p.declaration())
+		if stmt := p.declaration(); stmt != nil {
+			stmts = append(stmts, stmt)
+		}
 	}
 
 	p.consume(scanner.RIGHT_BRACE, "Expect '}' after block.")
@@ -253,6 +439,7 @@ func (p *Parser) block() []Stmt {
 }
 
 func (p *Parser) assignment() Expr {
+	start := p.peek().Span
 	expr := p.or()
 
 	if p.match(scanner.EQUAL) {
@@ -260,86 +447,96 @@ func (p *Parser) assignment() Expr {
 		value := p.assignment()
 
 		if varExpr, ok := expr.(*VariableExpr); ok {
-			return NewAssignExpr(varExpr.Name, value)
+			return p.finishExpr(NewAssignExpr(varExpr.Name, value), start)
 		}
 
 		if getExpr, ok := expr.(*GetExpr); ok {
-			return NewSetExpr(getExpr.Object, getExpr.Name, value)
+			return p.finishExpr(NewSetExpr(getExpr.Object, getExpr.Name, value), start)
 		}
 
-		_ = p.error(equals, "Invalid assignment target.")
+		if indexExpr, ok := expr.(*IndexExpr); ok {
+			return p.finishExpr(NewIndexSetExpr(indexExpr.Object, indexExpr.Bracket, indexExpr.Index, value), start)
+		}
+
+		_ = p.error(equals, diag.InvalidAssignment, "Invalid assignment target.")
 	}
 
 	return expr
 }
 
 func (p *Parser) or() Expr {
+	start := p.peek().Span
 	expr := p.and()
 
 	for p.match(scanner.OR) {
 		operator := p.previous()
 		right := p.and()
-		expr = NewLogicalExpr(expr, operator, right)
+		expr = p.finishExpr(NewLogicalExpr(expr, operator, right), start)
 	}
 
 	return expr
 }
 
 func (p *Parser) and() Expr {
+	start := p.peek().Span
 	expr := p.equality()
 
 	for p.match(scanner.AND) {
 		operator := p.previous()
 		right := p.equality()
-		expr = NewLogicalExpr(expr, operator, right)
+		expr = p.finishExpr(NewLogicalExpr(expr, operator, right), start)
 	}
 
 	return expr
 }
 
 func (p *Parser) equality() Expr {
+	start := p.peek().Span
 	expr := p.comparison()
 
 	for p.match(scanner.BANG_EQUAL, scanner.EQUAL_EQUAL) {
 		operator := p.previous()
 		right := p.comparison()
-		expr = NewBinaryExpr(expr, operator, right)
+		expr = p.finishExpr(NewBinaryExpr(expr, operator, right), start)
 	}
 
 	return expr
 }
 
 func (p *Parser) comparison() Expr {
+	start := p.peek().Span
 	expr := p.term()
 
 	for p.match(scanner.GREATER, scanner.GREATER_EQUAL, scanner.LESS, scanner.LESS_EQUAL) {
 		operator := p.previous()
 		right := p.term()
-		expr = NewBinaryExpr(expr, operator, right)
+		expr = p.finishExpr(NewBinaryExpr(expr, operator, right), start)
 	}
 
 	return expr
 }
 
 func (p *Parser) term() Expr {
+	start := p.peek().Span
 	expr := p.factor()
 
 	for p.match(scanner.MINUS, scanner.PLUS) {
 		operator := p.previous()
 		right := p.factor()
-		expr = NewBinaryExpr(expr, operator, right)
+		expr = p.finishExpr(NewBinaryExpr(expr, operator, right), start)
 	}
 
 	return expr
 }
 
 func (p *Parser) factor() Expr {
+	start := p.peek().Span
 	expr := p.unary()
 
 	for p.match(scanner.SLASH, scanner.STAR) {
 		operator := p.previous()
 		right := p.unary()
-		expr = NewBinaryExpr(expr, operator, right)
+		expr = p.finishExpr(NewBinaryExpr(expr, operator, right), start)
 	}
 
 	return expr
@@ -349,21 +546,26 @@ func (p *Parser) unary() Expr {
 	if p.match(scanner.MINUS, scanner.BANG) {
 		operator := p.previous()
 		right := p.unary()
-		return NewUnaryExpr(operator, right)
+		return p.finishExpr(NewUnaryExpr(operator, right), operator.Span)
 	}
 
 	return p.call()
 }
 
 func (p *Parser) call() Expr {
+	start := p.peek().Span
 	expr := p.primary()
 
 	for {
 		if p.match(scanner.LEFT_PAREN) {
-			expr = p.finishCall(expr)
+			expr = p.finishExpr(p.finishCall(expr), start)
 		} else if p.match(scanner.DOT) {
 			name := p.consume(scanner.IDENTIFIER, "Excpect property name after '.'.")
-			expr = NewGetExpr(expr, name)
+			expr = p.finishExpr(NewGetExpr(expr, name), start)
+		} else if p.match(scanner.LEFT_BRACKET) {
+			index := p.expression()
+			bracket := p.consume(scanner.RIGHT_BRACKET, "Expect ']' after index.")
+			expr = p.finishExpr(NewIndexExpr(expr, bracket, index), start)
 		} else {
 			break
 		}
@@ -389,41 +591,105 @@ func (p *Parser) finishCall(callee Expr) Expr {
 
 func (p *Parser) addCallArgument(arguments []Expr) []Expr {
 	if len(arguments) > maxArgumentCount {
-		_ = p.error(p.peek(), fmt.Sprintf("Can't have more than %d arguments.", maxArgumentCount))
+		_ = p.error(p.peek(), diag.TooManyArguments, fmt.Sprintf("Can't have more than %d arguments.", maxArgumentCount))
 	}
 
 	return append(arguments, p.expression())
 }
 
 func (p *Parser) primary() Expr {
+	start := p.peek().Span
 	switch {
 	case p.match(scanner.FALSE):
-		return NewLiteralExpr(false)
+		return p.finishExpr(NewLiteralExpr(false), start)
 	case p.match(scanner.TRUE):
-		return NewLiteralExpr(true)
+		return p.finishExpr(NewLiteralExpr(true), start)
 	case p.match(scanner.NIL):
-		return NewLiteralExpr(nil)
+		return p.finishExpr(NewLiteralExpr(nil), start)
 	case p.match(scanner.NUMBER, scanner.STRING):
-		return NewLiteralExpr(p.previous().Literal)
+		return p.finishExpr(NewLiteralExpr(p.previous().Literal), start)
 	case p.match(scanner.SUPER):
 		keyword := p.previous()
 		p.consume(scanner.DOT, "Expect '.' after 'super'.")
 		method := p.consume(scanner.IDENTIFIER, "Expect superclass method name.")
-		return NewSuperExpr(keyword, method)
+		return p.finishExpr(NewSuperExpr(keyword, method), start)
 	case p.match(scanner.THIS):
-		return NewThisExpr(p.previous())
+		return p.finishExpr(NewThisExpr(p.previous()), start)
 	case p.match(scanner.IDENTIFIER):
-		return NewVariableExpr(p.previous())
+		return p.finishExpr(NewVariableExpr(p.previous()), start)
 	case p.match(scanner.LEFT_PAREN):
 		expr := p.expression()
 		p.consume(scanner.RIGHT_PAREN, "Expect ')' after expression.")
-		return NewGroupingExpr(expr)
+		return p.finishExpr(NewGroupingExpr(expr), start)
+	case p.match(scanner.FUN):
+		return p.finishExpr(p.lambda(), start)
+	case p.match(scanner.LEFT_BRACKET):
+		return p.finishExpr(p.listLiteral(), start)
+	case p.match(scanner.LEFT_BRACE):
+		return p.finishExpr(p.mapLiteral(), start)
 	default:
-		err := p.error(p.peek(), "Expect expression.")
+		err := p.error(p.peek(), diag.ExpectExpression, "Expect expression.")
 		panic(err)
 	}
 }
 
+func (p *Parser) listLiteral() Expr {
+	elements := make([]Expr, 0)
+
+	if !p.check(scanner.RIGHT_BRACKET) {
+		elements = append(elements, p.expression())
+
+		for p.match(scanner.COMMA) {
+			elements = append(elements, p.expression())
+		}
+	}
+
+	p.consume(scanner.RIGHT_BRACKET, "Expect ']' after list elements.")
+	return NewListExpr(elements)
+}
+
+func (p *Parser) mapLiteral() Expr {
+	brace := p.previous()
+	keys := make([]Expr, 0)
+	values := make([]Expr, 0)
+
+	if !p.check(scanner.RIGHT_BRACE) {
+		keys, values = p.addMapEntry(keys, values)
+
+		for p.match(scanner.COMMA) {
+			keys, values = p.addMapEntry(keys, values)
+		}
+	}
+
+	p.consume(scanner.RIGHT_BRACE, "Expect '}' after map entries.")
+	return NewMapExpr(brace, keys, values)
+}
+
+func (p *Parser) addMapEntry(keys, values []Expr) ([]Expr, []Expr) {
+	key := p.expression()
+	p.consume(scanner.COLON, "Expect ':' after map key.")
+	value := p.expression()
+	return append(keys, key), append(values, value)
+}
+
+// finishStmt sets the span of stmt to run from start to the end of the last
+// consumed token.
+func (p *Parser) finishStmt(stmt Stmt, start scanner.Span) Stmt {
+	stmt.setSpan(p.spanFrom(start))
+	return stmt
+}
+
+// finishExpr sets the span of expr to run from start to the end of the last
+// consumed token.
+func (p *Parser) finishExpr(expr Expr, start scanner.Span) Expr {
+	expr.setSpan(p.spanFrom(start))
+	return expr
+}
+
+func (p *Parser) spanFrom(start scanner.Span) scanner.Span {
+	return scanner.Span{File: start.File, Start: start.Start, End: p.previous().Span.End}
+}
+
 func (p *Parser) match(types ...scanner.TokenType) bool {
 	for _, t := range types {
 		if p.check(t) {
@@ -438,12 +704,12 @@ func (p *Parser) consume(t scanner.TokenType, msg string) scanner.Token {
 	if p.check(t) {
 		return p.advance()
 	}
-	err := p.error(p.peek(), msg)
+	err := p.error(p.peek(), diag.UnexpectedToken, msg)
 	panic(err)
 }
 
-func (p *Parser) error(token scanner.Token, msg string) ParseError {
-	p.lox.ErrorWithToken(token, msg)
+func (p *Parser) error(token scanner.Token, code diag.Code, msg string) ParseError {
+	p.diags.Add(code, diag.Error, token.Span, msg)
 	return ParseError{msg: msg}
 }
 
@@ -458,12 +724,15 @@ func (p *Parser) synchronize() {
 		switch p.peek().Type {
 		case scanner.CLASS,
 			scanner.FUN,
+			scanner.IMPORT,
 			scanner.VAR,
 			scanner.FOR,
 			scanner.IF,
 			scanner.WHILE,
 			scanner.PRINT,
-			scanner.RETURN:
+			scanner.RETURN,
+			scanner.THROW,
+			scanner.TRY:
 			return
 		}
 
@@ -486,6 +755,14 @@ func (p *Parser) check(t scanner.TokenType) bool {
 	return p.peek().Type == t
 }
 
+func (p *Parser) checkNext(t scanner.TokenType) bool {
+	if p.isAtEnd() {
+		return false
+	}
+
+	return p.tokens[p.current+1].Type == t
+}
+
 func (p *Parser) isAtEnd() bool {
 	return p.peek().Type == scanner.EOF
 }
@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+// AstPrinter prints syntax trees as S-expressions, such as
+// `(print (+ 1 (group (* 2 3))))`, to show how the parser grouped them.
+type AstPrinter struct{}
+
+// Print returns the S-expressions for stmts, one per line.
+func (p AstPrinter) Print(stmts []Stmt) string {
+	var b strings.Builder
+	for _, stmt := range stmts {
+		b.WriteString(p.stmt(stmt))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// PrintExpr returns the S-expression for expr.
+func (p AstPrinter) PrintExpr(expr Expr) string {
+	s, _ := expr.Accept(p)
+	return s.(string)
+}
+
+func (p AstPrinter) stmt(stmt Stmt) string {
+	s, _ := stmt.Accept(p)
+	return s.(string)
+}
+
+// parenthesize returns a list of name and parts, which may be strings,
+// tokens, expressions, statements, or slices of those.
+func (p AstPrinter) parenthesize(name string, parts ...interface{}) (interface{}, error) {
+	var b strings.Builder
+	b.WriteString("(" + name)
+	for _, part := range parts {
+		switch part := part.(type) {
+		case string:
+			b.WriteString(" " + part)
+		case scanner.Token:
+			b.WriteString(" " + part.Lexeme)
+		case Expr:
+			b.WriteString(" " + p.PrintExpr(part))
+		case Stmt:
+			b.WriteString(" " + p.stmt(part))
+		case []Expr:
+			for _, expr := range part {
+				b.WriteString(" " + p.PrintExpr(expr))
+			}
+		case []Stmt:
+			for _, stmt := range part {
+				b.WriteString(" " + p.stmt(stmt))
+			}
+		}
+	}
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+// typed returns name with its type annotation, if it has one.
+func typed(name scanner.Token, a *Annotation) string {
+	if a == nil {
+		return name.Lexeme
+	}
+	return name.Lexeme + ":" + a.Name.Lexeme
+}
+
+// function returns the parameters and body of stmt, for parenthesize.
+func (p AstPrinter) function(stmt *FunctionStmt) []interface{} {
+	params := make([]string, len(stmt.Params))
+	for j, param := range stmt.Params {
+		params[j] = typed(param, stmt.ParamTypes[j])
+	}
+	signature := "(" + strings.Join(params, " ") + ")"
+	if stmt.ReturnType != nil {
+		signature += ":" + stmt.ReturnType.Name.Lexeme
+	}
+	return []interface{}{signature, stmt.Body}
+}
+
+func (p AstPrinter) VisitBlockStmt(stmt *BlockStmt) (interface{}, error) {
+	return p.parenthesize("block", stmt.Statements)
+}
+
+func (p AstPrinter) VisitBreakStmt(stmt *BreakStmt) (interface{}, error) {
+	return "(break)", nil
+}
+
+func (p AstPrinter) VisitExpressionStmt(stmt *ExpressionStmt) (interface{}, error) {
+	return p.parenthesize(";", stmt.Expression)
+}
+
+func (p AstPrinter) VisitClassStmt(stmt *ClassStmt) (interface{}, error) {
+	parts := []interface{}{stmt.Name}
+	if stmt.Superclass != nil {
+		parts = append(parts, "<", stmt.Superclass.Name)
+	}
+	for _, field := range stmt.Fields {
+		parts = append(parts, "(field "+typed(field.Name, field.Annotation)+")")
+	}
+	for _, method := range stmt.Methods {
+		s, _ := p.parenthesize("method", append([]interface{}{method.Name}, p.function(method)...)...)
+		parts = append(parts, s)
+	}
+	return p.parenthesize("class", parts...)
+}
+
+func (p AstPrinter) VisitContinueStmt(stmt *ContinueStmt) (interface{}, error) {
+	return "(continue)", nil
+}
+
+func (p AstPrinter) VisitFunctionStmt(stmt *FunctionStmt) (interface{}, error) {
+	return p.parenthesize("fun", append([]interface{}{stmt.Name}, p.function(stmt)...)...)
+}
+
+func (p AstPrinter) VisitIfStmt(stmt *IfStmt) (interface{}, error) {
+	if stmt.ElseBranch == nil {
+		return p.parenthesize("if", stmt.Condition, stmt.ThenBranch)
+	}
+	return p.parenthesize("if", stmt.Condition, stmt.ThenBranch, stmt.ElseBranch)
+}
+
+func (p AstPrinter) VisitImportStmt(stmt *ImportStmt) (interface{}, error) {
+	if len(stmt.Names) == 0 {
+		return p.parenthesize("import", stmt.Path, "as", stmt.Alias)
+	}
+	parts := []interface{}{stmt.Path, "for"}
+	for _, name := range stmt.Names {
+		parts = append(parts, name)
+	}
+	return p.parenthesize("import", parts...)
+}
+
+func (p AstPrinter) VisitPrintStmt(stmt *PrintStmt) (interface{}, error) {
+	return p.parenthesize("print", stmt.Expression)
+}
+
+func (p AstPrinter) VisitReturnStmt(stmt *ReturnStmt) (interface{}, error) {
+	if stmt.Value == nil {
+		return "(return)", nil
+	}
+	return p.parenthesize("return", stmt.Value)
+}
+
+func (p AstPrinter) VisitThrowStmt(stmt *ThrowStmt) (interface{}, error) {
+	return p.parenthesize("throw", stmt.Value)
+}
+
+func (p AstPrinter) VisitTryStmt(stmt *TryStmt) (interface{}, error) {
+	parts := []interface{}{p.stmt(NewBlockStmt(stmt.Body))}
+	if stmt.CatchBody != nil {
+		s, _ := p.parenthesize("catch", "("+stmt.CatchName.Lexeme+")", stmt.CatchBody)
+		parts = append(parts, s)
+	}
+	if stmt.FinallyBody != nil {
+		s, _ := p.parenthesize("finally", stmt.FinallyBody)
+		parts = append(parts, s)
+	}
+	return p.parenthesize("try", parts...)
+}
+
+func (p AstPrinter) VisitVarStmt(stmt *VarStmt) (interface{}, error) {
+	name := typed(stmt.Name, stmt.Annotation)
+	if stmt.Initializer == nil {
+		return p.parenthesize("var", name)
+	}
+	return p.parenthesize("var", name, stmt.Initializer)
+}
+
+func (p AstPrinter) VisitWhileStmt(stmt *WhileStmt) (interface{}, error) {
+	if stmt.Increment == nil {
+		return p.parenthesize("while", stmt.Condition, stmt.Body)
+	}
+	return p.parenthesize("while", stmt.Condition, stmt.Body, stmt.Increment)
+}
+
+func (p AstPrinter) VisitAssignExpr(expr *AssignExpr) (interface{}, error) {
+	return p.parenthesize("=", expr.Name, expr.Value)
+}
+
+func (p AstPrinter) VisitBinaryExpr(expr *BinaryExpr) (interface{}, error) {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (p AstPrinter) VisitCallExpr(expr *CallExpr) (interface{}, error) {
+	return p.parenthesize("call", expr.Callee, expr.Arguments)
+}
+
+func (p AstPrinter) VisitGetExpr(expr *GetExpr) (interface{}, error) {
+	return p.parenthesize(".", expr.Object, expr.Name)
+}
+
+func (p AstPrinter) VisitGroupingExpr(expr *GroupingExpr) (interface{}, error) {
+	return p.parenthesize("group", expr.Expression)
+}
+
+func (p AstPrinter) VisitIndexExpr(expr *IndexExpr) (interface{}, error) {
+	return p.parenthesize("[]", expr.Object, expr.Index)
+}
+
+func (p AstPrinter) VisitIndexSetExpr(expr *IndexSetExpr) (interface{}, error) {
+	return p.parenthesize("[]=", expr.Object, expr.Index, expr.Value)
+}
+
+func (p AstPrinter) VisitLambdaExpr(expr *LambdaExpr) (interface{}, error) {
+	return p.parenthesize("fun", p.function(expr.Declaration)...)
+}
+
+func (p AstPrinter) VisitListExpr(expr *ListExpr) (interface{}, error) {
+	return p.parenthesize("list", expr.Elements)
+}
+
+func (p AstPrinter) VisitLiteralExpr(expr *LiteralExpr) (interface{}, error) {
+	switch value := expr.Value.(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case string:
+		return strconv.Quote(value), nil
+	}
+	return "?", nil
+}
+
+func (p AstPrinter) VisitLogicalExpr(expr *LogicalExpr) (interface{}, error) {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (p AstPrinter) VisitMapExpr(expr *MapExpr) (interface{}, error) {
+	parts := make([]interface{}, len(expr.Keys))
+	for j := range expr.Keys {
+		parts[j], _ = p.parenthesize(":", expr.Keys[j], expr.Values[j])
+	}
+	return p.parenthesize("map", parts...)
+}
+
+func (p AstPrinter) VisitSetExpr(expr *SetExpr) (interface{}, error) {
+	return p.parenthesize("=.", expr.Object, expr.Name, expr.Value)
+}
+
+func (p AstPrinter) VisitSuperExpr(expr *SuperExpr) (interface{}, error) {
+	return p.parenthesize("super", expr.Method)
+}
+
+func (p AstPrinter) VisitThisExpr(expr *ThisExpr) (interface{}, error) {
+	return "this", nil
+}
+
+func (p AstPrinter) VisitUnaryExpr(expr *UnaryExpr) (interface{}, error) {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Right)
+}
+
+func (p AstPrinter) VisitVariableExpr(expr *VariableExpr) (interface{}, error) {
+	return expr.Name.Lexeme, nil
+}
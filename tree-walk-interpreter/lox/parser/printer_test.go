@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fosmjo/lox/scanner"
+)
+
+// parseFile parses the file in testdata, which must have no errors.
+func parseFile(t *testing.T, name string) []Stmt {
+	t.Helper()
+	src, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := scanner.New(string(src), scanner.WithFileName(name)).ScanTokens()
+	stmts, diags := NewParser(tokens).Parse()
+	if len(diags) > 0 {
+		t.Fatal(diags)
+	}
+	return stmts
+}
+
+// TestPrint prints testdata/nodes.lox, which has a node of every type, and
+// compares the result with testdata/nodes.golden.
+func TestPrint(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("testdata", "nodes.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (AstPrinter{}).Print(parseFile(t, "nodes.lox")); got != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
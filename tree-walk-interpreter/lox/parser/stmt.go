@@ -5,23 +5,32 @@ package parser
 import "github.com/fosmjo/lox/scanner"
 
 type Stmt interface {
-	Accept(StmtVisitor) interface{}
+	Accept(StmtVisitor) (interface{}, error)
+	Span() scanner.Span
+	setSpan(scanner.Span)
 }
 
 type StmtVisitor interface {
-	VisitBlockStmt(*BlockStmt) interface{}
-	VisitExpressionStmt(*ExpressionStmt) interface{}
-	VisitClassStmt(*ClassStmt) interface{}
-	VisitFunctionStmt(*FunctionStmt) interface{}
-	VisitIfStmt(*IfStmt) interface{}
-	VisitPrintStmt(*PrintStmt) interface{}
-	VisitReturnStmt(*ReturnStmt) interface{}
-	VisitVarStmt(*VarStmt) interface{}
-	VisitWhileStmt(*WhileStmt) interface{}
+	VisitBlockStmt(*BlockStmt) (interface{}, error)
+	VisitBreakStmt(*BreakStmt) (interface{}, error)
+	VisitExpressionStmt(*ExpressionStmt) (interface{}, error)
+	VisitClassStmt(*ClassStmt) (interface{}, error)
+	VisitContinueStmt(*ContinueStmt) (interface{}, error)
+	VisitFunctionStmt(*FunctionStmt) (interface{}, error)
+	VisitIfStmt(*IfStmt) (interface{}, error)
+	VisitImportStmt(*ImportStmt) (interface{}, error)
+	VisitPrintStmt(*PrintStmt) (interface{}, error)
+	VisitReturnStmt(*ReturnStmt) (interface{}, error)
+	VisitThrowStmt(*ThrowStmt) (interface{}, error)
+	VisitTryStmt(*TryStmt) (interface{}, error)
+	VisitVarStmt(*VarStmt) (interface{}, error)
+	VisitWhileStmt(*WhileStmt) (interface{}, error)
 }
 
 type BlockStmt struct {
 	Statements []Stmt
+
+	span scanner.Span
 }
 
 func NewBlockStmt(statements []Stmt) *BlockStmt {
@@ -30,12 +39,46 @@ func NewBlockStmt(statements []Stmt) *BlockStmt {
 	}
 }
 
-func (stmt *BlockStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *BlockStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitBlockStmt(stmt)
 }
 
+func (stmt *BlockStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *BlockStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
+type BreakStmt struct {
+	Keyword scanner.Token
+
+	span scanner.Span
+}
+
+func NewBreakStmt(keyword scanner.Token) *BreakStmt {
+	return &BreakStmt{
+		Keyword: keyword,
+	}
+}
+
+func (stmt *BreakStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitBreakStmt(stmt)
+}
+
+func (stmt *BreakStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *BreakStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type ExpressionStmt struct {
 	Expression Expr
+
+	span scanner.Span
 }
 
 func NewExpressionStmt(expression Expr) *ExpressionStmt {
@@ -44,50 +87,110 @@ func NewExpressionStmt(expression Expr) *ExpressionStmt {
 	}
 }
 
-func (stmt *ExpressionStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *ExpressionStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitExpressionStmt(stmt)
 }
 
+func (stmt *ExpressionStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *ExpressionStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type ClassStmt struct {
 	Name       scanner.Token
 	Superclass *VariableExpr
+	Fields     []*Field
 	Methods    []*FunctionStmt
+
+	span scanner.Span
 }
 
-func NewClassStmt(name scanner.Token, superclass *VariableExpr, methods []*FunctionStmt) *ClassStmt {
+func NewClassStmt(name scanner.Token, superclass *VariableExpr, fields []*Field, methods []*FunctionStmt) *ClassStmt {
 	return &ClassStmt{
 		Name:       name,
 		Superclass: superclass,
+		Fields:     fields,
 		Methods:    methods,
 	}
 }
 
-func (stmt *ClassStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *ClassStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitClassStmt(stmt)
 }
 
+func (stmt *ClassStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *ClassStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
+type ContinueStmt struct {
+	Keyword scanner.Token
+
+	span scanner.Span
+}
+
+func NewContinueStmt(keyword scanner.Token) *ContinueStmt {
+	return &ContinueStmt{
+		Keyword: keyword,
+	}
+}
+
+func (stmt *ContinueStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitContinueStmt(stmt)
+}
+
+func (stmt *ContinueStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *ContinueStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type FunctionStmt struct {
-	Name   scanner.Token
-	Params []scanner.Token
-	Body   []Stmt
+	Name       scanner.Token
+	Params     []scanner.Token
+	ParamTypes []*Annotation
+	ReturnType *Annotation
+	Body       []Stmt
+
+	span scanner.Span
 }
 
-func NewFunctionStmt(name scanner.Token, params []scanner.Token, body []Stmt) *FunctionStmt {
+func NewFunctionStmt(name scanner.Token, params []scanner.Token, paramTypes []*Annotation, returnType *Annotation, body []Stmt) *FunctionStmt {
 	return &FunctionStmt{
-		Name:   name,
-		Params: params,
-		Body:   body,
+		Name:       name,
+		Params:     params,
+		ParamTypes: paramTypes,
+		ReturnType: returnType,
+		Body:       body,
 	}
 }
 
-func (stmt *FunctionStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *FunctionStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitFunctionStmt(stmt)
 }
 
+func (stmt *FunctionStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *FunctionStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type IfStmt struct {
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
+
+	span scanner.Span
 }
 
 func NewIfStmt(condition Expr, thenBranch Stmt, elseBranch Stmt) *IfStmt {
@@ -98,12 +201,52 @@ func NewIfStmt(condition Expr, thenBranch Stmt, elseBranch Stmt) *IfStmt {
 	}
 }
 
-func (stmt *IfStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *IfStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitIfStmt(stmt)
 }
 
+func (stmt *IfStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *IfStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
+type ImportStmt struct {
+	Keyword scanner.Token
+	Path    scanner.Token
+	Alias   scanner.Token
+	Names   []scanner.Token
+
+	span scanner.Span
+}
+
+func NewImportStmt(keyword scanner.Token, path scanner.Token, alias scanner.Token, names []scanner.Token) *ImportStmt {
+	return &ImportStmt{
+		Keyword: keyword,
+		Path:    path,
+		Alias:   alias,
+		Names:   names,
+	}
+}
+
+func (stmt *ImportStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitImportStmt(stmt)
+}
+
+func (stmt *ImportStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *ImportStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type PrintStmt struct {
 	Expression Expr
+
+	span scanner.Span
 }
 
 func NewPrintStmt(expression Expr) *PrintStmt {
@@ -112,13 +255,23 @@ func NewPrintStmt(expression Expr) *PrintStmt {
 	}
 }
 
-func (stmt *PrintStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *PrintStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitPrintStmt(stmt)
 }
 
+func (stmt *PrintStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *PrintStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type ReturnStmt struct {
 	Keyword scanner.Token
 	Value   Expr
+
+	span scanner.Span
 }
 
 func NewReturnStmt(keyword scanner.Token, value Expr) *ReturnStmt {
@@ -128,38 +281,126 @@ func NewReturnStmt(keyword scanner.Token, value Expr) *ReturnStmt {
 	}
 }
 
-func (stmt *ReturnStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *ReturnStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitReturnStmt(stmt)
 }
 
+func (stmt *ReturnStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *ReturnStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
+type ThrowStmt struct {
+	Keyword scanner.Token
+	Value   Expr
+
+	span scanner.Span
+}
+
+func NewThrowStmt(keyword scanner.Token, value Expr) *ThrowStmt {
+	return &ThrowStmt{
+		Keyword: keyword,
+		Value:   value,
+	}
+}
+
+func (stmt *ThrowStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitThrowStmt(stmt)
+}
+
+func (stmt *ThrowStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *ThrowStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
+type TryStmt struct {
+	Body        []Stmt
+	CatchName   scanner.Token
+	CatchBody   []Stmt
+	FinallyBody []Stmt
+
+	span scanner.Span
+}
+
+func NewTryStmt(body []Stmt, catchName scanner.Token, catchBody []Stmt, finallyBody []Stmt) *TryStmt {
+	return &TryStmt{
+		Body:        body,
+		CatchName:   catchName,
+		CatchBody:   catchBody,
+		FinallyBody: finallyBody,
+	}
+}
+
+func (stmt *TryStmt) Accept(visitor StmtVisitor) (interface{}, error) {
+	return visitor.VisitTryStmt(stmt)
+}
+
+func (stmt *TryStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *TryStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type VarStmt struct {
 	Name        scanner.Token
+	Annotation  *Annotation
 	Initializer Expr
+
+	span scanner.Span
 }
 
-func NewVarStmt(name scanner.Token, initializer Expr) *VarStmt {
+func NewVarStmt(name scanner.Token, annotation *Annotation, initializer Expr) *VarStmt {
 	return &VarStmt{
 		Name:        name,
+		Annotation:  annotation,
 		Initializer: initializer,
 	}
 }
 
-func (stmt *VarStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *VarStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitVarStmt(stmt)
 }
 
+func (stmt *VarStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *VarStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
+
 type WhileStmt struct {
 	Condition Expr
 	Body      Stmt
+	Increment Expr
+
+	span scanner.Span
 }
 
-func NewWhileStmt(condition Expr, body Stmt) *WhileStmt {
+func NewWhileStmt(condition Expr, body Stmt, increment Expr) *WhileStmt {
 	return &WhileStmt{
 		Condition: condition,
 		Body:      body,
+		Increment: increment,
 	}
 }
 
-func (stmt *WhileStmt) Accept(visitor StmtVisitor) interface{} {
+func (stmt *WhileStmt) Accept(visitor StmtVisitor) (interface{}, error) {
 	return visitor.VisitWhileStmt(stmt)
 }
+
+func (stmt *WhileStmt) Span() scanner.Span {
+	return stmt.span
+}
+
+func (stmt *WhileStmt) setSpan(span scanner.Span) {
+	stmt.span = span
+}
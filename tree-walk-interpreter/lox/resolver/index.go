@@ -0,0 +1,104 @@
+package resolver
+
+import (
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// Kind is what a declaration declares.
+type Kind int
+
+const (
+	KindVariable Kind = iota
+	KindParameter
+	KindFunction
+	KindClass
+	KindMethod
+	KindImport
+)
+
+// Declaration is a name declared in a program.
+type Declaration struct {
+	Name scanner.Token
+	Kind Kind
+	// Stmt is the statement that declares the name. For a parameter it is
+	// the function, and for a catch variable the try statement.
+	Stmt parser.Stmt
+	// Class is the class that a method belongs to.
+	Class *parser.ClassStmt
+	// Global is set for names declared at the top level, which are
+	// visible everywhere. Other names are visible from their declaration
+	// to the end of Scope.
+	Global bool
+	Scope  scanner.Span
+}
+
+// Reference is a use of a variable.
+type Reference struct {
+	Name scanner.Token
+	// Declaration is the declaration that Name resolves to, or nil for a
+	// global that is never declared.
+	Declaration *Declaration
+}
+
+// Index records the declarations in a program and what each use of a
+// variable refers to, for tools such as the language server. Property
+// names are recorded as they are used, since which object they belong to
+// isn't known until the program runs.
+type Index struct {
+	Declarations []*Declaration
+	References   []Reference
+	Properties   []scanner.Token
+
+	globals map[string]*Declaration
+	// unresolved holds the indexes of the references that resolved to no
+	// local, which are looked up among the globals once they are all known.
+	unresolved []int
+}
+
+// WithIndex makes the resolver record the program in index as it resolves.
+func WithIndex(index *Index) Option {
+	return func(r *Resolver) {
+		r.index = index
+	}
+}
+
+func (r *Resolver) recordDeclaration(name scanner.Token, kind Kind, stmt parser.Stmt) *Declaration {
+	decl := &Declaration{Name: name, Kind: kind, Stmt: stmt, Global: r.scopes.IsEmpty()}
+	if decl.Global {
+		if r.index.globals == nil {
+			r.index.globals = make(map[string]*Declaration)
+		}
+		if _, ok := r.index.globals[name.Lexeme]; !ok {
+			r.index.globals[name.Lexeme] = decl
+		}
+	} else {
+		decl.Scope = r.spans[len(r.spans)-1]
+	}
+	r.index.Declarations = append(r.index.Declarations, decl)
+	return decl
+}
+
+func (r *Resolver) recordReference(name scanner.Token, v *variable) {
+	if v == nil {
+		r.index.unresolved = append(r.index.unresolved, len(r.index.References))
+		r.index.References = append(r.index.References, Reference{Name: name})
+		return
+	}
+	r.index.References = append(r.index.References, Reference{Name: name, Declaration: v.decl})
+}
+
+// resolveGlobals points the references that resolved to no local at the
+// globals they name.
+func (r *Resolver) resolveGlobals() {
+	pending := r.index.unresolved[:0]
+	for _, j := range r.index.unresolved {
+		ref := &r.index.References[j]
+		if decl, ok := r.index.globals[ref.Name.Lexeme]; ok {
+			ref.Declaration = decl
+		} else {
+			pending = append(pending, j)
+		}
+	}
+	r.index.unresolved = pending
+}
@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fosmjo/lox/diag"
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// lint holds what the resolver needs to know to warn.
+type lint struct {
+	predeclared map[string]bool
+	// globals maps the names of the globals declared so far to their first
+	// declarations.
+	globals map[string]scanner.Token
+	// assignedGlobals holds the names assigned to that resolved to no
+	// local, which are checked once every global is known.
+	assignedGlobals []scanner.Token
+}
+
+// WithWarnings makes the resolver also warn about code that is legal but
+// likely a mistake: locals and parameters that are never read, names that
+// shadow others, unreachable statements, assignments to globals that are
+// never declared, and 'this' in functions nested in methods. predeclared
+// names the globals a program starts with, such as the interpreter's
+// builtins.
+//
+// Locals and parameters whose names start with an underscore may go
+// unused.
+func WithWarnings(predeclared ...string) Option {
+	return func(r *Resolver) {
+		r.lint = &lint{
+			predeclared: make(map[string]bool),
+			globals:     make(map[string]scanner.Token),
+		}
+		for _, name := range predeclared {
+			r.lint.predeclared[name] = true
+		}
+	}
+}
+
+func (l *lint) declareGlobal(name scanner.Token) {
+	if _, ok := l.globals[name.Lexeme]; !ok {
+		l.globals[name.Lexeme] = name
+	}
+}
+
+func (r *Resolver) warning(span scanner.Span, code diag.Code, msg string) {
+	r.diags.Add(code, diag.Warning, span, msg)
+}
+
+// checkUnused warns about the variables in scope that are never read.
+func (r *Resolver) checkUnused(scope scope) {
+	bySlot := make([]*variable, len(scope))
+	for _, v := range scope {
+		bySlot[v.slot] = v
+	}
+
+	for _, v := range bySlot {
+		name := v.name.Lexeme
+		if v.read || name == "" || strings.HasPrefix(name, "_") {
+			continue
+		}
+		switch v.kind {
+		case KindParameter:
+			r.warning(v.name.Span, diag.UnusedParameter, fmt.Sprintf("Parameter '%s' is never used.", name))
+		case KindFunction:
+			r.warning(v.name.Span, diag.UnusedVariable, fmt.Sprintf("Local function '%s' is never used.", name))
+		case KindClass:
+			r.warning(v.name.Span, diag.UnusedVariable, fmt.Sprintf("Local class '%s' is never used.", name))
+		default:
+			r.warning(v.name.Span, diag.UnusedVariable, fmt.Sprintf("Local variable '%s' is never used.", name))
+		}
+	}
+}
+
+// checkShadowed warns if name, about to be declared in the innermost
+// scope, hides a variable of an enclosing scope or a global declared
+// earlier.
+func (r *Resolver) checkShadowed(name scanner.Token) {
+	if r.scopes.IsEmpty() {
+		return
+	}
+
+	var outer scanner.Token
+	for i := r.scopes.Size() - 2; i >= 0 && outer.Lexeme == ""; i-- {
+		if v, ok := r.scopes.Get(i)[name.Lexeme]; ok {
+			outer = v.name
+		}
+	}
+	if outer.Lexeme == "" {
+		outer = r.lint.globals[name.Lexeme]
+	}
+	if outer.Lexeme != "" {
+		r.warning(name.Span, diag.ShadowedVariable,
+			fmt.Sprintf("'%s' shadows the declaration on line %d.", name.Lexeme, outer.Line))
+	}
+}
+
+// checkAssignedGlobals warns about assignments to globals that are never
+// declared, which fail when they run.
+func (r *Resolver) checkAssignedGlobals() {
+	for _, name := range r.lint.assignedGlobals {
+		if _, ok := r.lint.globals[name.Lexeme]; !ok && !r.lint.predeclared[name.Lexeme] {
+			r.warning(name.Span, diag.UndefinedGlobal, fmt.Sprintf("Assignment to undefined global '%s'.", name.Lexeme))
+		}
+	}
+}
+
+// jumps reports whether stmt always leaves the statements it is in.
+func jumps(stmt parser.Stmt) bool {
+	switch stmt.(type) {
+	case *parser.ReturnStmt, *parser.ThrowStmt, *parser.BreakStmt, *parser.ContinueStmt:
+		return true
+	}
+	return false
+}
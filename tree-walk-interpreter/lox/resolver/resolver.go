@@ -1,66 +1,94 @@
 package resolver
 
 import (
-	"github.com/fosmjo/lox/interpreter"
+	"github.com/fosmjo/lox/diag"
 	"github.com/fosmjo/lox/parser"
 	"github.com/fosmjo/lox/scanner"
 )
 
+// Interpreter is told how many scopes out each local variable reference
+// resolves to, and which slot of that scope it is in.
+type Interpreter interface {
+	Resolve(expr parser.Expr, depth, slot int)
+}
+
 type Resolver struct {
-	interpreter     *interpreter.Interpreter
+	interpreter     Interpreter
 	scopes          *stack
 	currentFunction FunctionType
 	currentClass    ClassType
-	lox             loxer
-}
+	inLoop          bool
+	diags           diag.List
 
-type loxer interface {
-	ErrorWithToken(token scanner.Token, msg string)
+	index *Index
+	// spans holds the extent of each scope on the stack, for the index.
+	spans []scanner.Span
+
+	// lint is set if the resolver warns as well; see WithWarnings.
+	lint *lint
 }
 
-func NewResolver(interpreter *interpreter.Interpreter, lox loxer) *Resolver {
+type Option func(*Resolver)
+
+// NewResolver returns a resolver that tells interpreter how local variables
+// resolve. A nil interpreter, for back ends that resolve variables
+// themselves, makes the resolver only check the program.
+func NewResolver(interpreter Interpreter, options ...Option) *Resolver {
 	stack := NewStack()
-	return &Resolver{
+	r := &Resolver{
 		interpreter:     interpreter,
 		scopes:          stack,
 		currentFunction: FunctionTypeNone,
 		currentClass:    ClassTypeNone,
-		lox:             lox,
 	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
 }
 
-func (r *Resolver) VisitBlockStmt(stmt *parser.BlockStmt) interface{} {
-	r.beginScope()
-	r.Resolve(stmt.Statements)
+func (r *Resolver) VisitBlockStmt(stmt *parser.BlockStmt) (interface{}, error) {
+	r.beginScope(stmt.Span())
+	r.resolveStmts(stmt.Statements)
 	r.endScope()
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitClassStmt(stmt *parser.ClassStmt) interface{} {
-	if stmt.Superclass != nil {
-		r.beginScope()
-		r.scopes.Peek()["super"] = true
+func (r *Resolver) VisitBreakStmt(stmt *parser.BreakStmt) (interface{}, error) {
+	if !r.inLoop {
+		r.error(stmt.Keyword, diag.OutsideLoop, "Can't use 'break' outside of a loop.")
 	}
+	return nil, nil
+}
 
+func (r *Resolver) VisitClassStmt(stmt *parser.ClassStmt) (interface{}, error) {
 	enclosingClass := r.currentClass
 	r.currentClass = ClassTypeClass
 
-	r.declare(stmt.Name)
+	r.declare(stmt.Name, KindClass, stmt)
 	r.define(stmt.Name)
 
 	if (stmt.Superclass != nil) && stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
-		r.lox.ErrorWithToken(stmt.Superclass.Name, "A class can't inherit from itself.")
+		r.error(stmt.Superclass.Name, diag.InheritsFromItself, "A class can't inherit from itself.")
 	}
 
 	if stmt.Superclass != nil {
 		r.currentClass = ClassTypeSubclass
 		r.resolveExpr(stmt.Superclass)
+
+		r.beginScope(stmt.Span())
+		r.scopes.Peek()["super"] = &variable{defined: true}
 	}
 
-	r.beginScope()
-	r.scopes.Peek()["this"] = true
+	r.beginScope(stmt.Span())
+	r.scopes.Peek()["this"] = &variable{defined: true}
 
 	for _, m := range stmt.Methods {
+		if r.index != nil {
+			r.recordDeclaration(m.Name, KindMethod, m).Class = stmt
+		}
 		funType := FunctionTypeMethod
 		if m.Name.Lexeme == "init" {
 			funType = FunctionTypeInitializer
@@ -75,166 +103,295 @@ func (r *Resolver) VisitClassStmt(stmt *parser.ClassStmt) interface{} {
 	}
 
 	r.currentClass = enclosingClass
-	return nil
+	return nil, nil
+}
+
+func (r *Resolver) VisitContinueStmt(stmt *parser.ContinueStmt) (interface{}, error) {
+	if !r.inLoop {
+		r.error(stmt.Keyword, diag.OutsideLoop, "Can't use 'continue' outside of a loop.")
+	}
+	return nil, nil
 }
 
-func (r *Resolver) VisitExpressionStmt(stmt *parser.ExpressionStmt) interface{} {
+func (r *Resolver) VisitExpressionStmt(stmt *parser.ExpressionStmt) (interface{}, error) {
 	r.resolveExpr(stmt.Expression)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitFunctionStmt(stmt *parser.FunctionStmt) interface{} {
-	r.declare(stmt.Name)
+func (r *Resolver) VisitFunctionStmt(stmt *parser.FunctionStmt) (interface{}, error) {
+	r.declare(stmt.Name, KindFunction, stmt)
 	r.define(stmt.Name)
 	r.resolveFunction(stmt, FunctionTypeFunction)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitIfStmt(stmt *parser.IfStmt) interface{} {
+func (r *Resolver) VisitIfStmt(stmt *parser.IfStmt) (interface{}, error) {
 	r.resolveExpr(stmt.Condition)
 	r.resolveStmt(stmt.ThenBranch)
 	if stmt.ElseBranch != nil {
 		r.resolveStmt(stmt.ElseBranch)
 	}
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitPrintStmt(stmt *parser.PrintStmt) interface{} {
+func (r *Resolver) VisitImportStmt(stmt *parser.ImportStmt) (interface{}, error) {
+	if len(stmt.Names) == 0 {
+		r.declare(stmt.Alias, KindImport, stmt)
+		r.define(stmt.Alias)
+		return nil, nil
+	}
+
+	for _, name := range stmt.Names {
+		r.declare(name, KindImport, stmt)
+		r.define(name)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitPrintStmt(stmt *parser.PrintStmt) (interface{}, error) {
 	r.resolveExpr(stmt.Expression)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitReturnStmt(stmt *parser.ReturnStmt) interface{} {
+func (r *Resolver) VisitReturnStmt(stmt *parser.ReturnStmt) (interface{}, error) {
 	if r.currentFunction == FunctionTypeNone {
-		r.lox.ErrorWithToken(stmt.Keyword, "Can't return from top-level code.")
+		r.error(stmt.Keyword, diag.InvalidReturn, "Can't return from top-level code.")
 	}
 
 	if stmt.Value != nil {
 		if r.currentFunction == FunctionTypeInitializer {
-			r.lox.ErrorWithToken(stmt.Keyword, "Can't return a value from an initializer.")
+			r.error(stmt.Keyword, diag.InvalidReturn, "Can't return a value from an initializer.")
 		}
 		r.resolveExpr(stmt.Value)
 	}
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitVarStmt(stmt *parser.VarStmt) interface{} {
-	r.declare(stmt.Name)
+func (r *Resolver) VisitThrowStmt(stmt *parser.ThrowStmt) (interface{}, error) {
+	r.resolveExpr(stmt.Value)
+	return nil, nil
+}
+
+func (r *Resolver) VisitTryStmt(stmt *parser.TryStmt) (interface{}, error) {
+	r.beginScope(stmt.Span())
+	r.resolveStmts(stmt.Body)
+	r.endScope()
+
+	if stmt.CatchBody != nil {
+		r.beginScope(stmt.Span())
+		r.declare(stmt.CatchName, KindVariable, stmt)
+		r.define(stmt.CatchName)
+		r.resolveStmts(stmt.CatchBody)
+		r.endScope()
+	}
+
+	if stmt.FinallyBody != nil {
+		r.beginScope(stmt.Span())
+		r.resolveStmts(stmt.FinallyBody)
+		r.endScope()
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitVarStmt(stmt *parser.VarStmt) (interface{}, error) {
+	r.declare(stmt.Name, KindVariable, stmt)
 	if stmt.Initializer != nil {
 		r.resolveExpr(stmt.Initializer)
 	}
 	r.define(stmt.Name)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitWhileStmt(stmt *parser.WhileStmt) interface{} {
+func (r *Resolver) VisitWhileStmt(stmt *parser.WhileStmt) (interface{}, error) {
+	enclosingLoop := r.inLoop
+	r.inLoop = true
+
 	r.resolveExpr(stmt.Condition)
 	r.resolveStmt(stmt.Body)
-	return nil
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
+
+	r.inLoop = enclosingLoop
+	return nil, nil
 }
 
-func (r *Resolver) VisitAssignExpr(expr *parser.AssignExpr) interface{} {
+func (r *Resolver) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, error) {
 	r.resolveExpr(expr.Value)
-	r.resolveLocal(expr, expr.Name)
-	return nil
+	v := r.resolveLocal(expr, expr.Name)
+	if r.index != nil {
+		r.recordReference(expr.Name, v)
+	}
+	if v == nil && r.lint != nil {
+		r.lint.assignedGlobals = append(r.lint.assignedGlobals, expr.Name)
+	}
+	return nil, nil
 }
 
-func (r *Resolver) VisitBinaryExpr(expr *parser.BinaryExpr) interface{} {
+func (r *Resolver) VisitBinaryExpr(expr *parser.BinaryExpr) (interface{}, error) {
 	r.resolveExpr(expr.Left)
 	r.resolveExpr(expr.Right)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitCallExpr(expr *parser.CallExpr) interface{} {
+func (r *Resolver) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
 	r.resolveExpr(expr.Callee)
 	for _, arg := range expr.Arguments {
 		r.resolveExpr(arg)
 	}
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitGetExpr(expr *parser.GetExpr) interface{} {
+func (r *Resolver) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
 	r.resolveExpr(expr.Object)
-	return nil
+	if r.index != nil {
+		r.index.Properties = append(r.index.Properties, expr.Name)
+	}
+	return nil, nil
 }
 
-func (r *Resolver) VisitGroupingExpr(expr *parser.GroupingExpr) interface{} {
+func (r *Resolver) VisitGroupingExpr(expr *parser.GroupingExpr) (interface{}, error) {
 	r.resolveExpr(expr.Expression)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitLiteralExpr(expr *parser.LiteralExpr) interface{} {
-	return nil
+func (r *Resolver) VisitIndexExpr(expr *parser.IndexExpr) (interface{}, error) {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil, nil
+}
+
+func (r *Resolver) VisitIndexSetExpr(expr *parser.IndexSetExpr) (interface{}, error) {
+	r.resolveExpr(expr.Value)
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil, nil
+}
+
+func (r *Resolver) VisitLambdaExpr(expr *parser.LambdaExpr) (interface{}, error) {
+	r.resolveFunction(expr.Declaration, FunctionTypeFunction)
+	return nil, nil
 }
 
-func (r *Resolver) VisitLogicalExpr(expr *parser.LogicalExpr) interface{} {
+func (r *Resolver) VisitListExpr(expr *parser.ListExpr) (interface{}, error) {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitLiteralExpr(expr *parser.LiteralExpr) (interface{}, error) {
+	return nil, nil
+}
+
+func (r *Resolver) VisitLogicalExpr(expr *parser.LogicalExpr) (interface{}, error) {
 	r.resolveExpr(expr.Left)
 	r.resolveExpr(expr.Right)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitSetExpr(expr *parser.SetExpr) interface{} {
+func (r *Resolver) VisitMapExpr(expr *parser.MapExpr) (interface{}, error) {
+	for i := range expr.Keys {
+		r.resolveExpr(expr.Keys[i])
+		r.resolveExpr(expr.Values[i])
+	}
+	return nil, nil
+}
+
+func (r *Resolver) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
 	r.resolveExpr(expr.Value)
 	r.resolveExpr(expr.Object)
-	return nil
+	if r.index != nil {
+		r.index.Properties = append(r.index.Properties, expr.Name)
+	}
+	return nil, nil
 }
 
-func (r *Resolver) VisitSuperExpr(expr *parser.SuperExpr) interface{} {
+func (r *Resolver) VisitSuperExpr(expr *parser.SuperExpr) (interface{}, error) {
 	switch r.currentClass {
 	case ClassTypeNone:
-		r.lox.ErrorWithToken(expr.Keyword, "Can't use 'super' outside of a class.")
+		r.error(expr.Keyword, diag.InvalidSuper, "Can't use 'super' outside of a class.")
 	case ClassTypeClass:
-		r.lox.ErrorWithToken(expr.Keyword, "Can't use 'super' in a class with no superclass.")
+		r.error(expr.Keyword, diag.InvalidSuper, "Can't use 'super' in a class with no superclass.")
 	}
 
 	r.resolveLocal(expr, expr.Keyword)
-	return nil
+	if r.index != nil {
+		r.index.Properties = append(r.index.Properties, expr.Method)
+	}
+	return nil, nil
 }
 
-func (r *Resolver) VisitThisExpr(expr *parser.ThisExpr) interface{} {
+func (r *Resolver) VisitThisExpr(expr *parser.ThisExpr) (interface{}, error) {
 	if r.currentClass == ClassTypeNone {
-		r.lox.ErrorWithToken(expr.Keyword, "Can't use 'this' outside of a class.")
-		return nil
+		r.error(expr.Keyword, diag.InvalidThis, "Can't use 'this' outside of a class.")
+		return nil, nil
+	}
+	if r.currentFunction == FunctionTypeFunction && r.lint != nil {
+		r.warning(expr.Keyword.Span, diag.ThisInFunction, "'this' in a function that isn't a method refers to the enclosing method's instance.")
 	}
 
 	r.resolveLocal(expr, expr.Keyword)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitUnaryExpr(expr *parser.UnaryExpr) interface{} {
+func (r *Resolver) VisitUnaryExpr(expr *parser.UnaryExpr) (interface{}, error) {
 	r.resolveExpr(expr.Right)
-	return nil
+	return nil, nil
 }
 
-func (r *Resolver) VisitVariableExpr(expr *parser.VariableExpr) interface{} {
+func (r *Resolver) VisitVariableExpr(expr *parser.VariableExpr) (interface{}, error) {
 	if !r.scopes.IsEmpty() {
-		if v, ok := r.scopes.Peek()[expr.Name.Lexeme]; ok && !v {
-			r.lox.ErrorWithToken(expr.Name, "Can't read local variable in its own initializer.")
+		if v, ok := r.scopes.Peek()[expr.Name.Lexeme]; ok && !v.defined {
+			r.error(expr.Name, diag.OwnInitializer, "Can't read local variable in its own initializer.")
 		}
 	}
-	r.resolveLocal(expr, expr.Name)
-	return nil
+	v := r.resolveLocal(expr, expr.Name)
+	if v != nil {
+		v.read = true
+	}
+	if r.index != nil {
+		r.recordReference(expr.Name, v)
+	}
+	return nil, nil
 }
 
-func (r *Resolver) beginScope() {
+// beginScope opens a scope that covers span.
+func (r *Resolver) beginScope(span scanner.Span) {
 	r.scopes.Push(make(scope))
+	r.spans = append(r.spans, span)
 }
 
 func (r *Resolver) endScope() {
+	if r.lint != nil {
+		r.checkUnused(r.scopes.Peek())
+	}
 	r.scopes.Pop()
+	r.spans = r.spans[:len(r.spans)-1]
 }
 
-func (r *Resolver) declare(name scanner.Token) {
+// declare declares name, which stmt declares as a kind of thing.
+func (r *Resolver) declare(name scanner.Token, kind Kind, stmt parser.Stmt) {
+	var decl *Declaration
+	if r.index != nil {
+		decl = r.recordDeclaration(name, kind, stmt)
+	}
+	if r.lint != nil {
+		r.checkShadowed(name)
+	}
 	if r.scopes.IsEmpty() {
+		if r.lint != nil {
+			r.lint.declareGlobal(name)
+		}
 		return
 	}
 
 	scope := r.scopes.Peek()
 	if _, ok := scope[name.Lexeme]; ok {
-		r.lox.ErrorWithToken(name, "Already variable with this name in this scope.")
+		r.error(name, diag.AlreadyDeclared, "Already variable with this name in this scope.")
 	}
 
-	scope[name.Lexeme] = false
+	scope[name.Lexeme] = &variable{slot: len(scope), decl: decl, name: name, kind: kind}
 }
 
 func (r *Resolver) define(name scanner.Token) {
@@ -242,37 +399,88 @@ func (r *Resolver) define(name scanner.Token) {
 		return
 	}
 
-	scope := r.scopes.Peek()
-	scope[name.Lexeme] = true
+	r.scopes.Peek()[name.Lexeme].defined = true
 }
 
-func (r *Resolver) resolveLocal(expr parser.Expr, name scanner.Token) {
+// resolveLocal resolves name to the innermost local variable of that name,
+// which it returns, or to a global, in which case it returns nil.
+func (r *Resolver) resolveLocal(expr parser.Expr, name scanner.Token) *variable {
 	for i := r.scopes.Size() - 1; i >= 0; i-- {
-		if _, ok := r.scopes.Get(i)[name.Lexeme]; ok {
-			r.interpreter.Resolve(expr, r.scopes.Size()-1-i)
-			return
+		if v, ok := r.scopes.Get(i)[name.Lexeme]; ok {
+			if r.interpreter != nil {
+				r.interpreter.Resolve(expr, r.scopes.Size()-1-i, v.slot)
+			}
+			return v
 		}
 	}
+	return nil
 }
 
-func (r *Resolver) Resolve(stmts []parser.Stmt) {
-	for _, stmt := range stmts {
+// Resolve resolves stmts and returns the diagnostics for every problem it
+// has found so far, in order.
+func (r *Resolver) Resolve(stmts []parser.Stmt) diag.List {
+	r.resolveStmts(stmts)
+	if r.index != nil {
+		r.resolveGlobals()
+	}
+	if r.lint != nil {
+		r.checkAssignedGlobals()
+	}
+	return r.diags
+}
+
+// ResolveExpr resolves expr as if it appeared where the local variables
+// named in scopes are in scope. scopes lists the names outermost scope
+// first, each scope's names in slot order.
+func (r *Resolver) ResolveExpr(expr parser.Expr, scopes [][]string) diag.List {
+	for _, names := range scopes {
+		r.beginScope(scanner.Span{})
+		for slot, name := range names {
+			r.scopes.Peek()[name] = &variable{defined: true, slot: slot}
+			switch name {
+			case "this":
+				if r.currentClass == ClassTypeNone {
+					r.currentClass = ClassTypeClass
+				}
+			case "super":
+				r.currentClass = ClassTypeSubclass
+			}
+		}
+	}
+	r.resolveExpr(expr)
+	return r.diags
+}
+
+func (r *Resolver) resolveStmts(stmts []parser.Stmt) {
+	unreachable := false
+	for j, stmt := range stmts {
 		r.resolveStmt(stmt)
+		if r.lint != nil && !unreachable && j+1 < len(stmts) && jumps(stmt) {
+			r.warning(stmts[j+1].Span(), diag.UnreachableCode, "Unreachable code.")
+			unreachable = true
+		}
 	}
 }
 
 func (r *Resolver) resolveFunction(stmt *parser.FunctionStmt, funType FunctionType) {
 	enclosingFunction := r.currentFunction
 	r.currentFunction = funType
+	enclosingLoop := r.inLoop
+	r.inLoop = false
 
-	r.beginScope()
+	r.beginScope(stmt.Span())
 	for _, param := range stmt.Params {
-		r.declare(param)
+		r.declare(param, KindParameter, stmt)
 		r.define(param)
 	}
-	r.Resolve(stmt.Body)
+	r.resolveStmts(stmt.Body)
 	r.endScope()
 	r.currentFunction = enclosingFunction
+	r.inLoop = enclosingLoop
+}
+
+func (r *Resolver) error(token scanner.Token, code diag.Code, msg string) {
+	r.diags.Add(code, diag.Error, token.Span, msg)
 }
 
 func (r *Resolver) resolveStmt(stmt parser.Stmt) {
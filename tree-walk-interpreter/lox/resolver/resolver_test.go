@@ -0,0 +1,100 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fosmjo/lox/parser"
+	"github.com/fosmjo/lox/scanner"
+)
+
+// recorder is an Interpreter that notes each resolved reference as
+// "name depth slot", in the order they're resolved.
+type recorder struct {
+	refs []string
+}
+
+func (r *recorder) Resolve(expr parser.Expr, depth, slot int) {
+	var name string
+	switch expr := expr.(type) {
+	case *parser.VariableExpr:
+		name = expr.Name.Lexeme
+	case *parser.AssignExpr:
+		name = expr.Name.Lexeme + "="
+	case *parser.ThisExpr:
+		name = "this"
+	case *parser.SuperExpr:
+		name = "super"
+	}
+	r.refs = append(r.refs, fmt.Sprintf("%s %d %d", name, depth, slot))
+}
+
+func TestSlots(t *testing.T) {
+	for _, tt := range []struct {
+		name, src string
+		want      []string
+	}{
+		{"block", `
+{
+  var a = 1;
+  var b = 2;
+  print b + a;
+}`, []string{"b 0 1", "a 0 0"}},
+		{"shadowing", `
+{
+  var a = 1;
+  {
+    var b = a;
+    var a = 2;
+    print a + b;
+  }
+  a = 3;
+}`, []string{"a 1 0", "a 0 1", "b 0 0", "a= 0 0"}},
+		{"parameters and locals", `
+fun f(x, y) {
+  var z = x;
+  return y + z;
+}`, []string{"x 0 0", "y 0 1", "z 0 2"}},
+		{"closures", `
+fun outer() {
+  var a = 1;
+  fun inner(b) {
+    var c = a + b;
+    return fun () { return a + b + c; };
+  }
+  return inner;
+}`, []string{"a 1 0", "b 0 0", "a 2 0", "b 1 0", "c 1 1", "inner 0 1"}},
+		{"catch", `
+{
+  var a = 1;
+  try {
+    var b = 2;
+    throw b;
+  } catch (e) {
+    var c = e;
+    print a + c;
+  }
+}`, []string{"b 0 0", "e 0 0", "a 1 0", "c 0 1"}},
+		{"this and super", `
+class A { m() { return 1; } }
+class B < A {
+  m() { return super.m() + this.n; }
+}`, []string{"super 2 0", "this 1 0"}},
+		{"globals aren't resolved", `
+var g = 1;
+fun f() { return g; }`, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, diags := parser.NewParser(scanner.New(tt.src).ScanTokens()).Parse()
+			r := &recorder{}
+			diags = append(diags, NewResolver(r).Resolve(stmts)...)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			if got, want := strings.Join(r.refs, ", "), strings.Join(tt.want, ", "); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -1,10 +1,27 @@
 package resolver
 
+import "github.com/fosmjo/lox/scanner"
+
 type stack struct {
 	scopes []scope
 }
 
-type scope map[string]bool
+type scope map[string]*variable
+
+// variable is a local declared in a scope. Its slot is its index in the
+// scope's environment at runtime.
+type variable struct {
+	defined bool
+	slot    int
+	decl    *Declaration
+
+	// name and kind are those of the declaration, and read whether the
+	// variable is ever read, for the warnings. name is empty for the
+	// variables that the resolver declares itself, 'this' and 'super'.
+	name scanner.Token
+	kind Kind
+	read bool
+}
 
 func NewStack() *stack {
 	scopes := make([]scope, 0)
@@ -1,134 +1,191 @@
 package scanner
 
-import "strconv"
+import (
+	"strconv"
+	"unicode/utf8"
+)
 
+// Scanner splits Lox source into tokens, which NextToken returns one at a
+// time. Text that isn't a token comes back as an ERROR token rather than
+// being reported, so that each user of the scanner can report it as it
+// sees fit.
 type Scanner struct {
+	file   *File
 	source string
-	tokens []Token
-	lox    loxer
+	// comments holds the comments scanned since the last token, which
+	// become the next token's.
+	comments []Comment
 
 	start, current, line int
+	// lineStart is the offset of the first byte of the current line, and
+	// startPos the position of the token being scanned.
+	lineStart int
+	startPos  Position
 }
 
-type loxer interface {
-	Error(line int, msg string)
+type Option func(*Scanner)
+
+func New(source string, options ...Option) *Scanner {
+	s := &Scanner{
+		file:      &File{Source: source},
+		source:    source,
+		start:     0,
+		current:   0,
+		line:      1,
+		lineStart: 0,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
-func New(source string, lox loxer) *Scanner {
-	return &Scanner{
-		source:  source,
-		lox:     lox,
-		tokens:  make([]Token, 0),
-		start:   0,
-		current: 0,
-		line:    0,
+// WithFileName names the file being scanned in the spans of its tokens.
+func WithFileName(name string) Option {
+	return func(s *Scanner) {
+		s.file.Name = name
 	}
 }
 
+// ScanTokens returns the rest of the tokens, up to and including the EOF
+// token.
 func (s *Scanner) ScanTokens() []Token {
+	tokens := make([]Token, 0)
+	for {
+		token := s.NextToken()
+		tokens = append(tokens, token)
+		if token.Type == EOF {
+			return tokens
+		}
+	}
+}
+
+// NextToken scans and returns the next token. At the end of the source it
+// returns an EOF token, and keeps doing so if called again.
+func (s *Scanner) NextToken() Token {
 	for !s.isAtEnd() {
 		s.start = s.current
-		s.scanToken()
+		s.startPos = s.position()
+		if token, ok := s.scanToken(); ok {
+			return token
+		}
 	}
 
-	eof := NewToken(EOF, "", nil, s.line)
-	s.tokens = append(s.tokens, eof)
-	return s.tokens
+	s.start = s.current
+	s.startPos = s.position()
+	return s.makeToken(EOF, nil)
 }
 
-func (s *Scanner) scanToken() {
+// scanToken scans the next token, or whitespace or a comment, in which case
+// ok is false.
+func (s *Scanner) scanToken() (token Token, ok bool) {
 	ch := s.advance()
 	switch ch {
 	case '(':
-		s.addToken(LEFT_PAREN)
+		return s.addToken(LEFT_PAREN)
 	case ')':
-		s.addToken(RIGHT_PAREN)
+		return s.addToken(RIGHT_PAREN)
 	case '{':
-		s.addToken(LEFT_BRACE)
+		return s.addToken(LEFT_BRACE)
 	case '}':
-		s.addToken(RIGHT_BRACE)
+		return s.addToken(RIGHT_BRACE)
+	case '[':
+		return s.addToken(LEFT_BRACKET)
+	case ']':
+		return s.addToken(RIGHT_BRACKET)
+	case ':':
+		return s.addToken(COLON)
 	case ',':
-		s.addToken(COMMA)
+		return s.addToken(COMMA)
 	case '.':
-		s.addToken(DOT)
+		return s.addToken(DOT)
 	case '-':
-		s.addToken(MINUS)
+		return s.addToken(MINUS)
 	case '+':
-		s.addToken(PLUS)
+		return s.addToken(PLUS)
 	case ';':
-		s.addToken(SEMICOLON)
+		return s.addToken(SEMICOLON)
 	case '*':
-		s.addToken(STAR)
+		return s.addToken(STAR)
 	case '!':
 		tokenType := BANG
 		if s.match('=') {
 			tokenType = BANG_EQUAL
 		}
-		s.addToken(tokenType)
+		return s.addToken(tokenType)
 	case '=':
 		tokenType := EQUAL
 		if s.match('=') {
 			tokenType = EQUAL_EQUAL
 		}
-		s.addToken(tokenType)
+		return s.addToken(tokenType)
 	case '<':
 		tokenType := LESS
 		if s.match('=') {
 			tokenType = BANG_EQUAL
 		}
-		s.addToken(tokenType)
+		return s.addToken(tokenType)
 	case '>':
 		tokenType := GREATER
 		if s.match('=') {
 			tokenType = GREATER_EQUAL
 		}
-		s.addToken(tokenType)
+		return s.addToken(tokenType)
 	case '/':
 		if s.match('/') {
 			// A comment goes until the end of the line.
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
-		} else {
-			s.addToken(SLASH)
+			s.comments = append(s.comments, Comment{Text: s.currentLexeme(), Span: s.span()})
+			return Token{}, false
 		}
+		return s.addToken(SLASH)
 	case ' ', '\r', '\t':
 		// ignore whitespace
+		return Token{}, false
 	case '\n':
-		s.line++
+		s.newline()
+		return Token{}, false
 	case '"':
-		s.scanString()
+		return s.scanString()
 	default:
 		if s.isDigit(ch) {
-			s.scanNumber()
-		} else if s.isAlpha(ch) {
-			s.scanIdentifier()
-		} else {
-			s.lox.Error(s.line, "Unexpected character")
+			return s.scanNumber()
+		}
+		if s.isAlpha(ch) {
+			return s.scanIdentifier()
+		}
+		if ch >= utf8.RuneSelf {
+			// Take the rest of the character, so that it makes one token.
+			_, size := utf8.DecodeRuneInString(s.source[s.start:])
+			s.current = s.start + size
 		}
+		return s.errorToken("Unexpected character.")
 	}
 }
 
-func (s *Scanner) scanString() {
+func (s *Scanner) scanString() (Token, bool) {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
-		}
 		s.advance()
+		if s.source[s.current-1] == '\n' {
+			s.newline()
+		}
 	}
 
 	if s.isAtEnd() {
-		s.lox.Error(s.line, "Unterminated string.")
-		return
+		return s.errorToken("Unterminated string.")
 	}
 	// Consume closing "
 	s.advance()
 	// Trim the surrounding quotes
 	str := s.source[s.start+1 : s.current-1]
-	s.addToken(STRING, str)
+	return s.addToken(STRING, str)
 }
 
-func (s *Scanner) scanNumber() {
+func (s *Scanner) scanNumber() (Token, bool) {
 	for s.isDigit(s.peek()) {
 		s.advance()
 	}
@@ -142,13 +199,13 @@ func (s *Scanner) scanNumber() {
 
 	num, err := strconv.ParseFloat(s.currentLexeme(), 64)
 	if err != nil {
-		s.lox.Error(s.line, "Invalid number")
+		return s.errorToken("Invalid number.")
 	}
 
-	s.addToken(NUMBER, num)
+	return s.addToken(NUMBER, num)
 }
 
-func (s *Scanner) scanIdentifier() {
+func (s *Scanner) scanIdentifier() (Token, bool) {
 	for s.isAlphaNumeric(s.peek()) {
 		s.advance()
 	}
@@ -159,18 +216,44 @@ func (s *Scanner) scanIdentifier() {
 		tokenType = IDENTIFIER
 	}
 
-	s.addToken(tokenType)
+	return s.addToken(tokenType)
 }
 
-func (s *Scanner) addToken(tokenType TokenType, literal ...interface{}) {
+func (s *Scanner) addToken(tokenType TokenType, literal ...interface{}) (Token, bool) {
 	var lit interface{}
 	if len(literal) > 0 {
 		lit = literal[0]
 	}
+	return s.makeToken(tokenType, lit), true
+}
+
+// errorToken returns an ERROR token for the text being scanned, whose
+// literal is msg.
+func (s *Scanner) errorToken(msg string) (Token, bool) {
+	return s.makeToken(ERROR, msg), true
+}
+
+// makeToken returns a token for the text being scanned, which takes the
+// comments that precede it.
+func (s *Scanner) makeToken(tokenType TokenType, literal interface{}) Token {
+	token := NewToken(tokenType, s.currentLexeme(), literal, s.span())
+	token.Comments, s.comments = s.comments, nil
+	return token
+}
+
+// newline records that the byte just consumed ended a line.
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+
+func (s *Scanner) position() Position {
+	return Position{Offset: s.current, Line: s.line, Column: s.current - s.lineStart + 1}
+}
 
-	text := s.currentLexeme()
-	token := NewToken(tokenType, text, lit, s.line)
-	s.tokens = append(s.tokens, token)
+// span returns the span of the token being scanned.
+func (s *Scanner) span() Span {
+	return Span{File: s.file, Start: s.startPos, End: s.position()}
 }
 
 func (s *Scanner) currentLexeme() string {
@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNextToken(t *testing.T) {
+	s := New("var x = 1.5; // one\nprint x >= \"a\\nb\";", WithFileName("test.lox"))
+
+	want := []struct {
+		tokenType TokenType
+		lexeme    string
+		literal   interface{}
+	}{
+		{VAR, "var", nil},
+		{IDENTIFIER, "x", nil},
+		{EQUAL, "=", nil},
+		{NUMBER, "1.5", 1.5},
+		{SEMICOLON, ";", nil},
+		{PRINT, "print", nil},
+		{IDENTIFIER, "x", nil},
+		{GREATER_EQUAL, ">=", nil},
+		{STRING, `"a\nb"`, `a\nb`},
+		{SEMICOLON, ";", nil},
+		{EOF, "", nil},
+		// EOF again once the source runs out.
+		{EOF, "", nil},
+	}
+	for j, w := range want {
+		token := s.NextToken()
+		if token.Type != w.tokenType || token.Lexeme != w.lexeme || token.Literal != w.literal {
+			t.Errorf("token %d: got %s %q %v, want %s %q %v",
+				j, token.Type, token.Lexeme, token.Literal, w.tokenType, w.lexeme, w.literal)
+		}
+		if token.Span.File == nil || token.Span.File.Name != "test.lox" {
+			t.Errorf("token %d: got file %v, want test.lox", j, token.Span.File)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	tokens := New("var x;\n  \"two\nlines\" // c\n").ScanTokens()
+
+	want := []struct {
+		lexeme     string
+		start, end Position
+	}{
+		{"var", Position{0, 1, 1}, Position{3, 1, 4}},
+		{"x", Position{4, 1, 5}, Position{5, 1, 6}},
+		{";", Position{5, 1, 6}, Position{6, 1, 7}},
+		{"\"two\nlines\"", Position{9, 2, 3}, Position{20, 3, 7}},
+		{"", Position{26, 4, 1}, Position{26, 4, 1}},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(want))
+	}
+	for j, w := range want {
+		token := tokens[j]
+		if token.Lexeme != w.lexeme || token.Span.Start != w.start || token.Span.End != w.end {
+			t.Errorf("token %d: got %q from %v to %v, want %q from %v to %v",
+				j, token.Lexeme, token.Span.Start, token.Span.End, w.lexeme, w.start, w.end)
+		}
+		if token.Line != w.start.Line {
+			t.Errorf("token %d: got line %d, want %d", j, token.Line, w.start.Line)
+		}
+	}
+
+	// The comment belongs to the token after it, here EOF.
+	comments := tokens[len(tokens)-1].Comments
+	if len(comments) != 1 || comments[0].Text != "// c" || comments[0].Span.Start != (Position{21, 3, 8}) {
+		t.Errorf("got comments %v, want // c at 3:8", comments)
+	}
+}
+
+func TestErrorTokens(t *testing.T) {
+	for _, tt := range []struct {
+		src    string
+		lexeme string
+		msg    string
+		start  Position
+		end    Position
+	}{
+		{`print "a"; é`, "é", "Unexpected character.", Position{11, 1, 12}, Position{13, 1, 14}},
+		{"x = 1 # 2", "#", "Unexpected character.", Position{6, 1, 7}, Position{7, 1, 8}},
+		{"\n\"open", "\"open", "Unterminated string.", Position{1, 2, 1}, Position{6, 2, 6}},
+		{"€€", "€", "Unexpected character.", Position{0, 1, 1}, Position{3, 1, 4}},
+		{"\xff;", "\xff", "Unexpected character.", Position{0, 1, 1}, Position{1, 1, 2}},
+	} {
+		var errors []Token
+		for _, token := range New(tt.src).ScanTokens() {
+			if token.Type == ERROR {
+				errors = append(errors, token)
+			}
+		}
+		if len(errors) == 0 {
+			t.Errorf("%q: got no ERROR tokens", tt.src)
+			continue
+		}
+		got := errors[0]
+		if got.Lexeme != tt.lexeme || got.Literal != tt.msg || got.Span.Start != tt.start || got.Span.End != tt.end {
+			t.Errorf("%q: got %q (%v) from %v to %v, want %q (%s) from %v to %v",
+				tt.src, got.Lexeme, got.Literal, got.Span.Start, got.Span.End, tt.lexeme, tt.msg, tt.start, tt.end)
+		}
+	}
+
+	// One character is one error, however many bytes it takes.
+	tokens := New("€€").ScanTokens()
+	var types []TokenType
+	for _, token := range tokens {
+		types = append(types, token.Type)
+	}
+	if want := []TokenType{ERROR, ERROR, EOF}; !reflect.DeepEqual(types, want) {
+		t.Errorf("got %v, want %v", types, want)
+	}
+}
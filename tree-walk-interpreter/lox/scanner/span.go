@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"strconv"
+	"strings"
+)
+
+// File is a named piece of Lox source that tokens point back into.
+type File struct {
+	Name   string
+	Source string
+}
+
+// Line returns the text of the 1-based line n, without its line ending.
+func (f *File) Line(n int) string {
+	lines := strings.SplitN(f.Source, "\n", n+1)
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n-1], "\r")
+}
+
+// Position is a place in a file. Offset counts bytes from 0, while Line and
+// Column count from 1, with Column counting bytes within the line.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the stretch of a file from Start up to, but not including, End.
+type Span struct {
+	File  *File
+	Start Position
+	End   Position
+}
+
+// Join returns the span that covers both s and other.
+func (s Span) Join(other Span) Span {
+	if other.Start.Offset < s.Start.Offset {
+		s.Start = other.Start
+	}
+	if other.End.Offset > s.End.Offset {
+		s.End = other.End
+	}
+	return s
+}
+
+// String formats the start of the span as name:line:column.
+func (s Span) String() string {
+	name := "<input>"
+	if s.File != nil && s.File.Name != "" {
+		name = s.File.Name
+	}
+	return name + ":" + strconv.Itoa(s.Start.Line) + ":" + strconv.Itoa(s.Start.Column)
+}
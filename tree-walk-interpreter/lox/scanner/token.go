@@ -1,6 +1,9 @@
 package scanner
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 //go:generate stringer -type=TokenType
 
@@ -14,6 +17,9 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
+	COLON
 	COMMA
 	DOT
 	MINUS
@@ -39,46 +45,93 @@ const (
 
 	// Keywords.
 	AND
+	AS
+	BREAK
+	CATCH
 	CLASS
+	CONTINUE
 	ELSE
 	FALSE
+	FINALLY
 	FUN
 	FOR
 	IF
+	IMPORT
 	NIL
 	OR
 	PRINT
 	RETURN
 	SUPER
 	THIS
+	THROW
 	TRUE
+	TRY
 	VAR
 	WHILE
 
 	EOF
+
+	// ERROR is text that isn't a token, whose literal says what is wrong
+	// with it.
+	ERROR
 )
 
+// MarshalText implements encoding.TextMarshaler, naming t as its constant
+// does.
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *TokenType) UnmarshalText(text []byte) error {
+	for tt := INVALID; tt <= ERROR; tt++ {
+		if tt.String() == string(text) {
+			*t = tt
+			return nil
+		}
+	}
+	return fmt.Errorf("scanner: unknown token type %q", text)
+}
+
 var keywords map[string]TokenType
 
 func init() {
 	keywords = map[string]TokenType{
-		"and":    AND,
-		"class":  CLASS,
-		"else":   ELSE,
-		"false":  FALSE,
-		"for":    FOR,
-		"fun":    FUN,
-		"if":     IF,
-		"nil":    NIL,
-		"or":     OR,
-		"print":  PRINT,
-		"return": RETURN,
-		"super":  SUPER,
-		"this":   THIS,
-		"true":   TRUE,
-		"var":    VAR,
-		"while":  WHILE,
+		"and":      AND,
+		"as":       AS,
+		"break":    BREAK,
+		"catch":    CATCH,
+		"class":    CLASS,
+		"continue": CONTINUE,
+		"else":     ELSE,
+		"false":    FALSE,
+		"finally":  FINALLY,
+		"for":      FOR,
+		"fun":      FUN,
+		"if":       IF,
+		"import":   IMPORT,
+		"nil":      NIL,
+		"or":       OR,
+		"print":    PRINT,
+		"return":   RETURN,
+		"super":    SUPER,
+		"this":     THIS,
+		"throw":    THROW,
+		"true":     TRUE,
+		"try":      TRY,
+		"var":      VAR,
+		"while":    WHILE,
+	}
+}
+
+// Keywords returns Lox's reserved words in alphabetical order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
 	}
+	sort.Strings(words)
+	return words
 }
 
 type Token struct {
@@ -86,14 +139,25 @@ type Token struct {
 	Lexeme  string
 	Literal interface{}
 	Line    int
+	Span    Span
+	// Comments are the comments between the previous token and this one.
+	Comments []Comment
+}
+
+// Comment is a '//' comment, which the scanner keeps as trivia of the token
+// that follows it rather than as a token of its own.
+type Comment struct {
+	Text string
+	Span Span
 }
 
-func NewToken(tokenType TokenType, lexeme string, lit interface{}, line int) Token {
+func NewToken(tokenType TokenType, lexeme string, lit interface{}, span Span) Token {
 	return Token{
 		Type:    tokenType,
 		Lexeme:  lexeme,
 		Literal: lit,
-		Line:    line,
+		Line:    span.Start.Line,
+		Span:    span,
 	}
 }
 
@@ -13,46 +13,58 @@ func _() {
 	_ = x[RIGHT_PAREN-2]
 	_ = x[LEFT_BRACE-3]
 	_ = x[RIGHT_BRACE-4]
-	_ = x[COMMA-5]
-	_ = x[DOT-6]
-	_ = x[MINUS-7]
-	_ = x[PLUS-8]
-	_ = x[SEMICOLON-9]
-	_ = x[SLASH-10]
-	_ = x[STAR-11]
-	_ = x[BANG-12]
-	_ = x[BANG_EQUAL-13]
-	_ = x[EQUAL-14]
-	_ = x[EQUAL_EQUAL-15]
-	_ = x[GREATER-16]
-	_ = x[GREATER_EQUAL-17]
-	_ = x[LESS-18]
-	_ = x[LESS_EQUAL-19]
-	_ = x[IDENTIFIER-20]
-	_ = x[STRING-21]
-	_ = x[NUMBER-22]
-	_ = x[AND-23]
-	_ = x[CLASS-24]
-	_ = x[ELSE-25]
-	_ = x[FALSE-26]
-	_ = x[FUN-27]
-	_ = x[FOR-28]
-	_ = x[IF-29]
-	_ = x[NIL-30]
-	_ = x[OR-31]
-	_ = x[PRINT-32]
-	_ = x[RETURN-33]
-	_ = x[SUPER-34]
-	_ = x[THIS-35]
-	_ = x[TRUE-36]
-	_ = x[VAR-37]
-	_ = x[WHILE-38]
-	_ = x[EOF-39]
+	_ = x[LEFT_BRACKET-5]
+	_ = x[RIGHT_BRACKET-6]
+	_ = x[COLON-7]
+	_ = x[COMMA-8]
+	_ = x[DOT-9]
+	_ = x[MINUS-10]
+	_ = x[PLUS-11]
+	_ = x[SEMICOLON-12]
+	_ = x[SLASH-13]
+	_ = x[STAR-14]
+	_ = x[BANG-15]
+	_ = x[BANG_EQUAL-16]
+	_ = x[EQUAL-17]
+	_ = x[EQUAL_EQUAL-18]
+	_ = x[GREATER-19]
+	_ = x[GREATER_EQUAL-20]
+	_ = x[LESS-21]
+	_ = x[LESS_EQUAL-22]
+	_ = x[IDENTIFIER-23]
+	_ = x[STRING-24]
+	_ = x[NUMBER-25]
+	_ = x[AND-26]
+	_ = x[AS-27]
+	_ = x[BREAK-28]
+	_ = x[CATCH-29]
+	_ = x[CLASS-30]
+	_ = x[CONTINUE-31]
+	_ = x[ELSE-32]
+	_ = x[FALSE-33]
+	_ = x[FINALLY-34]
+	_ = x[FUN-35]
+	_ = x[FOR-36]
+	_ = x[IF-37]
+	_ = x[IMPORT-38]
+	_ = x[NIL-39]
+	_ = x[OR-40]
+	_ = x[PRINT-41]
+	_ = x[RETURN-42]
+	_ = x[SUPER-43]
+	_ = x[THIS-44]
+	_ = x[THROW-45]
+	_ = x[TRUE-46]
+	_ = x[TRY-47]
+	_ = x[VAR-48]
+	_ = x[WHILE-49]
+	_ = x[EOF-50]
+	_ = x[ERROR-51]
 }
 
-const _TokenType_name = "INVALIDLEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERANDCLASSELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEEOF"
+const _TokenType_name = "INVALIDLEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACELEFT_BRACKETRIGHT_BRACKETCOLONCOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERANDASBREAKCATCHCLASSCONTINUEELSEFALSEFINALLYFUNFORIFIMPORTNILORPRINTRETURNSUPERTHISTHROWTRUETRYVARWHILEEOFERROR"
 
-var _TokenType_index = [...]uint8{0, 7, 17, 28, 38, 49, 54, 57, 62, 66, 75, 80, 84, 88, 98, 103, 114, 121, 134, 138, 148, 158, 164, 170, 173, 178, 182, 187, 190, 193, 195, 198, 200, 205, 211, 216, 220, 224, 227, 232, 235}
+var _TokenType_index = [...]uint16{0, 7, 17, 28, 38, 49, 61, 74, 79, 84, 87, 92, 96, 105, 110, 114, 118, 128, 133, 144, 151, 164, 168, 178, 188, 194, 200, 203, 205, 210, 215, 220, 228, 232, 237, 244, 247, 250, 252, 258, 261, 263, 268, 274, 279, 283, 288, 292, 295, 298, 303, 306, 311}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
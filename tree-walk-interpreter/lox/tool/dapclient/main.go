@@ -0,0 +1,354 @@
+// Dapclient drives `lox dap` from a script of commands, so that the debug
+// adapter can be tried out without an editor:
+//
+//	go build -o lox . && go run ./tool/dapclient -lox ./lox -b 12 script.lox <<EOF
+//	vars
+//	next
+//	eval a + b
+//	continue
+//	EOF
+//
+// It launches the script, prints what the script prints, and reads the
+// next command from standard input each time the script stops.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/fosmjo/lox/dap"
+)
+
+const usage = `Commands, read each time the script stops:
+  continue, next, stepIn, stepOut  resume the script
+  stack                            print the stack trace
+  vars [depth]                     print the scopes of the innermost frame,
+                                   expanding values depth levels (default 1)
+  eval expr                        evaluate expr in the innermost frame
+  break line...                    replace the script's breakpoints
+  quit                             disconnect
+`
+
+type lines []int
+
+func (l *lines) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *lines) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, n)
+	return nil
+}
+
+type client struct {
+	conn  *dap.Conn
+	trace bool
+
+	// stopped and terminated record the events that the command loop
+	// waits for.
+	stopped    bool
+	terminated bool
+}
+
+func main() {
+	loxCmd := flag.String("lox", "lox", "`command` that runs lox")
+	entry := flag.Bool("entry", false, "stop on the first statement")
+	trace := flag.Bool("trace", false, "print every message to standard error")
+	var breaks lines
+	flag.Var(&breaks, "b", "set a breakpoint on `line` of the script")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: dapclient [-lox command] [-entry] [-trace] [-b line]... script")
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	script := flag.Arg(0)
+
+	args := append(strings.Fields(*loxCmd), "dap")
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := cmd.Start(); err != nil {
+		log.Fatalln(err)
+	}
+
+	c := &client{conn: dap.NewConn(stdout, stdin), trace: *trace}
+	c.request("initialize", map[string]interface{}{"adapterID": "lox", "linesStartAt1": true, "columnsStartAt1": true})
+	c.request("launch", map[string]interface{}{"program": script, "stopOnEntry": *entry})
+	c.wait("initialized")
+	c.setBreakpoints(script, breaks)
+	c.request("configurationDone", nil)
+
+	commands := bufio.NewScanner(os.Stdin)
+	for {
+		for !c.stopped && !c.terminated {
+			c.handle(c.read())
+		}
+		if c.terminated {
+			break
+		}
+		c.stopped = false
+		c.printStop()
+		if !c.command(commands, script) {
+			break
+		}
+	}
+
+	c.request("disconnect", nil)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// command runs commands until one resumes the script, and reports whether
+// the script was resumed rather than abandoned.
+func (c *client) command(commands *bufio.Scanner, script string) bool {
+	for commands.Scan() {
+		line := strings.TrimSpace(commands.Text())
+		if line == "" {
+			continue
+		}
+		fmt.Println(">", line)
+		name, arg := line, ""
+		if j := strings.IndexByte(line, ' '); j >= 0 {
+			name, arg = line[:j], strings.TrimSpace(line[j+1:])
+		}
+
+		switch name {
+		case "continue", "next", "stepIn", "stepOut":
+			c.request(name, map[string]int{"threadId": 1})
+			return true
+		case "stack":
+			c.printStack()
+		case "vars":
+			depth := 1
+			if arg != "" {
+				depth, _ = strconv.Atoi(arg)
+			}
+			c.printScopes(depth)
+		case "eval":
+			var body struct {
+				Result             string `json:"result"`
+				VariablesReference int    `json:"variablesReference"`
+			}
+			if c.request("evaluate", map[string]interface{}{"expression": arg, "frameId": 0}, &body) {
+				fmt.Println(body.Result)
+				c.printVariables(body.VariablesReference, 1, "  ")
+			}
+		case "break":
+			var bps lines
+			for _, f := range strings.Fields(arg) {
+				if err := bps.Set(f); err != nil {
+					fmt.Println(err)
+				}
+			}
+			c.setBreakpoints(script, bps)
+		case "quit":
+			return false
+		default:
+			fmt.Print(usage)
+		}
+	}
+	return false
+}
+
+func (c *client) setBreakpoints(script string, bps lines) {
+	breakpoints := make([]map[string]int, 0, len(bps))
+	for _, line := range bps {
+		breakpoints = append(breakpoints, map[string]int{"line": line})
+	}
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": script},
+		"breakpoints": breakpoints,
+	})
+}
+
+type stackFrame struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Line   int    `json:"line"`
+	Source struct {
+		Name string `json:"name"`
+	} `json:"source"`
+}
+
+func (c *client) stack() []stackFrame {
+	var body struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]int{"threadId": 1}, &body)
+	return body.StackFrames
+}
+
+func (c *client) printStop() {
+	if frames := c.stack(); len(frames) > 0 {
+		f := frames[0]
+		fmt.Printf("stopped at %s:%d in %s\n", f.Source.Name, f.Line, f.Name)
+	}
+}
+
+func (c *client) printStack() {
+	for _, f := range c.stack() {
+		fmt.Printf("#%d %s:%d in %s\n", f.ID, f.Source.Name, f.Line, f.Name)
+	}
+}
+
+func (c *client) printScopes(depth int) {
+	var body struct {
+		Scopes []struct {
+			Name               string `json:"name"`
+			VariablesReference int    `json:"variablesReference"`
+		} `json:"scopes"`
+	}
+	c.request("scopes", map[string]int{"frameId": 0}, &body)
+	for _, scope := range body.Scopes {
+		fmt.Println(scope.Name + ":")
+		c.printVariables(scope.VariablesReference, depth, "  ")
+	}
+}
+
+func (c *client) printVariables(ref, depth int, indent string) {
+	if ref == 0 || depth == 0 {
+		return
+	}
+
+	var body struct {
+		Variables []struct {
+			Name               string `json:"name"`
+			Value              string `json:"value"`
+			VariablesReference int    `json:"variablesReference"`
+		} `json:"variables"`
+	}
+	c.request("variables", map[string]int{"variablesReference": ref}, &body)
+	for _, v := range body.Variables {
+		fmt.Printf("%s%s = %s\n", indent, v.Name, v.Value)
+		c.printVariables(v.VariablesReference, depth-1, indent+"  ")
+	}
+}
+
+// request sends a request and handles messages until its response, which
+// it decodes into body, if given. It reports whether the request
+// succeeded.
+func (c *client) request(command string, args interface{}, body ...interface{}) bool {
+	req := &dap.Request{Command: command}
+	if args != nil {
+		raw, err := json.Marshal(args)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		req.Arguments = raw
+	}
+	if err := c.conn.Write(req); err != nil {
+		log.Fatalln(err)
+	}
+
+	for {
+		msg := c.read()
+		var resp struct {
+			dap.Response
+			Body json.RawMessage `json:"body"`
+		}
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			log.Fatalln(err)
+		}
+		if resp.Type != "response" || resp.RequestSeq != req.Seq {
+			c.handle(msg)
+			continue
+		}
+
+		if !resp.Success {
+			fmt.Printf("%s failed: %s\n", command, resp.ErrMessage)
+			return false
+		}
+		if len(body) > 0 && resp.Body != nil {
+			if err := json.Unmarshal(resp.Body, body[0]); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		return true
+	}
+}
+
+// wait handles messages until the named event arrives.
+func (c *client) wait(event string) {
+	for {
+		msg := c.read()
+		var e dap.Event
+		if err := json.Unmarshal(msg, &e); err != nil {
+			log.Fatalln(err)
+		}
+		c.handle(msg)
+		if e.Type == "event" && e.Event == event {
+			return
+		}
+	}
+}
+
+// handle handles an event.
+func (c *client) handle(msg []byte) {
+	var e struct {
+		Type  string          `json:"type"`
+		Event string          `json:"event"`
+		Body  json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(msg, &e); err != nil || e.Type != "event" {
+		return
+	}
+
+	switch e.Event {
+	case "output":
+		var body struct {
+			Output string `json:"output"`
+		}
+		json.Unmarshal(e.Body, &body)
+		fmt.Print(body.Output)
+	case "stopped":
+		c.stopped = true
+	case "exited":
+		var body struct {
+			ExitCode int `json:"exitCode"`
+		}
+		json.Unmarshal(e.Body, &body)
+		fmt.Printf("exited with code %d\n", body.ExitCode)
+	case "terminated":
+		c.terminated = true
+	}
+}
+
+func (c *client) read() []byte {
+	msg, err := c.conn.Read()
+	if err == io.EOF {
+		log.Fatalln("adapter closed the connection")
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if c.trace {
+		fmt.Fprintf(os.Stderr, "<- %s\n", msg)
+	}
+	return msg
+}
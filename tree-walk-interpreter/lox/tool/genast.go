@@ -17,7 +17,9 @@ func defineAst(w io.Writer, baseName string, types []string) {
 	fmt.Fprintf(w, "import \"github.com/fosmjo/lox/scanner\"\n\n")
 
 	fmt.Fprintf(w, "type %s interface {\n", baseName)
-	fmt.Fprintf(w, "    Accept(%sVisitor) interface{}\n", baseName)
+	fmt.Fprintf(w, "    Accept(%sVisitor) (interface{}, error)\n", baseName)
+	fmt.Fprintf(w, "    Span() scanner.Span\n")
+	fmt.Fprintf(w, "    setSpan(scanner.Span)\n")
 	fmt.Fprintf(w, "}\n\n")
 
 	defineVisitor(w, baseName, types)
@@ -33,7 +35,7 @@ func defineVisitor(w io.Writer, baseName string, types []string) {
 	for _, s := range types {
 		exprType := strings.Split(s, ":")[0]
 		exprStructName := strings.TrimSpace(exprType) + baseName
-		fmt.Fprintf(w, "    Visit%s(*%s) interface{}\n", exprStructName, exprStructName)
+		fmt.Fprintf(w, "    Visit%s(*%s) (interface{}, error)\n", exprStructName, exprStructName)
 	}
 
 	fmt.Fprintf(w, "}\n\n")
@@ -54,6 +56,7 @@ func defineType(w io.Writer, baseName, s string) {
 		fmt.Fprintf(w, "    %s %s\n", fieldName, fieldType)
 	}
 
+	fmt.Fprintf(w, "\n    span scanner.Span\n")
 	fmt.Fprintf(w, "}\n\n")
 
 	// factory
@@ -71,9 +74,18 @@ func defineType(w io.Writer, baseName, s string) {
 	fmt.Fprintf(w, "}\n\n")
 
 	// implement Expr
-	fmt.Fprintf(w, "func (%s *%s) Accept(visitor %sVisitor) interface{} {\n", strings.ToLower(baseName), structName, baseName)
+	fmt.Fprintf(w, "func (%s *%s) Accept(visitor %sVisitor) (interface{}, error) {\n", strings.ToLower(baseName), structName, baseName)
 	fmt.Fprintf(w, "    return visitor.Visit%s(%s)\n", structName, strings.ToLower(baseName))
 	fmt.Fprintf(w, "}\n\n")
+
+	// source span
+	fmt.Fprintf(w, "func (%s *%s) Span() scanner.Span {\n", strings.ToLower(baseName), structName)
+	fmt.Fprintf(w, "    return %s.span\n", strings.ToLower(baseName))
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "func (%s *%s) setSpan(span scanner.Span) {\n", strings.ToLower(baseName), structName)
+	fmt.Fprintf(w, "    %s.span = span\n", strings.ToLower(baseName))
+	fmt.Fprintf(w, "}\n\n")
 }
 
 func gen(fileName, baseName string, types []string) {
@@ -106,8 +118,13 @@ func main() {
 			"Call     : callee Expr, paren scanner.Token, arguments []Expr",
 			"Get      : object Expr, name scanner.Token",
 			"Grouping : expression Expr",
+			"Index    : object Expr, bracket scanner.Token, index Expr",
+			"IndexSet : object Expr, bracket scanner.Token, index Expr, value Expr",
+			"Lambda   : declaration *FunctionStmt",
+			"List     : elements []Expr",
 			"Literal  : value interface{}",
 			"Logical  : left Expr, operator scanner.Token, right Expr",
+			"Map      : brace scanner.Token, keys []Expr, values []Expr",
 			"Set      : object Expr, name scanner.Token, value Expr",
 			"Super    : keyword scanner.Token, method scanner.Token",
 			"This     : keyword scanner.Token",
@@ -120,14 +137,19 @@ func main() {
 		"Stmt",
 		[]string{
 			"Block      : statements []Stmt",
+			"Break      : keyword scanner.Token",
 			"Expression : expression Expr",
-			"Class      : name scanner.Token, superclass *VariableExpr, methods []*FunctionStmt",
-			"Function   : name scanner.Token, params []scanner.Token, body []Stmt",
+			"Class      : name scanner.Token, superclass *VariableExpr, fields []*Field, methods []*FunctionStmt",
+			"Continue   : keyword scanner.Token",
+			"Function   : name scanner.Token, params []scanner.Token, paramTypes []*Annotation, returnType *Annotation, body []Stmt",
 			"If         : condition Expr, thenBranch Stmt, elseBranch Stmt",
+			"Import     : keyword scanner.Token, path scanner.Token, alias scanner.Token, names []scanner.Token",
 			"Print      : expression Expr",
 			"Return     : keyword scanner.Token, value Expr",
-			"Var        : name scanner.Token, initializer Expr",
-			"While      : condition Expr, body Stmt",
+			"Throw      : keyword scanner.Token, value Expr",
+			"Try        : body []Stmt, catchName scanner.Token, catchBody []Stmt, finallyBody []Stmt",
+			"Var        : name scanner.Token, annotation *Annotation, initializer Expr",
+			"While      : condition Expr, body Stmt, increment Expr",
 		},
 	)
 }